@@ -0,0 +1,44 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+type AlarmsListResponse struct {
+	Total int64
+	List  []sipapi.Alarms
+}
+
+// @Summary     报警列表接口
+// @Description 可以根据查询条件查询设备上报的报警记录
+// @Tags        alarms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       limit   query    integer false "条数(0-100) 默认20"
+// @Param       skip    query    integer false "间隔 默认0"
+// @Param       sort    query    string  false "排序,例:-key,根据key倒序,key,根据key正序"
+// @Param       filters query    string  false "查询条件,使用规则详情请看帮助"
+// @Success     0       {object} AlarmsListResponse
+// @Failure     1000    {object} string
+// @Failure     1001    {object} string
+// @Failure     1002    {object} string
+// @Failure     1003    {object} string
+// @Router      /alarms [get]
+func AlarmsList(c *gin.Context) {
+	limit := m.GetLimit(c)
+	skip := m.GetSkip(c)
+	sort := m.GetSort(c)
+	alarms := []sipapi.Alarms{}
+	total, err := db.FindWithJson(db.DBClient, new(sipapi.Alarms), &alarms, c.Query("filters"), sort, skip, limit, true)
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, AlarmsListResponse{
+		Total: total,
+		List:  alarms,
+	})
+}
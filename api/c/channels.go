@@ -193,5 +193,6 @@ func ChannelsDelete(c *gin.Context) {
 		m.JsonResponse(c, m.StatusDBERR, err)
 		return
 	}
+	db.DelQ(db.DBClient, new(sipapi.Presets), db.M{"channelid=?": channelid})
 	m.JsonResponse(c, m.StatusSucc, "")
 }
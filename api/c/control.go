@@ -0,0 +1,77 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     设备控制
+// @Description 对设备发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。
+// @Tags        control
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true "设备id"
+// @Success     0    {object} string
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /devices/{id}/reboot [post]
+// @Router      /devices/{id}/record/start [post]
+// @Router      /devices/{id}/record/stop [post]
+// @Router      /devices/{id}/guard/set [post]
+// @Router      /devices/{id}/guard/reset [post]
+// @Router      /devices/{id}/alarm/reset [post]
+func DevicesControl(cmd string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		device := &sipapi.Devices{DeviceID: c.Param("id")}
+		if err := db.Get(db.DBClient, device); err != nil {
+			if db.RecordNotFound(err) {
+				m.JsonResponse(c, m.StatusParamsERR, "设备不存在")
+				return
+			}
+			m.JsonResponse(c, m.StatusDBERR, err)
+			return
+		}
+		result, err := sipapi.SipDevicesControl(device.DeviceID, cmd)
+		if err != nil {
+			m.JsonResponse(c, m.StatusSysERR, err)
+			return
+		}
+		m.JsonResponse(c, m.StatusSucc, result)
+	}
+}
+
+// @Summary     通道控制
+// @Description 对通道发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。
+// @Tags        control
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true "通道id"
+// @Success     0    {object} string
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /channels/{id}/reboot [post]
+// @Router      /channels/{id}/record/start [post]
+// @Router      /channels/{id}/record/stop [post]
+// @Router      /channels/{id}/guard/set [post]
+// @Router      /channels/{id}/guard/reset [post]
+// @Router      /channels/{id}/alarm/reset [post]
+func ChannelsControl(cmd string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		channel, ok := getOnlineChannel(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		result, err := sipapi.SipChannelsControl(channel, cmd)
+		if err != nil {
+			m.JsonResponse(c, m.StatusSysERR, err)
+			return
+		}
+		m.JsonResponse(c, m.StatusSucc, result)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     设备配置查询
+// @Description 向设备查询配置，最多等待10s，只返回查询的配置类型。
+// @Tags        devices
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id         path     string true  "设备id"
+// @Param       configtype query    string false "配置类型:BasicParam,VideoParamOpt,SVACEncodeConfig,SnapShot，多个用/分隔，默认BasicParam"
+// @Success     0          {object} sipapi.DeviceConfigs
+// @Failure     1000       {object} string
+// @Failure     1001       {object} string
+// @Failure     1002       {object} string
+// @Failure     1003       {object} string
+// @Router      /devices/{id}/config [get]
+func DevicesConfig(c *gin.Context) {
+	device, ok := getDevice(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	types := []string{}
+	for _, t := range strings.Split(c.Query("configtype"), "/") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	configs, err := sipapi.SipConfigDownload(device.DeviceID, types)
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, configs)
+}
+
+// @Summary     设备配置
+// @Description 修改设备基本参数，只下发不为空的参数，返回设备应答结果(OK/ERROR)。
+// @Tags        devices
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id                path     string true  "设备id"
+// @Param       name              formData string false "设备名称"
+// @Param       expiration        formData int    false "注册过期时间，秒"
+// @Param       heartbeatinterval formData int    false "心跳间隔时间，秒"
+// @Param       heartbeatcount    formData int    false "心跳超时次数"
+// @Success     0                 {object} string
+// @Failure     1000              {object} string
+// @Failure     1001              {object} string
+// @Failure     1002              {object} string
+// @Failure     1003              {object} string
+// @Router      /devices/{id}/config [post]
+func DevicesConfigUpdate(c *gin.Context) {
+	device, ok := getDevice(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	param, ok := parseBasicParam(c)
+	if !ok {
+		return
+	}
+	result, err := sipapi.SipDeviceConfig(device.DeviceID, param)
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, result)
+}
+
+// @Summary     批量设备配置
+// @Description 并发修改多个设备的基本参数，只下发不为空的参数，每个设备最多等待10s，按deviceids顺序返回每个设备的应答结果或失败原因。
+// @Tags        devices
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       deviceids         formData string true  "设备id，多个用,分隔，all 表示所有在线设备"
+// @Param       name              formData string false "设备名称"
+// @Param       expiration        formData int    false "注册过期时间，秒"
+// @Param       heartbeatinterval formData int    false "心跳间隔时间，秒"
+// @Param       heartbeatcount    formData int    false "心跳超时次数"
+// @Success     0                 {object} []sipapi.DeviceConfigResult
+// @Failure     1000              {object} string
+// @Failure     1001              {object} string
+// @Failure     1002              {object} string
+// @Failure     1003              {object} string
+// @Router      /devices/config [post]
+func DevicesConfigBatch(c *gin.Context) {
+	ids := []string{}
+	if str := strings.TrimSpace(c.PostForm("deviceids")); str != "all" {
+		for _, id := range strings.Split(str, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			m.JsonResponse(c, m.StatusParamsERR, "设备id不能为空")
+			return
+		}
+	}
+	param, ok := parseBasicParam(c)
+	if !ok {
+		return
+	}
+	results, err := sipapi.SipDevicesConfig(ids, param)
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, results)
+}
+
+func parseBasicParam(c *gin.Context) (sipapi.BasicParam, bool) {
+	param := sipapi.BasicParam{Name: c.PostForm("name")}
+	for key, v := range map[string]*int{
+		"expiration":        &param.Expiration,
+		"heartbeatinterval": &param.HeartBeatInterval,
+		"heartbeatcount":    &param.HeartBeatCount,
+	} {
+		if c.PostForm(key) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(c.PostForm(key))
+		if err != nil || n <= 0 {
+			m.JsonResponse(c, m.StatusParamsERR, key+"错误")
+			return param, false
+		}
+		*v = n
+	}
+	return param, true
+}
+
+func getDevice(c *gin.Context, deviceid string) (*sipapi.Devices, bool) {
+	device := &sipapi.Devices{DeviceID: deviceid}
+	if err := db.Get(db.DBClient, device); err != nil {
+		if db.RecordNotFound(err) {
+			m.JsonResponse(c, m.StatusParamsERR, "设备不存在")
+			return nil, false
+		}
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return nil, false
+	}
+	return device, true
+}
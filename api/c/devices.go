@@ -15,8 +15,10 @@ import (
 // @Tags        devices
 // @Accept      x-www-form-urlencoded
 // @Produce     json
-// @Param       pwd  formData string true "设备密码(GB28181认证密码)"
-// @Param       name formData string true "设备名称"
+// @Param       pwd        formData string true  "设备密码(GB28181认证密码)"
+// @Param       name       formData string true  "设备名称"
+// @Param       algorithms formData string false "接受的摘要认证算法,多个用逗号分隔,可选MD5,MD5-sess,SHA-256,SHA-256-sess,为空使用全局配置"
+// @Param       autocreate formData int    false "自动创建目录中未注册的通道,0使用全局配置,1创建,-1不创建"
 // @Success     0    {object} sipapi.Devices
 // @Failure     1000 {object} string
 // @Failure     1001 {object} string
@@ -30,11 +32,23 @@ func DevicesCreate(c *gin.Context) {
 		return
 	}
 	name := c.PostForm("name")
+	algorithms, err := sipapi.ParseAuthAlgorithms(c.PostForm("algorithms"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	autoCreate, err := parseAutoCreate(c.PostForm("autocreate"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
 	device := sipapi.Devices{
-		DeviceID: fmt.Sprintf("%s%06d", m.MConfig.GB28181.DID, m.MConfig.GB28181.DNUM+1),
-		Region:   m.MConfig.GB28181.Region,
-		PWD:      pwd,
-		Name:     name,
+		DeviceID:   fmt.Sprintf("%s%06d", m.MConfig.GB28181.DID, m.MConfig.GB28181.DNUM+1),
+		Region:     m.MConfig.GB28181.Region,
+		PWD:        pwd,
+		Name:       name,
+		Algorithms: algorithms,
+		AutoCreate: autoCreate,
 	}
 	if device.Name == "" {
 		device.Name = device.DeviceID
@@ -65,9 +79,11 @@ func DevicesCreate(c *gin.Context) {
 // @Tags        devices
 // @Accept      x-www-form-urlencoded
 // @Produce     json
-// @Param       id   path     string true "设备id"
-// @Param       pwd  formData string false "设备密码(GB28181认证密码)"
-// @Param       name formData string false "设备名称"
+// @Param       id         path     string true  "设备id"
+// @Param       pwd        formData string false "设备密码(GB28181认证密码)"
+// @Param       name       formData string false "设备名称"
+// @Param       algorithms formData string false "接受的摘要认证算法,多个用逗号分隔,可选MD5,MD5-sess,SHA-256,SHA-256-sess,传空值时使用全局配置"
+// @Param       autocreate formData int    false "自动创建目录中未注册的通道,0使用全局配置,1创建,-1不创建"
 // @Success     0    {object} sipapi.Devices
 // @Failure     1000 {object} string
 // @Failure     1001 {object} string
@@ -97,6 +113,22 @@ func DevicesUpdate(c *gin.Context) {
 	if name != "" {
 		device.Name = name
 	}
+	if v, ok := c.GetPostForm("algorithms"); ok {
+		algorithms, err := sipapi.ParseAuthAlgorithms(v)
+		if err != nil {
+			m.JsonResponse(c, m.StatusParamsERR, err.Error())
+			return
+		}
+		device.Algorithms = algorithms
+	}
+	if v, ok := c.GetPostForm("autocreate"); ok {
+		autoCreate, err := parseAutoCreate(v)
+		if err != nil {
+			m.JsonResponse(c, m.StatusParamsERR, err.Error())
+			return
+		}
+		device.AutoCreate = autoCreate
+	}
 	if err := db.Save(db.DBClient, device); err != nil {
 		m.JsonResponse(c, m.StatusDBERR, err)
 		return
@@ -181,6 +213,10 @@ func DevicesDelete(c *gin.Context) {
 		m.JsonResponse(c, m.StatusDBERR, err)
 		return
 	}
+	if err := db.Del(tx.DB(), &sipapi.Nodes{DeviceID: deviceid}); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
 	tx.Commit()
 	m.JsonResponse(c, m.StatusSucc, "")
 }
@@ -304,3 +340,16 @@ func DevicesDelete(c *gin.Context) {
 // 	router.POST("/index/hook/:method", apiWebHooks)
 // 	logrus.Fatal(http.ListenAndServe(config.API, router))
 // }
+
+// 自动创建通道配置 0使用全局配置 1创建 -1不创建
+func parseAutoCreate(v string) (int, error) {
+	switch v {
+	case "", "0":
+		return 0, nil
+	case "1":
+		return 1, nil
+	case "-1":
+		return -1, nil
+	}
+	return 0, fmt.Errorf("autocreate参数错误:%s", v)
+}
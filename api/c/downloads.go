@@ -0,0 +1,72 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     历史视频下载
+// @Description 设备按倍速发送历史视频，收到流后录制为mp4，设备通知发送结束后停止录制并关闭流。通过下载进度接口查询进度和文件地址，配置downloads_done时下载完成后发送通知。
+// @Tags        downloads
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id    path     string true  "通道id"
+// @Param       start formData int    true  "开始时间，时间戳"
+// @Param       end   formData int    true  "结束时间，时间戳"
+// @Param       speed formData int    false "下载倍速，默认4"
+// @Success     0     {object} sipapi.Streams
+// @Failure     1000  {object} string
+// @Failure     1001  {object} string
+// @Failure     1002  {object} string
+// @Failure     1003  {object} string
+// @Router      /channels/{id}/download [post]
+func Download(c *gin.Context) {
+	start, _ := strconv.ParseInt(c.PostForm("start"), 10, 64)
+	if start <= 0 {
+		m.JsonResponse(c, m.StatusParamsERR, "开始时间错误")
+		return
+	}
+	end, _ := strconv.ParseInt(c.PostForm("end"), 10, 64)
+	if start >= end {
+		m.JsonResponse(c, m.StatusParamsERR, "开始时间>=结束时间")
+		return
+	}
+	speed := 0
+	if v := c.PostForm("speed"); v != "" {
+		var err error
+		if speed, err = strconv.Atoi(v); err != nil || speed <= 0 {
+			m.JsonResponse(c, m.StatusParamsERR, "下载倍速错误")
+			return
+		}
+	}
+	res, err := sipapi.SipDownload(c.Param("id"), start, end, speed)
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, res)
+}
+
+// @Summary     历史视频下载进度
+// @Description 返回下载流信息，progress为下载进度(0-100)，下载完成后file为mp4文件地址。
+// @Tags        downloads
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true "流id,下载接口返回的streamid"
+// @Success     0    {object} sipapi.Streams
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /streams/{id}/download [get]
+func DownloadInfo(c *gin.Context) {
+	stream, err := sipapi.GetDownload(c.Param("id"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, stream)
+}
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     上级平台新增接口
+// @Description 新增一个上级平台，启用后本平台作为下级平台定时向上级注册并发送心跳
+// @Tags        platforms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       platformid formData string true  "上级平台编号"
+// @Param       region     formData string true  "上级平台域"
+// @Param       addr       formData string true  "上级平台信令地址,ip:port"
+// @Param       name       formData string false "上级平台名称"
+// @Param       transport  formData string false "信令传输协议,UDP或TCP,默认UDP,不支持TLS"
+// @Param       username   formData string false "认证用户名,为空使用本平台编号"
+// @Param       pwd        formData string false "认证密码"
+// @Param       expires    formData int    false "注册有效期,秒,默认3600"
+// @Param       keepalive  formData int    false "心跳间隔,秒,默认60"
+// @Param       enable     formData int    false "是否启用,1启用,0停用,默认1"
+// @Success     0    {object} sipapi.Platforms
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /platforms [post]
+func PlatformsCreate(c *gin.Context) {
+	platform := &sipapi.Platforms{
+		PlatformID: c.PostForm("platformid"),
+		Region:     c.PostForm("region"),
+		Addr:       c.PostForm("addr"),
+		TransPort:  "UDP",
+		Enable:     true,
+	}
+	if platform.PlatformID == "" || platform.Region == "" || platform.Addr == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "上级平台编号、域、地址不能为空")
+		return
+	}
+	if err := db.Get(db.DBClient, &sipapi.Platforms{PlatformID: platform.PlatformID}); err == nil {
+		m.JsonResponse(c, m.StatusParamsERR, "上级平台已存在")
+		return
+	} else if !db.RecordNotFound(err) {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	if err := parsePlatform(c, platform); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	if err := db.Create(db.DBClient, platform); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, platform)
+}
+
+// @Summary     上级平台修改接口
+// @Description 修改后先向上级注销，启用的平台在下次检查时使用新配置重新注册
+// @Tags        platforms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id        path     string true  "上级平台编号"
+// @Param       region    formData string false "上级平台域"
+// @Param       addr      formData string false "上级平台信令地址,ip:port"
+// @Param       name      formData string false "上级平台名称"
+// @Param       transport formData string false "信令传输协议,UDP或TCP,不支持TLS"
+// @Param       username  formData string false "认证用户名,为空使用本平台编号"
+// @Param       pwd       formData string false "认证密码"
+// @Param       expires   formData int    false "注册有效期,秒"
+// @Param       keepalive formData int    false "心跳间隔,秒"
+// @Param       enable    formData int    false "是否启用,1启用,0停用"
+// @Success     0    {object} sipapi.Platforms
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /platforms/{id} [post]
+func PlatformsUpdate(c *gin.Context) {
+	platform, ok := getPlatform(c)
+	if !ok {
+		return
+	}
+	if v := c.PostForm("region"); v != "" {
+		platform.Region = v
+	}
+	if v := c.PostForm("addr"); v != "" {
+		platform.Addr = v
+	}
+	if err := parsePlatform(c, platform); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	sipapi.PlatformUnregister(platform.PlatformID)
+	platform.Online = false
+	platform.Expire = 0
+	if err := db.Save(db.DBClient, platform); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, platform)
+}
+
+type PlatformsListResponse struct {
+	Total int64
+	List  []sipapi.Platforms
+}
+
+// @Summary     上级平台列表接口
+// @Description 可以根据查询条件查询上级平台列表，包含注册状态
+// @Tags        platforms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       limit   query    integer false "条数(0-100) 默认20"
+// @Param       skip    query    integer false "间隔 默认0"
+// @Param       sort    query    string  false "排序,例:-key,根据key倒序,key,根据key正序"
+// @Param       filters query    string  false "查询条件,使用规则详情请看帮助"
+// @Success     0       {object} PlatformsListResponse
+// @Failure     1000    {object} string
+// @Failure     1001    {object} string
+// @Failure     1002    {object} string
+// @Failure     1003    {object} string
+// @Router      /platforms [get]
+func PlatformsList(c *gin.Context) {
+	limit := m.GetLimit(c)
+	skip := m.GetSkip(c)
+	sort := m.GetSort(c)
+	platforms := []sipapi.Platforms{}
+	total, err := db.FindWithJson(db.DBClient, new(sipapi.Platforms), &platforms, c.Query("filters"), sort, skip, limit, true)
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, PlatformsListResponse{
+		Total: total,
+		List:  platforms,
+	})
+}
+
+// @Summary     上级平台删除接口
+// @Description 向上级注销并删除上级平台，同时删除共享给该平台的通道
+// @Tags        platforms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true "上级平台编号"
+// @Success     0    {object} string
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /platforms/{id} [delete]
+func PlatformsDelete(c *gin.Context) {
+	platform, ok := getPlatform(c)
+	if !ok {
+		return
+	}
+	sipapi.PlatformUnregister(platform.PlatformID)
+	tx, err := db.NewTx(db.DBClient)
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	defer tx.End()
+	if err := db.Del(tx.DB(), platform); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	if err := db.Del(tx.DB(), &sipapi.PlatformChannels{PlatformID: platform.PlatformID}); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	tx.Commit()
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+type PlatformChannelsListResponse struct {
+	Total int64
+	List  []sipapi.PlatformChannels
+}
+
+// @Summary     上级平台共享通道列表
+// @Description 共享给上级平台的通道，上级平台查询目录时返回这些通道
+// @Tags        platforms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id    path     string  true  "上级平台编号"
+// @Param       limit query    integer false "条数(0-100) 默认20"
+// @Param       skip  query    integer false "间隔 默认0"
+// @Success     0     {object} PlatformChannelsListResponse
+// @Failure     1000  {object} string
+// @Failure     1001  {object} string
+// @Failure     1002  {object} string
+// @Failure     1003  {object} string
+// @Router      /platforms/{id}/channels [get]
+func PlatformChannelsList(c *gin.Context) {
+	limit := m.GetLimit(c)
+	skip := m.GetSkip(c)
+	list := []sipapi.PlatformChannels{}
+	total, err := db.FindT(db.DBClient, new(sipapi.PlatformChannels), &list, db.M{"platformid=?": c.Param("id")}, "id", skip, limit, true)
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, PlatformChannelsListResponse{
+		Total: total,
+		List:  list,
+	})
+}
+
+// @Summary     上级平台共享通道添加
+// @Description 将通道共享给上级平台，已共享的通道忽略
+// @Tags        platforms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id         path     string true "上级平台编号"
+// @Param       channelids formData string true "通道id,多个用逗号分隔"
+// @Success     0    {object} string
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /platforms/{id}/channels [post]
+func PlatformChannelsAdd(c *gin.Context) {
+	platform, ok := getPlatform(c)
+	if !ok {
+		return
+	}
+	ids, ok := platformChannelIDs(c, c.PostForm("channelids"))
+	if !ok {
+		return
+	}
+	channels := []sipapi.Channels{}
+	if _, err := db.FindT(db.DBClient, new(sipapi.Channels), &channels, db.M{"channelid in (?)": ids}, "", 0, -1, false); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	if len(channels) != len(ids) {
+		m.JsonResponse(c, m.StatusParamsERR, "通道id不存在")
+		return
+	}
+	exists := []sipapi.PlatformChannels{}
+	if _, err := db.FindT(db.DBClient, new(sipapi.PlatformChannels), &exists, db.M{"platformid=?": platform.PlatformID, "channelid in (?)": ids}, "", 0, -1, false); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	shared := map[string]bool{}
+	for _, item := range exists {
+		shared[item.ChannelID] = true
+	}
+	for _, id := range ids {
+		if shared[id] {
+			continue
+		}
+		if err := db.Create(db.DBClient, &sipapi.PlatformChannels{PlatformID: platform.PlatformID, ChannelID: id}); err != nil {
+			m.JsonResponse(c, m.StatusDBERR, err)
+			return
+		}
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+// @Summary     上级平台共享通道删除
+// @Description 取消通道共享
+// @Tags        platforms
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id         path     string true "上级平台编号"
+// @Param       channelids query    string true "通道id,多个用逗号分隔"
+// @Success     0    {object} string
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /platforms/{id}/channels [delete]
+func PlatformChannelsDelete(c *gin.Context) {
+	platform, ok := getPlatform(c)
+	if !ok {
+		return
+	}
+	ids, ok := platformChannelIDs(c, c.Query("channelids"))
+	if !ok {
+		return
+	}
+	if err := db.DelQ(db.DBClient, &sipapi.PlatformChannels{}, db.M{"platformid=?": platform.PlatformID, "channelid in (?)": ids}); err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+func getPlatform(c *gin.Context) (*sipapi.Platforms, bool) {
+	platform := &sipapi.Platforms{PlatformID: c.Param("id")}
+	if err := db.Get(db.DBClient, platform); err != nil {
+		if db.RecordNotFound(err) {
+			m.JsonResponse(c, m.StatusParamsERR, "上级平台不存在")
+			return nil, false
+		}
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return nil, false
+	}
+	return platform, true
+}
+
+// 解析上级平台的可选参数
+func parsePlatform(c *gin.Context, platform *sipapi.Platforms) error {
+	if v, ok := c.GetPostForm("name"); ok {
+		platform.Name = v
+	}
+	if v, ok := c.GetPostForm("transport"); ok {
+		platform.TransPort = strings.ToUpper(v)
+		if platform.TransPort != "UDP" && platform.TransPort != "TCP" {
+			// tls 连接只能由设备发起，不支持向上级平台建立
+			return errors.New("transport 只支持UDP、TCP，不支持TLS")
+		}
+	}
+	if v, ok := c.GetPostForm("username"); ok {
+		platform.Username = v
+	}
+	if v, ok := c.GetPostForm("pwd"); ok {
+		platform.PWD = v
+	}
+	for key, field := range map[string]*int{"expires": &platform.Expires, "keepalive": &platform.Keepalive} {
+		if v, ok := c.GetPostForm(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s 参数错误", key)
+			}
+			*field = n
+		}
+	}
+	if v, ok := c.GetPostForm("enable"); ok {
+		platform.Enable = v == "1"
+	}
+	return nil
+}
+
+// 解析逗号分隔的通道id
+func platformChannelIDs(c *gin.Context, str string) ([]string, bool) {
+	ids := []string{}
+	for _, id := range strings.Split(str, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		m.JsonResponse(c, m.StatusParamsERR, "通道id不能为空")
+		return nil, false
+	}
+	return ids, true
+}
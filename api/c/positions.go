@@ -0,0 +1,46 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     移动设备位置轨迹
+// @Description 查询通道时间段内上报的位置轨迹，按时间正序。需要先开启设备的mobileposition订阅，或设备主动上报。通道最新位置见通道信息的longitude,latitude,positionat字段。
+// @Tags        positions
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id    path     string true  "通道id"
+// @Param       start query    int    true  "开始时间，时间戳"
+// @Param       end   query    int    false "结束时间，时间戳，默认当前时间"
+// @Success     0     {object} []sipapi.Positions
+// @Failure     1000  {object} string
+// @Failure     1001  {object} string
+// @Failure     1002  {object} string
+// @Failure     1003  {object} string
+// @Router      /channels/{id}/positions [get]
+func PositionsList(c *gin.Context) {
+	start, err := strconv.ParseInt(c.Query("start"), 10, 64)
+	if err != nil || start <= 0 {
+		m.JsonResponse(c, m.StatusParamsERR, "开始时间错误")
+		return
+	}
+	end := time.Now().Unix()
+	if v := c.Query("end"); v != "" {
+		end, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || end <= start {
+			m.JsonResponse(c, m.StatusParamsERR, "结束时间错误")
+			return
+		}
+	}
+	positions, err := sipapi.PositionList(c.Param("id"), start, end)
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, positions)
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     预置位列表
+// @Description 从设备查询通道预置位列表并同步到本地，local=1时只返回本地保存的预置位。
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id    path     string true  "通道id"
+// @Param       local query    int    false "1:只查询本地数据"
+// @Success     0     {object} []sipapi.Presets
+// @Failure     1000  {object} string
+// @Failure     1001  {object} string
+// @Failure     1002  {object} string
+// @Failure     1003  {object} string
+// @Router      /channels/{id}/presets [get]
+func PresetsList(c *gin.Context) {
+	channelid := c.Param("id")
+	if c.Query("local") == "1" {
+		presets := []sipapi.Presets{}
+		if _, err := db.FindT(db.DBClient, new(sipapi.Presets), &presets, db.M{"channelid=?": channelid}, "presetid", 0, -1, false); err != nil {
+			m.JsonResponse(c, m.StatusDBERR, err)
+			return
+		}
+		m.JsonResponse(c, m.StatusSucc, presets)
+		return
+	}
+	channel, ok := getOnlineChannel(c, channelid)
+	if !ok {
+		return
+	}
+	presets, err := sipapi.SipPresetList(channel)
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, presets)
+}
+
+// @Summary     设置预置位
+// @Description 将云台当前位置设置为预置位，已存在时覆盖，并保存预置位名称。
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true  "通道id"
+// @Param       presetid formData int    true  "预置位编号1-255"
+// @Param       name     formData string false "预置位名称"
+// @Success     0        {object} sipapi.Presets
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/presets [post]
+func PresetsSet(c *gin.Context) {
+	presetid, err := strconv.Atoi(c.PostForm("presetid"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "预置位编号错误")
+		return
+	}
+	channel, ok := getOnlineChannel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	preset, err := sipapi.SipPresetSet(channel, presetid, c.PostForm("name"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, preset)
+}
+
+// @Summary     调用预置位
+// @Description 云台转到指定预置位
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true "通道id"
+// @Param       presetid path     int    true "预置位编号1-255"
+// @Success     0        {object} string
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/presets/{presetid}/call [post]
+func PresetsCall(c *gin.Context) {
+	presetid, err := strconv.Atoi(c.Param("presetid"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "预置位编号错误")
+		return
+	}
+	channel, ok := getOnlineChannel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := sipapi.SipPresetCall(channel, presetid); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+// @Summary     删除预置位
+// @Description 删除设备预置位及本地保存的名称
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true "通道id"
+// @Param       presetid path     int    true "预置位编号1-255"
+// @Success     0        {object} string
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/presets/{presetid} [delete]
+func PresetsDelete(c *gin.Context) {
+	presetid, err := strconv.Atoi(c.Param("presetid"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "预置位编号错误")
+		return
+	}
+	channel, ok := getOnlineChannel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := sipapi.SipPresetDelete(channel, presetid); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+// @Summary     设置巡航轨迹
+// @Description 清空巡航组后按顺序加入巡航点，并设置巡航速度和预置位停留时间
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true  "通道id"
+// @Param       cruiseid path     int    true  "巡航组号0-255"
+// @Param       presets  formData string true  "巡航点预置位编号，多个用逗号分隔，例:1,2,3"
+// @Param       speed    formData int    false "巡航速度0-4095，不传不设置"
+// @Param       dwell    formData int    false "预置位停留时间，秒，0-4095，不传不设置"
+// @Success     0        {object} string
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/cruises/{cruiseid} [post]
+func CruiseSet(c *gin.Context) {
+	cruiseid, err := strconv.Atoi(c.Param("cruiseid"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "巡航组号错误")
+		return
+	}
+	cruise := sipapi.CruiseConfig{CruiseID: cruiseid}
+	for _, v := range strings.Split(c.PostForm("presets"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			m.JsonResponse(c, m.StatusParamsERR, "巡航点错误")
+			return
+		}
+		cruise.Presets = append(cruise.Presets, id)
+	}
+	if v := c.PostForm("speed"); v != "" {
+		if cruise.Speed, err = strconv.Atoi(v); err != nil {
+			m.JsonResponse(c, m.StatusParamsERR, "巡航速度错误")
+			return
+		}
+	}
+	if v := c.PostForm("dwell"); v != "" {
+		if cruise.Dwell, err = strconv.Atoi(v); err != nil {
+			m.JsonResponse(c, m.StatusParamsERR, "停留时间错误")
+			return
+		}
+	}
+	channel, ok := getOnlineChannel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := sipapi.SipCruiseSet(channel, cruise); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+// @Summary     开始巡航
+// @Description 按巡航组开始巡航
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true "通道id"
+// @Param       cruiseid path     int    true "巡航组号0-255"
+// @Success     0        {object} string
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/cruises/{cruiseid}/start [post]
+func CruiseStart(c *gin.Context) {
+	cruiseid, err := strconv.Atoi(c.Param("cruiseid"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "巡航组号错误")
+		return
+	}
+	channel, ok := getOnlineChannel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := sipapi.SipCruiseStart(channel, cruiseid); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+// @Summary     停止巡航
+// @Description 停止当前巡航
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true "通道id"
+// @Param       cruiseid path     int    true "巡航组号0-255"
+// @Success     0        {object} string
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/cruises/{cruiseid}/stop [post]
+func CruiseStop(c *gin.Context) {
+	channel, ok := getOnlineChannel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := sipapi.SipCruiseStop(channel); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+// @Summary     删除巡航组
+// @Description 删除巡航组内所有巡航点
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true "通道id"
+// @Param       cruiseid path     int    true "巡航组号0-255"
+// @Success     0        {object} string
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/cruises/{cruiseid} [delete]
+func CruiseDelete(c *gin.Context) {
+	cruiseid, err := strconv.Atoi(c.Param("cruiseid"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "巡航组号错误")
+		return
+	}
+	channel, ok := getOnlineChannel(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := sipapi.SipCruiseDelete(channel, cruiseid); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
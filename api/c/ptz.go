@@ -0,0 +1,72 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     云台控制
+// @Description 控制通道云台方向、变倍、聚焦、光圈，duration大于0时运动指定毫秒后自动停止，否则需要发送stop停止。
+// @Tags        ptz
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true  "通道id"
+// @Param       cmd      formData string true  "指令:up,down,left,right,upleft,upright,downleft,downright,zoomin,zoomout,focusnear,focusfar,irisopen,irisclose,stop"
+// @Param       speed    formData int    false "速度0-255，默认128，变倍速度取高4位"
+// @Param       duration formData int    false "运动时长，毫秒，大于0时到时自动停止"
+// @Success     0        {object} string
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /channels/{id}/ptz [post]
+func PTZ(c *gin.Context) {
+	channelid := c.Param("id")
+	cmd := c.PostForm("cmd")
+	if cmd == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "缺少控制指令")
+		return
+	}
+	speed := 128
+	if v := c.PostForm("speed"); v != "" {
+		s, err := strconv.Atoi(v)
+		if err != nil {
+			m.JsonResponse(c, m.StatusParamsERR, "速度错误")
+			return
+		}
+		speed = s
+	}
+	duration, _ := strconv.Atoi(c.PostForm("duration"))
+
+	channel, ok := getOnlineChannel(c, channelid)
+	if !ok {
+		return
+	}
+	if err := sipapi.SipPTZ(channel, cmd, speed, duration); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+// 获取在线通道，失败时直接返回错误响应
+func getOnlineChannel(c *gin.Context, channelid string) (*sipapi.Channels, bool) {
+	channel := &sipapi.Channels{ChannelID: channelid}
+	if err := db.Get(db.DBClient, channel); err != nil {
+		if db.RecordNotFound(err) {
+			m.JsonResponse(c, m.StatusParamsERR, "通道不存在")
+			return nil, false
+		}
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return nil, false
+	}
+	if channel.Status != m.DeviceStatusON {
+		m.JsonResponse(c, m.StatusParamsERR, "通道已离线")
+		return nil, false
+	}
+	return channel, true
+}
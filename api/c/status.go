@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     设备状态
+// @Description 向设备查询当前状态（在线、工作状态、编码、录像、设备时间、报警设备布防状态），最多等待10s，查询结果同时保存在设备信息的devicestatus字段。
+// @Tags        devices
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true "设备id"
+// @Success     0    {object} sipapi.DeviceStatus
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /devices/{id}/status [get]
+func DevicesStatus(c *gin.Context) {
+	device := &sipapi.Devices{DeviceID: c.Param("id")}
+	if err := db.Get(db.DBClient, device); err != nil {
+		if db.RecordNotFound(err) {
+			m.JsonResponse(c, m.StatusParamsERR, "设备不存在")
+			return
+		}
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	status, err := sipapi.SipDeviceStatus(device.DeviceID)
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, status)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -28,7 +29,7 @@ import (
 // @Router      /channels/{id}/streams [post]
 func Play(c *gin.Context) {
 	channelid := c.Param("id")
-	pm := &sipapi.Streams{S: time.Time{}, E: time.Time{}, ChannelID: channelid, Ttag: db.M{}, Ftag: db.M{}}
+	pm := &sipapi.Streams{S: time.Time{}, E: time.Time{}, ChannelID: channelid}
 	if c.PostForm("replay") == "1" {
 		// 回放，获取时间
 		pm.T = 1
@@ -82,6 +83,48 @@ func Stop(c *gin.Context) {
 	m.JsonResponse(c, m.StatusSucc, "")
 }
 
+// @Summary     回放控制
+// @Description 对回放流发送播放、暂停、停止命令，play时可以传position跳转到指定位置、传scale修改倍速，直播流不支持。返回流信息，position为控制时的播放位置。
+// @Tags        streams
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true  "流id,播放接口返回的streamid"
+// @Param       cmd      formData string true  "控制命令:play,pause,teardown"
+// @Param       position formData number false "play时跳转位置，相对回放开始时间的秒数"
+// @Param       scale    formData number false "play时播放倍速，如0.25,0.5,1,2,4"
+// @Success     0        {object} sipapi.Streams
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /streams/{id}/control [post]
+func PlaybackControl(c *gin.Context) {
+	cmd := strings.ToUpper(c.PostForm("cmd"))
+	position, scale := float64(-1), float64(0)
+	if v := c.PostForm("position"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 {
+			m.JsonResponse(c, m.StatusParamsERR, "播放位置错误")
+			return
+		}
+		position = p
+	}
+	if v := c.PostForm("scale"); v != "" {
+		s, err := strconv.ParseFloat(v, 64)
+		if err != nil || s <= 0 {
+			m.JsonResponse(c, m.StatusParamsERR, "播放倍速错误")
+			return
+		}
+		scale = s
+	}
+	stream, err := sipapi.SipPlaybackControl(c.Param("id"), cmd, position, scale)
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, stream)
+}
+
 type StreamsListResponse struct {
 	Total int64
 	List  []sipapi.Streams
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     开启设备订阅
+// @Description 保存订阅配置并向设备发送SUBSCRIBE，订阅到期前自动刷新，设备离线时在设备上线后自动订阅。
+// @Tags        subscriptions
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id            path     string true  "设备id"
+// @Param       event         path     string true  "订阅类型:alarm,catalog,mobileposition"
+// @Param       expires       formData int    false "订阅有效期，秒，默认3600"
+// @Param       startpriority formData int    false "报警起始级别，0为全部"
+// @Param       endpriority   formData int    false "报警终止级别，0为全部"
+// @Param       method        formData int    false "报警方式，0为全部"
+// @Param       interval      formData int    false "移动设备位置上报间隔，秒，默认5"
+// @Success     0             {object} sipapi.Subscriptions
+// @Failure     1000          {object} string
+// @Failure     1001          {object} string
+// @Failure     1002          {object} string
+// @Failure     1003          {object} string
+// @Router      /devices/{id}/subscriptions/{event} [post]
+func SubscriptionsCreate(c *gin.Context) {
+	device := &sipapi.Devices{DeviceID: c.Param("id")}
+	if err := db.Get(db.DBClient, device); err != nil {
+		if db.RecordNotFound(err) {
+			m.JsonResponse(c, m.StatusParamsERR, "设备不存在")
+			return
+		}
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	expires, _ := strconv.Atoi(c.PostForm("expires"))
+	params := sipapi.SubscribeParams{}
+	params.StartPriority, _ = strconv.Atoi(c.PostForm("startpriority"))
+	params.EndPriority, _ = strconv.Atoi(c.PostForm("endpriority"))
+	params.Method, _ = strconv.Atoi(c.PostForm("method"))
+	params.Interval, _ = strconv.Atoi(c.PostForm("interval"))
+	sub, err := sipapi.SipSubscribe(device.DeviceID, c.Param("event"), expires, params)
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, sub)
+}
+
+// @Summary     取消设备订阅
+// @Description 设备在线时发送有效期为0的SUBSCRIBE取消订阅，并删除订阅配置
+// @Tags        subscriptions
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id    path     string true "设备id"
+// @Param       event path     string true "订阅类型:alarm,catalog,mobileposition"
+// @Success     0     {object} string
+// @Failure     1000  {object} string
+// @Failure     1001  {object} string
+// @Failure     1002  {object} string
+// @Failure     1003  {object} string
+// @Router      /devices/{id}/subscriptions/{event} [delete]
+func SubscriptionsDelete(c *gin.Context) {
+	if err := sipapi.SipUnsubscribe(c.Param("id"), c.Param("event")); err != nil {
+		if db.RecordNotFound(err) {
+			m.JsonResponse(c, m.StatusParamsERR, "订阅不存在")
+			return
+		}
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
+
+type SubscriptionsListResponse struct {
+	Total int64
+	List  []sipapi.Subscriptions
+}
+
+// @Summary     订阅列表接口
+// @Description 可以根据查询条件查询设备订阅列表
+// @Tags        subscriptions
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       limit   query    integer false "条数(0-100) 默认20"
+// @Param       skip    query    integer false "间隔 默认0"
+// @Param       sort    query    string  false "排序,例:-key,根据key倒序,key,根据key正序"
+// @Param       filters query    string  false "查询条件,使用规则详情请看帮助"
+// @Success     0       {object} SubscriptionsListResponse
+// @Failure     1000    {object} string
+// @Failure     1001    {object} string
+// @Failure     1002    {object} string
+// @Failure     1003    {object} string
+// @Router      /subscriptions [get]
+func SubscriptionsList(c *gin.Context) {
+	limit := m.GetLimit(c)
+	skip := m.GetSkip(c)
+	sort := m.GetSort(c)
+	subs := []sipapi.Subscriptions{}
+	total, err := db.FindWithJson(db.DBClient, new(sipapi.Subscriptions), &subs, c.Query("filters"), sort, skip, limit, true)
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, SubscriptionsListResponse{
+		Total: total,
+		List:  subs,
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     开始语音广播或对讲
+// @Description 创建通道的语音会话，浏览器向返回的推流地址推送音频(webrtc/rtmp/rtsp)后平台向设备发送语音广播通知，设备发起邀请后协商G.711音频并转发。对讲模式下同时返回设备音频的播放地址。等待推流或设备邀请超过30秒自动结束。
+// @Tags        talk
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true  "通道id"
+// @Param       mode formData string false "broadcast 广播(默认) talk 对讲"
+// @Success     0    {object} sipapi.TalkSession
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /channels/{id}/talk [post]
+func TalkStart(c *gin.Context) {
+	session, err := sipapi.SipTalkStart(c.Param("id"), c.PostForm("mode"))
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, session)
+}
+
+// @Summary     语音会话状态
+// @Description 返回通道当前的语音会话，status: waiting 等待推流 inviting 等待设备邀请 talking 通话中
+// @Tags        talk
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true "通道id"
+// @Success     0    {object} sipapi.TalkSession
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /channels/{id}/talk [get]
+func TalkInfo(c *gin.Context) {
+	session, ok := sipapi.GetTalkSession(c.Param("id"))
+	if !ok {
+		m.JsonResponse(c, m.StatusParamsERR, "语音会话不存在")
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, session)
+}
+
+// @Summary     结束语音广播或对讲
+// @Description 向设备发送BYE，停止转发音频并关闭浏览器推送的语音流
+// @Tags        talk
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id   path     string true "通道id"
+// @Success     0    {object} string
+// @Failure     1000 {object} string
+// @Failure     1001 {object} string
+// @Failure     1002 {object} string
+// @Failure     1003 {object} string
+// @Router      /channels/{id}/talk [delete]
+func TalkStop(c *gin.Context) {
+	if err := sipapi.SipTalkStop(c.Param("id")); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "")
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/panjjo/gosip/m"
+	sipapi "github.com/panjjo/gosip/sip"
+)
+
+// @Summary     组织树
+// @Description 返回所有设备的组织树（设备-行政区划/业务分组/虚拟组织-通道），每个节点带通道总数和在线数。节点较多时使用lazy=1按parentid逐级加载。
+// @Tags        tree
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       parentid query    string false "父节点编码，为空时从设备开始"
+// @Param       lazy     query    int    false "1:只返回下一级节点"
+// @Success     0        {object} []sipapi.TreeNode
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /tree [get]
+func Tree(c *gin.Context) {
+	tree, err := sipapi.Tree("", c.Query("parentid"), c.Query("lazy") == "1")
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, tree)
+}
+
+// @Summary     设备组织树
+// @Description 返回设备的组织树，每个节点带通道总数和在线数。节点较多时使用lazy=1按parentid逐级加载。
+// @Tags        tree
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       id       path     string true  "设备id"
+// @Param       parentid query    string false "父节点编码，为空时从设备开始"
+// @Param       lazy     query    int    false "1:只返回下一级节点"
+// @Success     0        {object} []sipapi.TreeNode
+// @Failure     1000     {object} string
+// @Failure     1001     {object} string
+// @Failure     1002     {object} string
+// @Failure     1003     {object} string
+// @Router      /devices/{id}/tree [get]
+func DevicesTree(c *gin.Context) {
+	tree, err := sipapi.Tree(c.Param("id"), c.Query("parentid"), c.Query("lazy") == "1")
+	if err != nil {
+		m.JsonResponse(c, m.StatusDBERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, tree)
+}
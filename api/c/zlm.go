@@ -90,6 +90,20 @@ func zlmStreamChanged(c *gin.Context) {
 		})
 		return
 	}
+	if req.APP == sipapi.TalkApp {
+		// 浏览器推送的语音流
+		if req.Schema == "rtmp" {
+			if req.Regist {
+				sipapi.TalkStreamRegist(req.Stream)
+			} else {
+				sipapi.TalkStreamUnregist(req.Stream)
+			}
+		}
+		c.JSON(http.StatusOK, map[string]any{
+			"code": 0,
+			"msg":  "success"})
+		return
+	}
 	ssrc := req.Stream
 	if req.Regist {
 		if req.Schema == "rtmp" {
@@ -102,6 +116,10 @@ func zlmStreamChanged(c *gin.Context) {
 				sipapi.StreamList.Response.Store(ssrc, params)
 				// 接收到流注册后进行视频流编码分析，分析出此设备对应的编码格式并保存或更新
 				sipapi.SyncDevicesCodec(ssrc, params.DeviceID)
+				if params.T == 2 {
+					// 下载流开始录制
+					sipapi.StartDownloadRecord(params)
+				}
 			} else {
 				// ssrc不存在，关闭流
 				sipapi.SipStopPlay(ssrc)
@@ -159,6 +177,8 @@ func zlmRecordMp4(c *gin.Context) {
 		sipapi.RecordList.Stop(req.Stream)
 		item.Down(req.URL)
 		item.Resp(fmt.Sprintf("%s/%s", m.MConfig.Media.HTTP, req.URL))
+	} else if sipapi.DownloadRecorded(req.Stream, req.URL) {
+		logrus.Infoln("download recorded", req.Stream, req.URL)
 	}
 	c.JSON(http.StatusOK, map[string]any{
 		"code": 0,
@@ -246,6 +266,22 @@ func zlmStreamNoneReader(c *gin.Context) {
 		})
 		return
 	}
+	if req.APP == sipapi.TalkApp && sipapi.TalkStreamActive(req.Stream) {
+		// 语音会话未结束，无人观看也不关闭
+		c.JSON(http.StatusOK, map[string]any{
+			"code":  0,
+			"close": false,
+		})
+		return
+	}
+	if d, ok := sipapi.StreamList.Response.Load(req.Stream); ok && d.(*sipapi.Streams).Downloading() {
+		// 下载未完成，无人观看也不关闭
+		c.JSON(http.StatusOK, map[string]any{
+			"code":  0,
+			"close": false,
+		})
+		return
+	}
 	sipapi.SipStopPlay(req.Stream)
 	c.JSON(http.StatusOK, map[string]any{
 		"code":  0,
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	api "github.com/panjjo/gosip/api/c"
 	"github.com/panjjo/gosip/api/middleware"
+	sipapi "github.com/panjjo/gosip/sip"
 )
 
 func Init(r *gin.Engine) {
@@ -17,6 +18,12 @@ func Init(r *gin.Engine) {
 		r.POST("/devices", api.DevicesCreate)
 		r.POST("/devices/:id", api.DevicesUpdate)
 		r.DELETE("/devices/:id", api.DevicesDelete)
+		r.GET("/devices/:id/tree", api.DevicesTree)
+		r.GET("/devices/:id/status", api.DevicesStatus)
+		r.GET("/devices/:id/config", api.DevicesConfig)
+		r.POST("/devices/:id/config", api.DevicesConfigUpdate)
+		r.POST("/devices/config", api.DevicesConfigBatch)
+		r.GET("/tree", api.Tree)
 
 	}
 	// 通道类接口
@@ -31,11 +38,71 @@ func Init(r *gin.Engine) {
 		r.GET("/streams", api.StreamsList)
 		r.POST("/channels/:id/streams", api.Play)
 		r.DELETE("/streams/:id", api.Stop)
+		r.POST("/streams/:id/control", api.PlaybackControl)
+		r.POST("/channels/:id/download", api.Download)
+		r.GET("/streams/:id/download", api.DownloadInfo)
 	}
 	// 录像类
 	{
 		r.GET("/channels/:id/records", api.RecordsList)
 	}
+	// 云台控制
+	{
+		r.POST("/channels/:id/ptz", api.PTZ)
+		r.GET("/channels/:id/presets", api.PresetsList)
+		r.POST("/channels/:id/presets", api.PresetsSet)
+		r.POST("/channels/:id/presets/:presetid/call", api.PresetsCall)
+		r.DELETE("/channels/:id/presets/:presetid", api.PresetsDelete)
+		r.POST("/channels/:id/cruises/:cruiseid", api.CruiseSet)
+		r.POST("/channels/:id/cruises/:cruiseid/start", api.CruiseStart)
+		r.POST("/channels/:id/cruises/:cruiseid/stop", api.CruiseStop)
+		r.DELETE("/channels/:id/cruises/:cruiseid", api.CruiseDelete)
+	}
+	// 设备控制
+	{
+		r.POST("/devices/:id/reboot", api.DevicesControl(sipapi.ControlReboot))
+		r.POST("/devices/:id/record/start", api.DevicesControl(sipapi.ControlRecordStart))
+		r.POST("/devices/:id/record/stop", api.DevicesControl(sipapi.ControlRecordStop))
+		r.POST("/devices/:id/guard/set", api.DevicesControl(sipapi.ControlGuardSet))
+		r.POST("/devices/:id/guard/reset", api.DevicesControl(sipapi.ControlGuardReset))
+		r.POST("/devices/:id/alarm/reset", api.DevicesControl(sipapi.ControlAlarmReset))
+		r.POST("/channels/:id/reboot", api.ChannelsControl(sipapi.ControlReboot))
+		r.POST("/channels/:id/record/start", api.ChannelsControl(sipapi.ControlRecordStart))
+		r.POST("/channels/:id/record/stop", api.ChannelsControl(sipapi.ControlRecordStop))
+		r.POST("/channels/:id/guard/set", api.ChannelsControl(sipapi.ControlGuardSet))
+		r.POST("/channels/:id/guard/reset", api.ChannelsControl(sipapi.ControlGuardReset))
+		r.POST("/channels/:id/alarm/reset", api.ChannelsControl(sipapi.ControlAlarmReset))
+	}
+	// 报警
+	{
+		r.GET("/alarms", api.AlarmsList)
+	}
+	// 订阅
+	{
+		r.GET("/subscriptions", api.SubscriptionsList)
+		r.POST("/devices/:id/subscriptions/:event", api.SubscriptionsCreate)
+		r.DELETE("/devices/:id/subscriptions/:event", api.SubscriptionsDelete)
+	}
+	// 移动设备位置
+	{
+		r.GET("/channels/:id/positions", api.PositionsList)
+	}
+	// 语音广播和对讲
+	{
+		r.GET("/channels/:id/talk", api.TalkInfo)
+		r.POST("/channels/:id/talk", api.TalkStart)
+		r.DELETE("/channels/:id/talk", api.TalkStop)
+	}
+	// 级联上级平台
+	{
+		r.GET("/platforms", api.PlatformsList)
+		r.POST("/platforms", api.PlatformsCreate)
+		r.POST("/platforms/:id", api.PlatformsUpdate)
+		r.DELETE("/platforms/:id", api.PlatformsDelete)
+		r.GET("/platforms/:id/channels", api.PlatformChannelsList)
+		r.POST("/platforms/:id/channels", api.PlatformChannelsAdd)
+		r.DELETE("/platforms/:id/channels", api.PlatformChannelsDelete)
+	}
 	// zlm webhook
 	{
 		r.POST("/zlm/webhook/:method", api.ZLMWebHook)
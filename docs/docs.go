@@ -25,6 +25,79 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/alarms": {
+            "get": {
+                "description": "可以根据查询条件查询设备上报的报警记录",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alarms"
+                ],
+                "summary": "报警列表接口",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "条数(0-100) 默认20",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "间隔 默认0",
+                        "name": "skip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序,例:-key,根据key倒序,key,根据key正序",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "查询条件,使用规则详情请看帮助",
+                        "name": "filters",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/api.AlarmsListResponse"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/channels": {
             "get": {
                 "description": "可以根据查询条件查询通道列表",
@@ -226,9 +299,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/channels/{id}/records": {
-            "get": {
-                "description": "用来获取通道设备存储的可回放时间段列表，注意控制时间跨度，跨度越大，数据量越多，返回越慢，甚至会超时（最多10s）。",
+        "/channels/{id}/alarm/reset": {
+            "post": {
+                "description": "对通道发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -236,9 +309,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "records"
+                    "control"
                 ],
-                "summary": "回放文件时间列表",
+                "summary": "通道控制",
                 "parameters": [
                     {
                         "type": "string",
@@ -246,27 +319,13 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "开始时间，时间戳",
-                        "name": "start",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "结束时间，时间戳",
-                        "name": "end",
-                        "in": "query",
-                        "required": true
                     }
                 ],
                 "responses": {
                     "0": {
                         "description": "",
                         "schema": {
-                            "$ref": "#/definitions/sipapi.Records"
+                            "type": "string"
                         }
                     },
                     "1000": {
@@ -296,9 +355,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/channels/{id}/streams": {
+        "/channels/{id}/cruises/{cruiseid}": {
             "post": {
-                "description": "直播一个通道最多存在一个流，回放每请求一次生成一个流",
+                "description": "清空巡航组后按顺序加入巡航点，并设置巡航速度和预置位停留时间",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -306,9 +365,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "streams"
+                    "ptz"
                 ],
-                "summary": "监控播放（直播/回放）",
+                "summary": "设置巡航轨迹",
                 "parameters": [
                     {
                         "type": "string",
@@ -319,20 +378,28 @@ const docTemplate = `{
                     },
                     {
                         "type": "integer",
-                        "description": "是否回放，1回放，0直播，默认0",
-                        "name": "replay",
-                        "in": "formData"
+                        "description": "巡航组号0-255",
+                        "name": "cruiseid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "巡航点预置位编号，多个用逗号分隔，例:1,2,3",
+                        "name": "presets",
+                        "in": "formData",
+                        "required": true
                     },
                     {
                         "type": "integer",
-                        "description": "回放开始时间，时间戳，replay=1时必传",
-                        "name": "start",
+                        "description": "巡航速度0-4095，不传不设置",
+                        "name": "speed",
                         "in": "formData"
                     },
                     {
                         "type": "integer",
-                        "description": "回放结束时间，时间戳，replay=1时必传",
-                        "name": "end",
+                        "description": "预置位停留时间，秒，0-4095，不传不设置",
+                        "name": "dwell",
                         "in": "formData"
                     }
                 ],
@@ -340,7 +407,7 @@ const docTemplate = `{
                     "0": {
                         "description": "",
                         "schema": {
-                            "$ref": "#/definitions/sipapi.Streams"
+                            "type": "string"
                         }
                     },
                     "1000": {
@@ -368,11 +435,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/devices": {
-            "get": {
-                "description": "可以根据查询条件查询设备列表",
+            },
+            "delete": {
+                "description": "删除巡航组内所有巡航点",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -380,40 +445,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "ptz"
                 ],
-                "summary": "设备列表接口",
+                "summary": "删除巡航组",
                 "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "条数(0-100) 默认20",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "间隔 默认0",
-                        "name": "skip",
-                        "in": "query"
-                    },
                     {
                         "type": "string",
-                        "description": "排序,例:-key,根据key倒序,key,根据key正序",
-                        "name": "sort",
-                        "in": "query"
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "查询条件,使用规则详情请看帮助",
-                        "name": "filters",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "巡航组号0-255",
+                        "name": "cruiseid",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "0": {
                         "description": "",
                         "schema": {
-                            "$ref": "#/definitions/api.DevicesListResponse"
+                            "type": "string"
                         }
                     },
                     "1000": {
@@ -441,9 +496,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/channels/{id}/cruises/{cruiseid}/start": {
             "post": {
-                "description": "通过此接口新增一个设备，获取设备id",
+                "description": "按巡航组开始巡航",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -451,22 +508,22 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "ptz"
                 ],
-                "summary": "设备新增接口",
+                "summary": "开始巡航",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "设备密码(GB28181认证密码)",
-                        "name": "pwd",
-                        "in": "formData",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "设备名称",
-                        "name": "name",
-                        "in": "formData",
+                        "type": "integer",
+                        "description": "巡航组号0-255",
+                        "name": "cruiseid",
+                        "in": "path",
                         "required": true
                     }
                 ],
@@ -474,7 +531,7 @@ const docTemplate = `{
                     "0": {
                         "description": "",
                         "schema": {
-                            "$ref": "#/definitions/sipapi.Devices"
+                            "type": "string"
                         }
                     },
                     "1000": {
@@ -504,9 +561,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/devices/{id}": {
+        "/channels/{id}/cruises/{cruiseid}/stop": {
             "post": {
-                "description": "调整设备信息",
+                "description": "停止当前巡航",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -514,35 +571,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "ptz"
                 ],
-                "summary": "设备修改接口",
+                "summary": "停止巡航",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "设备id",
+                        "description": "通道id",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "设备密码(GB28181认证密码)",
-                        "name": "pwd",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "设备名称",
-                        "name": "name",
-                        "in": "formData"
+                        "type": "integer",
+                        "description": "巡航组号0-255",
+                        "name": "cruiseid",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "0": {
                         "description": "",
                         "schema": {
-                            "$ref": "#/definitions/sipapi.Devices"
+                            "type": "string"
                         }
                     },
                     "1000": {
@@ -570,9 +622,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "删除设备信息",
+            }
+        },
+        "/channels/{id}/download": {
+            "post": {
+                "description": "设备按倍速发送历史视频，收到流后录制为mp4，设备通知发送结束后停止录制并关闭流。通过下载进度接口查询进度和文件地址，配置downloads_done时下载完成后发送通知。",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -580,23 +634,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "devices"
+                    "downloads"
                 ],
-                "summary": "设备删除接口",
+                "summary": "历史视频下载",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "设备id",
+                        "description": "通道id",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "开始时间，时间戳",
+                        "name": "start",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "结束时间，时间戳",
+                        "name": "end",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "下载倍速，默认4",
+                        "name": "speed",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "0": {
                         "description": "",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/sipapi.Streams"
                         }
                     },
                     "1000": {
@@ -626,9 +700,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/devices/{id}/channels": {
+        "/channels/{id}/guard/reset": {
             "post": {
-                "description": "通过此接口在设备下新增通道，获取通道id",
+                "description": "对通道发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -636,41 +710,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "channels"
+                    "control"
                 ],
-                "summary": "通道新增接口",
+                "summary": "通道控制",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "设备id",
+                        "description": "通道id",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "通道备注",
-                        "name": "memo",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "播放类型，pull 媒体服务器拉流，push 摄像头推流,默认push",
-                        "name": "streamtype",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "静态拉流地址，streamtype=pull 时生效。",
-                        "name": "url",
-                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "0": {
                         "description": "",
                         "schema": {
-                            "$ref": "#/definitions/sipapi.Channels"
+                            "type": "string"
                         }
                     },
                     "1000": {
@@ -700,9 +756,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/streams": {
-            "get": {
-                "description": "可以根据查询条件查询视频流列表",
+        "/channels/{id}/guard/set": {
+            "post": {
+                "description": "对通道发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -710,32 +766,84 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "streams"
+                    "control"
                 ],
-                "summary": "视频流列表接口",
+                "summary": "通道控制",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "条数(0-100) 默认20",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "间隔 默认0",
-                        "name": "skip",
-                        "in": "query"
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/positions": {
+            "get": {
+                "description": "查询通道时间段内上报的位置轨迹，按时间正序。需要先开启设备的mobileposition订阅，或设备主动上报。通道最新位置见通道信息的longitude,latitude,positionat字段。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "positions"
+                ],
+                "summary": "移动设备位置轨迹",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "排序,例:-key,根据key倒序,key,根据key正序",
-                        "name": "sort",
-                        "in": "query"
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "查询条件,使用规则详情请看帮助",
-                        "name": "filters",
+                        "type": "integer",
+                        "description": "开始时间，时间戳",
+                        "name": "start",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "结束时间，时间戳，默认当前时间",
+                        "name": "end",
                         "in": "query"
                     }
                 ],
@@ -743,7 +851,10 @@ const docTemplate = `{
                     "0": {
                         "description": "",
                         "schema": {
-                            "$ref": "#/definitions/api.StreamsListResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sipapi.Positions"
+                            }
                         }
                     },
                     "1000": {
@@ -773,9 +884,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/streams/{id}": {
-            "delete": {
-                "description": "无人观看5分钟自动关闭，直播流无需调用此接口。",
+        "/channels/{id}/presets": {
+            "get": {
+                "description": "从设备查询通道预置位列表并同步到本地，local=1时只返回本地保存的预置位。",
                 "consumes": [
                     "application/x-www-form-urlencoded"
                 ],
@@ -783,23 +894,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "streams"
+                    "ptz"
                 ],
-                "summary": "停止播放（直播/回放）",
+                "summary": "预置位列表",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "流id,播放接口返回的streamid",
+                        "description": "通道id",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "1:只查询本地数据",
+                        "name": "local",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "0": {
                         "description": "",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sipapi.Presets"
+                            }
                         }
                     },
                     "1000": {
@@ -827,93 +947,3118 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "api.ChannelsListResponse": {
-            "type": "object",
-            "properties": {
-                "list": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/sipapi.Channels"
+            },
+            "post": {
+                "description": "将云台当前位置设置为预置位，已存在时覆盖，并保存预置位名称。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ptz"
+                ],
+                "summary": "设置预置位",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "预置位编号1-255",
+                        "name": "presetid",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "预置位名称",
+                        "name": "name",
+                        "in": "formData"
                     }
-                },
-                "total": {
-                    "type": "integer"
-                }
-            }
-        },
-        "api.DevicesListResponse": {
-            "type": "object",
-            "properties": {
-                "list": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/sipapi.Devices"
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Presets"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
-                },
-                "total": {
-                    "type": "integer"
                 }
             }
         },
-        "api.StreamsListResponse": {
-            "type": "object",
-            "properties": {
-                "list": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/sipapi.Streams"
+        "/channels/{id}/presets/{presetid}": {
+            "delete": {
+                "description": "删除设备预置位及本地保存的名称",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ptz"
+                ],
+                "summary": "删除预置位",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "预置位编号1-255",
+                        "name": "presetid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
-                },
-                "total": {
-                    "type": "integer"
                 }
             }
         },
-        "m.SysInfo": {
-            "type": "object",
-            "properties": {
-                "addtime": {
-                    "type": "integer"
-                },
-                "cid": {
-                    "description": "CID 通道id固定头部",
-                    "type": "string"
-                },
-                "cnum": {
-                    "description": "CNUM 当前通道数",
-                    "type": "integer"
-                },
-                "did": {
-                    "description": "DID 设备id固定头部",
-                    "type": "string"
-                },
-                "dnum": {
-                    "description": "DNUM 当前设备数",
-                    "type": "integer"
-                },
+        "/channels/{id}/presets/{presetid}/call": {
+            "post": {
+                "description": "云台转到指定预置位",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ptz"
+                ],
+                "summary": "调用预置位",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "预置位编号1-255",
+                        "name": "presetid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/ptz": {
+            "post": {
+                "description": "控制通道云台方向、变倍、聚焦、光圈，duration大于0时运动指定毫秒后自动停止，否则需要发送stop停止。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ptz"
+                ],
+                "summary": "云台控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "指令:up,down,left,right,upleft,upright,downleft,downright,zoomin,zoomout,focusnear,focusfar,irisopen,irisclose,stop",
+                        "name": "cmd",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "速度0-255，默认128，变倍速度取高4位",
+                        "name": "speed",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "运动时长，毫秒，大于0时到时自动停止",
+                        "name": "duration",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/reboot": {
+            "post": {
+                "description": "对通道发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "通道控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/record/start": {
+            "post": {
+                "description": "对通道发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "通道控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/record/stop": {
+            "post": {
+                "description": "对通道发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "通道控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/records": {
+            "get": {
+                "description": "用来获取通道设备存储的可回放时间段列表，注意控制时间跨度，跨度越大，数据量越多，返回越慢，甚至会超时（最多10s）。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "records"
+                ],
+                "summary": "回放文件时间列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "开始时间，时间戳",
+                        "name": "start",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "结束时间，时间戳",
+                        "name": "end",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Records"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/streams": {
+            "post": {
+                "description": "直播一个通道最多存在一个流，回放每请求一次生成一个流",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "streams"
+                ],
+                "summary": "监控播放（直播/回放）",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "是否回放，1回放，0直播，默认0",
+                        "name": "replay",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "回放开始时间，时间戳，replay=1时必传",
+                        "name": "start",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "回放结束时间，时间戳，replay=1时必传",
+                        "name": "end",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Streams"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{id}/talk": {
+            "get": {
+                "description": "返回通道当前的语音会话，status: waiting 等待推流 inviting 等待设备邀请 talking 通话中",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "talk"
+                ],
+                "summary": "语音会话状态",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.TalkSession"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "创建通道的语音会话，浏览器向返回的推流地址推送音频(webrtc/rtmp/rtsp)后平台向设备发送语音广播通知，设备发起邀请后协商G.711音频并转发。对讲模式下同时返回设备音频的播放地址。等待推流或设备邀请超过30秒自动结束。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "talk"
+                ],
+                "summary": "开始语音广播或对讲",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "broadcast 广播(默认) talk 对讲",
+                        "name": "mode",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.TalkSession"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "向设备发送BYE，停止转发音频并关闭浏览器推送的语音流",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "talk"
+                ],
+                "summary": "结束语音广播或对讲",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "通道id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices": {
+            "get": {
+                "description": "可以根据查询条件查询设备列表",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "设备列表接口",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "条数(0-100) 默认20",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "间隔 默认0",
+                        "name": "skip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序,例:-key,根据key倒序,key,根据key正序",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "查询条件,使用规则详情请看帮助",
+                        "name": "filters",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/api.DevicesListResponse"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "通过此接口新增一个设备，获取设备id",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "设备新增接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备密码(GB28181认证密码)",
+                        "name": "pwd",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "设备名称",
+                        "name": "name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "接受的摘要认证算法,多个用逗号分隔,可选MD5,MD5-sess,SHA-256,SHA-256-sess,为空使用全局配置",
+                        "name": "algorithms",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "自动创建目录中未注册的通道,0使用全局配置,1创建,-1不创建",
+                        "name": "autocreate",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Devices"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/config": {
+            "post": {
+                "description": "并发修改多个设备的基本参数，只下发不为空的参数，每个设备最多等待10s，按deviceids顺序返回每个设备的应答结果或失败原因。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "批量设备配置",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id，多个用,分隔，all 表示所有在线设备",
+                        "name": "deviceids",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "设备名称",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "注册过期时间，秒",
+                        "name": "expiration",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "心跳间隔时间，秒",
+                        "name": "heartbeatinterval",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "心跳超时次数",
+                        "name": "heartbeatcount",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sipapi.DeviceConfigResult"
+                            }
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}": {
+            "post": {
+                "description": "调整设备信息",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "设备修改接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "设备密码(GB28181认证密码)",
+                        "name": "pwd",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "设备名称",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "接受的摘要认证算法,多个用逗号分隔,可选MD5,MD5-sess,SHA-256,SHA-256-sess,传空值时使用全局配置",
+                        "name": "algorithms",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "自动创建目录中未注册的通道,0使用全局配置,1创建,-1不创建",
+                        "name": "autocreate",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Devices"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "删除设备信息",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "设备删除接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/alarm/reset": {
+            "post": {
+                "description": "对设备发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "设备控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/channels": {
+            "post": {
+                "description": "通过此接口在设备下新增通道，获取通道id",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "channels"
+                ],
+                "summary": "通道新增接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "通道备注",
+                        "name": "memo",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "播放类型，pull 媒体服务器拉流，push 摄像头推流,默认push",
+                        "name": "streamtype",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "静态拉流地址，streamtype=pull 时生效。",
+                        "name": "url",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Channels"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/config": {
+            "get": {
+                "description": "向设备查询配置，最多等待10s，只返回查询的配置类型。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "设备配置查询",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "配置类型:BasicParam,VideoParamOpt,SVACEncodeConfig,SnapShot，多个用/分隔，默认BasicParam",
+                        "name": "configtype",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.DeviceConfigs"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "修改设备基本参数，只下发不为空的参数，返回设备应答结果(OK/ERROR)。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "设备配置",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "设备名称",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "注册过期时间，秒",
+                        "name": "expiration",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "心跳间隔时间，秒",
+                        "name": "heartbeatinterval",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "心跳超时次数",
+                        "name": "heartbeatcount",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/guard/reset": {
+            "post": {
+                "description": "对设备发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "设备控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/guard/set": {
+            "post": {
+                "description": "对设备发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "设备控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/reboot": {
+            "post": {
+                "description": "对设备发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "设备控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/record/start": {
+            "post": {
+                "description": "对设备发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "设备控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/record/stop": {
+            "post": {
+                "description": "对设备发送远程启动、手动录像、布撤防、报警复位命令，返回设备控制应答结果(OK/ERROR)，远程启动设备不应答，发送成功即返回OK。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "control"
+                ],
+                "summary": "设备控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/status": {
+            "get": {
+                "description": "向设备查询当前状态（在线、工作状态、编码、录像、设备时间、报警设备布防状态），最多等待10s，查询结果同时保存在设备信息的devicestatus字段。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "设备状态",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.DeviceStatus"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/subscriptions/{event}": {
+            "post": {
+                "description": "保存订阅配置并向设备发送SUBSCRIBE，订阅到期前自动刷新，设备离线时在设备上线后自动订阅。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "开启设备订阅",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "订阅类型:alarm,catalog,mobileposition",
+                        "name": "event",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "订阅有效期，秒，默认3600",
+                        "name": "expires",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "报警起始级别，0为全部",
+                        "name": "startpriority",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "报警终止级别，0为全部",
+                        "name": "endpriority",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "报警方式，0为全部",
+                        "name": "method",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "移动设备位置上报间隔，秒，默认5",
+                        "name": "interval",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Subscriptions"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "设备在线时发送有效期为0的SUBSCRIBE取消订阅，并删除订阅配置",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "取消设备订阅",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "订阅类型:alarm,catalog,mobileposition",
+                        "name": "event",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/devices/{id}/tree": {
+            "get": {
+                "description": "返回设备的组织树，每个节点带通道总数和在线数。节点较多时使用lazy=1按parentid逐级加载。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tree"
+                ],
+                "summary": "设备组织树",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "设备id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "父节点编码，为空时从设备开始",
+                        "name": "parentid",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "1:只返回下一级节点",
+                        "name": "lazy",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sipapi.TreeNode"
+                            }
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/platforms": {
+            "get": {
+                "description": "可以根据查询条件查询上级平台列表，包含注册状态",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platforms"
+                ],
+                "summary": "上级平台列表接口",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "条数(0-100) 默认20",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "间隔 默认0",
+                        "name": "skip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序,例:-key,根据key倒序,key,根据key正序",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "查询条件,使用规则详情请看帮助",
+                        "name": "filters",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/api.PlatformsListResponse"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "新增一个上级平台，启用后本平台作为下级平台定时向上级注册并发送心跳",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platforms"
+                ],
+                "summary": "上级平台新增接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上级平台编号",
+                        "name": "platformid",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "上级平台域",
+                        "name": "region",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "上级平台信令地址,ip:port",
+                        "name": "addr",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "上级平台名称",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "信令传输协议,UDP或TCP,默认UDP,不支持TLS",
+                        "name": "transport",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "认证用户名,为空使用本平台编号",
+                        "name": "username",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "认证密码",
+                        "name": "pwd",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "注册有效期,秒,默认3600",
+                        "name": "expires",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "心跳间隔,秒,默认60",
+                        "name": "keepalive",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "是否启用,1启用,0停用,默认1",
+                        "name": "enable",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Platforms"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/platforms/{id}": {
+            "post": {
+                "description": "修改后先向上级注销，启用的平台在下次检查时使用新配置重新注册",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platforms"
+                ],
+                "summary": "上级平台修改接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上级平台编号",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "上级平台域",
+                        "name": "region",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "上级平台信令地址,ip:port",
+                        "name": "addr",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "上级平台名称",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "信令传输协议,UDP或TCP,不支持TLS",
+                        "name": "transport",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "认证用户名,为空使用本平台编号",
+                        "name": "username",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "认证密码",
+                        "name": "pwd",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "注册有效期,秒",
+                        "name": "expires",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "心跳间隔,秒",
+                        "name": "keepalive",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "是否启用,1启用,0停用",
+                        "name": "enable",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Platforms"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "向上级注销并删除上级平台，同时删除共享给该平台的通道",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platforms"
+                ],
+                "summary": "上级平台删除接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上级平台编号",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/platforms/{id}/channels": {
+            "get": {
+                "description": "共享给上级平台的通道，上级平台查询目录时返回这些通道",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platforms"
+                ],
+                "summary": "上级平台共享通道列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上级平台编号",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "条数(0-100) 默认20",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "间隔 默认0",
+                        "name": "skip",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/api.PlatformChannelsListResponse"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "将通道共享给上级平台，已共享的通道忽略",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platforms"
+                ],
+                "summary": "上级平台共享通道添加",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上级平台编号",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "通道id,多个用逗号分隔",
+                        "name": "channelids",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "取消通道共享",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "platforms"
+                ],
+                "summary": "上级平台共享通道删除",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "上级平台编号",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "通道id,多个用逗号分隔",
+                        "name": "channelids",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/streams": {
+            "get": {
+                "description": "可以根据查询条件查询视频流列表",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "streams"
+                ],
+                "summary": "视频流列表接口",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "条数(0-100) 默认20",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "间隔 默认0",
+                        "name": "skip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序,例:-key,根据key倒序,key,根据key正序",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "查询条件,使用规则详情请看帮助",
+                        "name": "filters",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/api.StreamsListResponse"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/streams/{id}": {
+            "delete": {
+                "description": "无人观看5分钟自动关闭，直播流无需调用此接口。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "streams"
+                ],
+                "summary": "停止播放（直播/回放）",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "流id,播放接口返回的streamid",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/streams/{id}/control": {
+            "post": {
+                "description": "对回放流发送播放、暂停、停止命令，play时可以传position跳转到指定位置、传scale修改倍速，直播流不支持。返回流信息，position为控制时的播放位置。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "streams"
+                ],
+                "summary": "回放控制",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "流id,播放接口返回的streamid",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "控制命令:play,pause,teardown",
+                        "name": "cmd",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "play时跳转位置，相对回放开始时间的秒数",
+                        "name": "position",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "description": "play时播放倍速，如0.25,0.5,1,2,4",
+                        "name": "scale",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Streams"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/streams/{id}/download": {
+            "get": {
+                "description": "返回下载流信息，progress为下载进度(0-100)，下载完成后file为mp4文件地址。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "downloads"
+                ],
+                "summary": "历史视频下载进度",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "流id,下载接口返回的streamid",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/sipapi.Streams"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions": {
+            "get": {
+                "description": "可以根据查询条件查询设备订阅列表",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "订阅列表接口",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "条数(0-100) 默认20",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "间隔 默认0",
+                        "name": "skip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序,例:-key,根据key倒序,key,根据key正序",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "查询条件,使用规则详情请看帮助",
+                        "name": "filters",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/api.SubscriptionsListResponse"
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tree": {
+            "get": {
+                "description": "返回所有设备的组织树（设备-行政区划/业务分组/虚拟组织-通道），每个节点带通道总数和在线数。节点较多时使用lazy=1按parentid逐级加载。",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tree"
+                ],
+                "summary": "组织树",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "父节点编码，为空时从设备开始",
+                        "name": "parentid",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "1:只返回下一级节点",
+                        "name": "lazy",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "0": {
+                        "description": "",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sipapi.TreeNode"
+                            }
+                        }
+                    },
+                    "1000": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1001": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1002": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "1003": {
+                        "description": "",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.AlarmsListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.Alarms"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ChannelsListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.Channels"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.DevicesListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.Devices"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.PlatformChannelsListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.PlatformChannels"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.PlatformsListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.Platforms"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.StreamsListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.Streams"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.SubscriptionsListResponse": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.Subscriptions"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "m.SysInfo": {
+            "type": "object",
+            "properties": {
+                "addtime": {
+                    "type": "integer"
+                },
+                "cid": {
+                    "description": "CID 通道id固定头部",
+                    "type": "string"
+                },
+                "cnum": {
+                    "description": "CNUM 当前通道数",
+                    "type": "integer"
+                },
+                "did": {
+                    "description": "DID 设备id固定头部",
+                    "type": "string"
+                },
+                "dnum": {
+                    "description": "DNUM 当前设备数",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lid": {
+                    "description": "LID 当前服务id",
+                    "type": "string"
+                },
+                "mediaServer": {
+                    "type": "boolean"
+                },
+                "region": {
+                    "description": "Region 当前域",
+                    "type": "string"
+                },
+                "uptime": {
+                    "type": "integer"
+                }
+            }
+        },
+        "sipapi.AlarmStatusItem": {
+            "type": "object",
+            "properties": {
+                "deviceid": {
+                    "type": "string"
+                },
+                "dutystatus": {
+                    "description": "DutyStatus 布防状态 ONDUTY OFFDUTY ALARM",
+                    "type": "string"
+                }
+            }
+        },
+        "sipapi.Alarms": {
+            "type": "object",
+            "properties": {
+                "addtime": {
+                    "type": "integer"
+                },
+                "channelid": {
+                    "description": "ChannelID 报警设备编码（通道或报警输入）",
+                    "type": "string"
+                },
+                "description": {
+                    "description": "Description 报警内容描述",
+                    "type": "string"
+                },
+                "deviceid": {
+                    "description": "DeviceID 上报报警的注册设备编号",
+                    "type": "string"
+                },
+                "eventtype": {
+                    "description": "EventType 报警类型扩展参数，入侵检测时 1进入区域 2离开区域",
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "lid": {
-                    "description": "LID 当前服务id",
-                    "type": "string"
+                "latitude": {
+                    "description": "Latitude 纬度",
+                    "type": "number"
                 },
-                "mediaServer": {
-                    "type": "boolean"
+                "longitude": {
+                    "description": "Longitude 经度",
+                    "type": "number"
                 },
-                "region": {
-                    "description": "Region 当前域",
-                    "type": "string"
+                "method": {
+                    "description": "Method 报警方式 1电话 2设备 3短信 4GPS 5视频 6设备故障 7其他",
+                    "type": "integer"
+                },
+                "priority": {
+                    "description": "Priority 报警级别 1一级警情 2二级警情 3三级警情 4四级警情",
+                    "type": "integer"
+                },
+                "time": {
+                    "description": "Time 报警时间",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "Type 报警类型，含义与报警方式相关，如视频报警 2移动侦测 3区域入侵 6视频遮挡",
+                    "type": "integer"
                 },
                 "uptime": {
                     "type": "integer"
                 }
             }
         },
+        "sipapi.BasicParam": {
+            "type": "object",
+            "properties": {
+                "expiration": {
+                    "description": "Expiration 注册过期时间，秒",
+                    "type": "integer"
+                },
+                "heartbeatcount": {
+                    "description": "HeartBeatCount 心跳超时次数",
+                    "type": "integer"
+                },
+                "heartbeatinterval": {
+                    "description": "HeartBeatInterval 心跳间隔时间，秒",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Name 设备名称",
+                    "type": "string"
+                },
+                "positioncapability": {
+                    "description": "PositionCapability 定位功能支持情况 0不支持 1支持GPS 2支持北斗 3支持GPS和北斗",
+                    "type": "integer"
+                }
+            }
+        },
         "sipapi.Channels": {
             "type": "object",
             "properties": {
@@ -928,6 +4073,10 @@ const docTemplate = `{
                 "addtime": {
                     "type": "integer"
                 },
+                "businessgroupid": {
+                    "description": "BusinessGroupID 所属业务分组",
+                    "type": "string"
+                },
                 "channelid": {
                     "description": "ChannelID 通道编码",
                     "type": "string"
@@ -950,6 +4099,13 @@ const docTemplate = `{
                 "id": {
                     "type": "integer"
                 },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "description": "最新位置，移动设备上报",
+                    "type": "number"
+                },
                 "manufacturer": {
                     "type": "string"
                 },
@@ -970,128 +4126,405 @@ const docTemplate = `{
                 "parental": {
                     "type": "integer"
                 },
+                "parentid": {
+                    "description": "ParentID 父节点编码（设备、业务分组或虚拟组织）",
+                    "type": "string"
+                },
+                "positionat": {
+                    "type": "integer"
+                },
                 "registerway": {
                     "type": "integer"
                 },
                 "safetyway": {
                     "type": "integer"
                 },
-                "secrecy": {
+                "secrecy": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "Status 状态  on 在线",
+                    "type": "string"
+                },
+                "streamtype": {
+                    "description": "pull 媒体服务器主动拉流，push 监控设备主动推流",
+                    "type": "string"
+                },
+                "uptime": {
+                    "type": "integer"
+                },
+                "uri": {
+                    "type": "string"
+                },
+                "url": {
+                    "description": "streamtype=pull时，拉流地址",
+                    "type": "string"
+                },
+                "vf": {
+                    "description": "视频编码格式",
+                    "type": "string"
+                },
+                "width": {
+                    "description": "视频宽",
+                    "type": "integer"
+                }
+            }
+        },
+        "sipapi.DeviceConfigResult": {
+            "type": "object",
+            "properties": {
+                "deviceid": {
+                    "type": "string"
+                },
+                "msg": {
+                    "description": "Msg 下发失败原因，如设备不在线、应答超时",
+                    "type": "string"
+                },
+                "result": {
+                    "description": "Result 设备应答结果 OK/ERROR",
+                    "type": "string"
+                }
+            }
+        },
+        "sipapi.DeviceConfigs": {
+            "type": "object",
+            "properties": {
+                "basicparam": {
+                    "$ref": "#/definitions/sipapi.BasicParam"
+                },
+                "snapshot": {
+                    "$ref": "#/definitions/sipapi.SnapShotConfig"
+                },
+                "svacencodeconfig": {
+                    "$ref": "#/definitions/sipapi.SVACEncodeConfig"
+                },
+                "videoparamopt": {
+                    "$ref": "#/definitions/sipapi.VideoParamOpt"
+                }
+            }
+        },
+        "sipapi.DeviceStatus": {
+            "type": "object",
+            "properties": {
+                "alarmstatus": {
+                    "description": "AlarmStatus 报警设备状态列表",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.AlarmStatusItem"
+                    }
+                },
+                "devicetime": {
+                    "description": "DeviceTime 设备时间",
+                    "type": "integer"
+                },
+                "encode": {
+                    "description": "Encode 是否编码 ON OFF",
+                    "type": "string"
+                },
+                "online": {
+                    "description": "Online 是否在线 ONLINE OFFLINE",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Reason 不正常工作原因",
+                    "type": "string"
+                },
+                "record": {
+                    "description": "Record 是否录像 ON OFF",
+                    "type": "string"
+                },
+                "result": {
+                    "description": "Result 查询结果 OK ERROR",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status 是否正常工作 OK ERROR",
+                    "type": "string"
+                },
+                "updateat": {
+                    "description": "UpdateAt 状态获取时间",
+                    "type": "integer"
+                }
+            }
+        },
+        "sipapi.Devices": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "ActiveAt 最后心跳检测时间",
+                    "type": "integer"
+                },
+                "addtime": {
+                    "type": "integer"
+                },
+                "algorithms": {
+                    "description": "Algorithms 接受的摘要认证算法，为空时使用全局配置",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "autocreate": {
+                    "description": "AutoCreate 自动创建目录中未注册的通道 0使用全局配置 1创建 -1不创建",
+                    "type": "integer"
+                },
+                "deviceid": {
+                    "description": "DeviceID 设备id",
+                    "type": "string"
+                },
+                "devicestatus": {
+                    "description": "DeviceStatus 最近一次查询的设备状态",
+                    "$ref": "#/definitions/sipapi.DeviceStatus"
+                },
+                "devicetype": {
+                    "description": "设备类型DVR，NVR",
+                    "type": "string"
+                },
+                "expire": {
+                    "description": "Expire 注册过期时间，0 未注册或已注销",
+                    "type": "integer"
+                },
+                "firmware": {
+                    "description": "Firmware 固件版本",
+                    "type": "string"
+                },
+                "heartbeatcount": {
+                    "description": "HeartBeatCount 设备配置下发的心跳超时次数，0使用全局配置",
+                    "type": "integer"
+                },
+                "heartbeatinterval": {
+                    "description": "HeartBeatInterval 设备配置下发的心跳间隔(秒)，0使用全局配置",
+                    "type": "integer"
+                },
+                "host": {
+                    "description": "Host Via 地址",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "manufacturer": {
+                    "description": "Manufacturer 制造厂商",
+                    "type": "string"
+                },
+                "model": {
+                    "description": "Model 型号",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name 设备名称",
+                    "type": "string"
+                },
+                "port": {
+                    "description": "Port via 端口",
+                    "type": "string"
+                },
+                "proto": {
+                    "description": "Proto 协议",
+                    "type": "string"
+                },
+                "pwd": {
+                    "description": "PWD 密码",
+                    "type": "string"
+                },
+                "raddr": {
+                    "description": "RAddr via recevied",
+                    "type": "string"
+                },
+                "region": {
+                    "description": "Region 设备域",
+                    "type": "string"
+                },
+                "regist": {
+                    "description": "Regist 是否注册",
+                    "type": "boolean"
+                },
+                "report": {
+                    "description": "Rport via rport",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "Source",
+                    "type": "string"
+                },
+                "sysinfo": {
+                    "$ref": "#/definitions/m.SysInfo"
+                },
+                "transport": {
+                    "description": "TransPort via transport",
+                    "type": "string"
+                },
+                "uptime": {
+                    "type": "integer"
+                },
+                "uri": {
+                    "type": "string"
+                }
+            }
+        },
+        "sipapi.PlatformChannels": {
+            "type": "object",
+            "properties": {
+                "addtime": {
+                    "type": "integer"
+                },
+                "channelid": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "platformid": {
+                    "type": "string"
+                },
+                "uptime": {
+                    "type": "integer"
+                }
+            }
+        },
+        "sipapi.Platforms": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "ActiveAt 最后心跳成功时间",
+                    "type": "integer"
+                },
+                "addr": {
+                    "description": "Addr 上级平台信令地址 ip:port",
+                    "type": "string"
+                },
+                "addtime": {
+                    "type": "integer"
+                },
+                "enable": {
+                    "description": "Enable 是否启用",
+                    "type": "boolean"
+                },
+                "expire": {
+                    "description": "Expire 注册过期时间",
+                    "type": "integer"
+                },
+                "expires": {
+                    "description": "Expires 注册有效期，秒",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "keepalive": {
+                    "description": "Keepalive 心跳间隔，秒",
                     "type": "integer"
                 },
-                "status": {
-                    "description": "Status 状态  on 在线",
+                "msg": {
+                    "description": "Msg 注册或心跳失败原因",
                     "type": "string"
                 },
-                "streamtype": {
-                    "description": "pull 媒体服务器主动拉流，push 监控设备主动推流",
+                "name": {
+                    "description": "Name 上级平台名称",
                     "type": "string"
                 },
-                "uptime": {
-                    "type": "integer"
+                "online": {
+                    "description": "Online 是否注册成功",
+                    "type": "boolean"
                 },
-                "uri": {
+                "platformid": {
+                    "description": "PlatformID 上级平台编号",
                     "type": "string"
                 },
-                "url": {
-                    "description": "streamtype=pull时，拉流地址",
+                "pwd": {
+                    "description": "PWD 认证密码",
                     "type": "string"
                 },
-                "vf": {
-                    "description": "视频编码格式",
+                "region": {
+                    "description": "Region 上级平台域",
                     "type": "string"
                 },
-                "width": {
-                    "description": "视频宽",
+                "transport": {
+                    "description": "TransPort 信令传输协议 UDP TCP",
+                    "type": "string"
+                },
+                "uptime": {
                     "type": "integer"
+                },
+                "username": {
+                    "description": "Username 认证用户名，为空时使用本平台编号",
+                    "type": "string"
                 }
             }
         },
-        "sipapi.Devices": {
+        "sipapi.Positions": {
             "type": "object",
             "properties": {
-                "active": {
-                    "description": "ActiveAt 最后心跳检测时间",
-                    "type": "integer"
-                },
                 "addtime": {
                     "type": "integer"
                 },
-                "deviceid": {
-                    "description": "DeviceID 设备id",
-                    "type": "string"
+                "altitude": {
+                    "description": "Altitude 海拔高度，米",
+                    "type": "number"
                 },
-                "devicetype": {
-                    "description": "设备类型DVR，NVR",
+                "channelid": {
+                    "description": "ChannelID 移动设备编码",
                     "type": "string"
                 },
-                "firmware": {
-                    "description": "Firmware 固件版本",
+                "deviceid": {
+                    "description": "DeviceID 上报位置的注册设备编号",
                     "type": "string"
                 },
-                "host": {
-                    "description": "Host Via 地址",
-                    "type": "string"
+                "direction": {
+                    "description": "Direction 方向，正北方向顺时针夹角，度",
+                    "type": "number"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "manufacturer": {
-                    "description": "Manufacturer 制造厂商",
-                    "type": "string"
-                },
-                "model": {
-                    "description": "Model 型号",
-                    "type": "string"
+                "latitude": {
+                    "description": "Latitude 纬度",
+                    "type": "number"
                 },
-                "name": {
-                    "description": "Name 设备名称",
-                    "type": "string"
+                "longitude": {
+                    "description": "Longitude 经度",
+                    "type": "number"
                 },
-                "port": {
-                    "description": "Port via 端口",
-                    "type": "string"
+                "speed": {
+                    "description": "Speed 速度，千米/小时",
+                    "type": "number"
                 },
-                "proto": {
-                    "description": "Proto 协议",
-                    "type": "string"
+                "time": {
+                    "description": "Time 位置时间",
+                    "type": "integer"
                 },
-                "pwd": {
-                    "description": "PWD 密码",
-                    "type": "string"
+                "uptime": {
+                    "type": "integer"
+                }
+            }
+        },
+        "sipapi.Presets": {
+            "type": "object",
+            "properties": {
+                "addtime": {
+                    "type": "integer"
                 },
-                "raddr": {
-                    "description": "RAddr via recevied",
+                "channelid": {
+                    "description": "ChannelID 通道编码",
                     "type": "string"
                 },
-                "region": {
-                    "description": "Region 设备域",
+                "deviceid": {
+                    "description": "DeviceID 设备编号",
                     "type": "string"
                 },
-                "regist": {
-                    "description": "Regist 是否注册",
-                    "type": "boolean"
-                },
-                "report": {
-                    "description": "Rport via rport",
-                    "type": "string"
+                "id": {
+                    "type": "integer"
                 },
-                "source": {
-                    "description": "Source",
+                "name": {
+                    "description": "Name 预置位名称",
                     "type": "string"
                 },
-                "sysinfo": {
-                    "$ref": "#/definitions/m.SysInfo"
-                },
-                "transport": {
-                    "description": "TransPort via transport",
-                    "type": "string"
+                "presetid": {
+                    "description": "PresetID 预置位编号 1-255",
+                    "type": "integer"
                 },
                 "uptime": {
                     "type": "integer"
-                },
-                "uri": {
-                    "type": "string"
                 }
             }
         },
@@ -1140,6 +4573,108 @@ const docTemplate = `{
                 }
             }
         },
+        "sipapi.SVACEncodeConfig": {
+            "type": "object",
+            "properties": {
+                "audioparam": {
+                    "type": "object",
+                    "properties": {
+                        "audiorecognitionflag": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "roiparam": {
+                    "type": "object",
+                    "properties": {
+                        "backgroundqp": {
+                            "type": "integer"
+                        },
+                        "backgroundskipflag": {
+                            "type": "integer"
+                        },
+                        "item": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "bottomright": {
+                                        "type": "integer"
+                                    },
+                                    "roiqp": {
+                                        "type": "integer"
+                                    },
+                                    "roiseq": {
+                                        "type": "integer"
+                                    },
+                                    "topleft": {
+                                        "type": "integer"
+                                    }
+                                }
+                            }
+                        },
+                        "roiflag": {
+                            "type": "integer"
+                        },
+                        "roinumber": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "surveillanceparam": {
+                    "type": "object",
+                    "properties": {
+                        "alertflag": {
+                            "type": "integer"
+                        },
+                        "eventflag": {
+                            "type": "integer"
+                        },
+                        "timeflag": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "svcparam": {
+                    "type": "object",
+                    "properties": {
+                        "svcspacedomainmode": {
+                            "type": "integer"
+                        },
+                        "svcspacesupportmode": {
+                            "type": "integer"
+                        },
+                        "svctimedomainmode": {
+                            "type": "integer"
+                        },
+                        "svctimesupportmode": {
+                            "type": "integer"
+                        }
+                    }
+                }
+            }
+        },
+        "sipapi.SnapShotConfig": {
+            "type": "object",
+            "properties": {
+                "interval": {
+                    "description": "Interval 单张抓拍间隔，秒",
+                    "type": "integer"
+                },
+                "sessionid": {
+                    "description": "SessionID 会话ID",
+                    "type": "string"
+                },
+                "snapnum": {
+                    "description": "SnapNum 连拍张数",
+                    "type": "integer"
+                },
+                "uploadurl": {
+                    "description": "UploadURL 抓拍图像上传路径",
+                    "type": "string"
+                }
+            }
+        },
         "sipapi.Streams": {
             "type": "object",
             "properties": {
@@ -1154,13 +4689,17 @@ const docTemplate = `{
                     "description": "通道ID",
                     "type": "string"
                 },
-                "cseqno": {
-                    "type": "integer"
-                },
                 "deviceid": {
                     "description": "设备ID",
                     "type": "string"
                 },
+                "end": {
+                    "type": "integer"
+                },
+                "file": {
+                    "description": "下载完成的mp4文件地址",
+                    "type": "string"
+                },
                 "http": {
                     "description": "m3u8播放地址",
                     "type": "string"
@@ -1171,6 +4710,22 @@ const docTemplate = `{
                 "msg": {
                     "type": "string"
                 },
+                "paused": {
+                    "description": "Paused 是否暂停",
+                    "type": "boolean"
+                },
+                "position": {
+                    "description": "回放控制状态，T=1时有效\nPosition 最近一次控制时的播放位置，相对回放开始时间的秒数",
+                    "type": "number"
+                },
+                "positionat": {
+                    "description": "PositionAt 最近一次控制时间",
+                    "type": "integer"
+                },
+                "progress": {
+                    "description": "下载进度 0-100",
+                    "type": "integer"
+                },
                 "rtmp": {
                     "description": "rtmp 播放地址",
                     "type": "string"
@@ -1179,6 +4734,18 @@ const docTemplate = `{
                     "description": "rtsp 播放地址",
                     "type": "string"
                 },
+                "scale": {
+                    "description": "Scale 播放倍速",
+                    "type": "number"
+                },
+                "speed": {
+                    "description": "下载倍速，T=2时有效",
+                    "type": "integer"
+                },
+                "start": {
+                    "description": "回放、下载的时间段",
+                    "type": "integer"
+                },
                 "status": {
                     "description": "0正常 1关闭 -1 尚未开始",
                     "type": "integer"
@@ -1200,7 +4767,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "t": {
-                    "description": "0  直播 1 历史",
+                    "description": "0  直播 1 历史 2 下载",
                     "type": "integer"
                 },
                 "uptime": {
@@ -1211,6 +4778,162 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "sipapi.SubscribeParams": {
+            "type": "object",
+            "properties": {
+                "endpriority": {
+                    "description": "EndPriority 报警终止级别，0为全部",
+                    "type": "integer"
+                },
+                "interval": {
+                    "description": "Interval 移动设备位置上报间隔，秒",
+                    "type": "integer"
+                },
+                "method": {
+                    "description": "Method 报警方式，0为全部",
+                    "type": "integer"
+                },
+                "startpriority": {
+                    "description": "StartPriority 报警起始级别，0为全部",
+                    "type": "integer"
+                }
+            }
+        },
+        "sipapi.Subscriptions": {
+            "type": "object",
+            "properties": {
+                "addtime": {
+                    "type": "integer"
+                },
+                "deviceid": {
+                    "description": "DeviceID 订阅的设备编号",
+                    "type": "string"
+                },
+                "event": {
+                    "description": "Event 订阅类型 alarm catalog mobileposition",
+                    "type": "string"
+                },
+                "expireat": {
+                    "description": "ExpireAt 当前订阅到期时间，0 未订阅成功",
+                    "type": "integer"
+                },
+                "expires": {
+                    "description": "Expires 订阅有效期，秒",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "params": {
+                    "description": "Params 订阅条件",
+                    "$ref": "#/definitions/sipapi.SubscribeParams"
+                },
+                "uptime": {
+                    "type": "integer"
+                }
+            }
+        },
+        "sipapi.TalkSession": {
+            "type": "object",
+            "properties": {
+                "channelid": {
+                    "type": "string"
+                },
+                "codec": {
+                    "description": "Codec 协商的音频编码 PCMA PCMU",
+                    "type": "string"
+                },
+                "createdat": {
+                    "type": "integer"
+                },
+                "deviceid": {
+                    "type": "string"
+                },
+                "mode": {
+                    "description": "Mode broadcast 广播 talk 对讲",
+                    "type": "string"
+                },
+                "msg": {
+                    "type": "string"
+                },
+                "playrtmp": {
+                    "description": "对讲时设备音频播放地址",
+                    "type": "string"
+                },
+                "playwsflv": {
+                    "type": "string"
+                },
+                "pushrtmp": {
+                    "description": "浏览器推流地址",
+                    "type": "string"
+                },
+                "pushrtsp": {
+                    "type": "string"
+                },
+                "pushwebrtc": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status waiting 等待推流 inviting 等待设备邀请 talking 通话中",
+                    "type": "string"
+                }
+            }
+        },
+        "sipapi.TreeNode": {
+            "type": "object",
+            "properties": {
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sipapi.TreeNode"
+                    }
+                },
+                "deviceid": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "leaf": {
+                    "description": "Leaf 是否为叶子节点，懒加载时用来判断是否还有子节点",
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "online": {
+                    "description": "Online 节点下在线通道数",
+                    "type": "integer"
+                },
+                "parentid": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status 通道状态，仅通道节点",
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total 节点下通道总数",
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "sipapi.VideoParamOpt": {
+            "type": "object",
+            "properties": {
+                "downloadspeed": {
+                    "description": "DownloadSpeed 下载倍速范围，多个用/分隔，如1/2/4",
+                    "type": "string"
+                },
+                "resolution": {
+                    "description": "Resolution 摄像机支持的分辨率，多个用/分隔",
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
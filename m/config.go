@@ -16,6 +16,12 @@ type Config struct {
 	DB        db.Config         `json:"database" yaml:"database" mapstructure:"database"`
 	LogLevel  string            `json:"logger" yaml:"logger" mapstructure:"logger"`
 	UDP       string            `json:"udp" yaml:"udp" mapstructure:"udp"`
+	TCP       string            `json:"tcp" yaml:"tcp" mapstructure:"tcp"`
+	TLS       TLSCfg            `json:"tls" yaml:"tls" mapstructure:"tls"`
+	Timer     TimerCfg          `json:"timer" yaml:"timer" mapstructure:"timer"`
+	Auth      AuthCfg           `json:"auth" yaml:"auth" mapstructure:"auth"`
+	Keepalive KeepaliveCfg      `json:"keepalive" yaml:"keepalive" mapstructure:"keepalive"`
+	Catalog   CatalogCfg        `json:"catalog" yaml:"catalog" mapstructure:"catalog"`
 	API       string            `json:"api" yaml:"api" mapstructure:"api"`
 	Secret    string            `json:"secret" yaml:"secret" mapstructure:"secret"`
 	Media     MediaServer       `json:"media" yaml:"media" mapstructure:"media"`
@@ -26,6 +32,50 @@ type Config struct {
 	NotifyMap map[string]string
 }
 
+// TLSCfg sip over tls 配置
+type TLSCfg struct {
+	// Addr 监听地址，为空时不开启
+	Addr string `json:"addr" yaml:"addr" mapstructure:"addr"`
+	// Cert 证书文件路径
+	Cert string `json:"cert" yaml:"cert" mapstructure:"cert"`
+	// Key 私钥文件路径
+	Key string `json:"key" yaml:"key" mapstructure:"key"`
+}
+
+// TimerCfg sip 事务定时器配置，单位毫秒，RFC 3261 17.1.1.1
+type TimerCfg struct {
+	// T1 RTT估计值，默认500
+	T1 int `json:"t1" yaml:"t1" mapstructure:"t1"`
+	// T2 非INVITE请求和INVITE响应的最大重传间隔，默认4000
+	T2 int `json:"t2" yaml:"t2" mapstructure:"t2"`
+}
+
+// AuthCfg 设备注册摘要认证配置
+type AuthCfg struct {
+	// Algorithms 接受的摘要算法，按顺序下发认证质询，可选 MD5 MD5-sess SHA-256 SHA-256-sess，默认 MD5
+	Algorithms []string `json:"algorithms" yaml:"algorithms" mapstructure:"algorithms"`
+}
+
+// CatalogCfg 设备目录同步配置
+type CatalogCfg struct {
+	// Interval 全量同步目录间隔(秒)，默认3600
+	Interval int `json:"interval" yaml:"interval" mapstructure:"interval"`
+	// Subscribe 设备上线后自动订阅目录变化
+	Subscribe bool `json:"subscribe" yaml:"subscribe" mapstructure:"subscribe"`
+	// AutoCreate 自动创建目录中未注册的通道，设备未单独配置时使用
+	AutoCreate bool `json:"autocreate" yaml:"autocreate" mapstructure:"autocreate"`
+	// RemoveMissing 完整目录中已不存在的通道标记为已移除
+	RemoveMissing bool `json:"removemissing" yaml:"removemissing" mapstructure:"removemissing"`
+}
+
+// KeepaliveCfg 设备心跳超时配置，超过 Interval*Count 秒未收到心跳时设备离线
+type KeepaliveCfg struct {
+	// Interval 心跳间隔(秒)，默认60
+	Interval int `json:"interval" yaml:"interval" mapstructure:"interval"`
+	// Count 心跳超时次数，默认3
+	Count int `json:"count" yaml:"count" mapstructure:"count"`
+}
+
 type RecordCfg struct {
 	FilePath  string `json:"filepath" yaml:"filepath" mapstructure:"filepath"`
 	Expire    int    `json:"expire" yaml:"expire"  mapstructure:"expire"`
@@ -82,8 +132,10 @@ func LoadConfig() {
 	viper.AddConfigPath("./")
 	viper.SetDefault("logger", "debug")
 	viper.SetDefault("udp", "0.0.0.0:5060")
+	viper.SetDefault("tcp", "0.0.0.0:5060")
 	viper.SetDefault("api", "0.0.0.0:8090")
 	viper.SetDefault("mod", "release")
+	viper.SetDefault("auth.algorithms", []string{"MD5"})
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
@@ -127,4 +179,13 @@ func LoadConfig() {
 	if MConfig.Record.Recordmax <= 0 {
 		MConfig.Record.Recordmax = 600
 	}
+	if MConfig.Keepalive.Interval <= 0 {
+		MConfig.Keepalive.Interval = 60
+	}
+	if MConfig.Keepalive.Count <= 0 {
+		MConfig.Keepalive.Count = 3
+	}
+	if MConfig.Catalog.Interval <= 0 {
+		MConfig.Catalog.Interval = 3600
+	}
 }
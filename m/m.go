@@ -43,8 +43,10 @@ func JsonResponse(c *gin.Context, code string, data any) {
 const (
 	DeviceStatusON  = "ON"
 	DeviceStatusOFF = "OFF"
-	defaultLimit    = 20
-	defaultSort     = "-addtime"
+	// DeviceStatusREMOVED 完整目录中已不存在的通道
+	DeviceStatusREMOVED = "REMOVED"
+	defaultLimit        = 20
+	defaultSort         = "-addtime"
 )
 
 func GetLimit(c *gin.Context) int {
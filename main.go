@@ -59,8 +59,13 @@ func init() {
 }
 
 func _cron() {
-	c := cron.New()                                 // 新建一个定时任务对象
-	c.AddFunc("0 */5 * * * *", sipapi.CheckStreams) // 定时关闭推送流
-	c.AddFunc("0 */5 * * * *", sipapi.ClearFiles)   // 定时清理录制文件
+	c := cron.New()                                        // 新建一个定时任务对象
+	c.AddFunc("0 */5 * * * *", sipapi.CheckStreams)        // 定时关闭推送流
+	c.AddFunc("0 */5 * * * *", sipapi.ClearFiles)          // 定时清理录制文件
+	c.AddFunc("*/30 * * * * *", sipapi.CheckRegisters)     // 定时检查注册过期的设备
+	c.AddFunc("*/10 * * * * *", sipapi.CheckKeepalive)     // 定时检查心跳超时的设备
+	c.AddFunc("*/30 * * * * *", sipapi.CheckSubscriptions) // 定时刷新即将到期的订阅
+	c.AddFunc("0 * * * * *", sipapi.SyncCatalogs)          // 定时全量同步设备目录
+	c.AddFunc("*/10 * * * * *", sipapi.CheckPlatforms)     // 定时向上级平台注册、发送心跳
 	c.Start()
 }
@@ -0,0 +1,90 @@
+package sipapi
+
+import (
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Alarms 设备报警记录
+type Alarms struct {
+	db.DBModel
+	// DeviceID 上报报警的注册设备编号
+	DeviceID string `json:"deviceid" gorm:"column:deviceid"`
+	// ChannelID 报警设备编码（通道或报警输入）
+	ChannelID string `json:"channelid" gorm:"column:channelid"`
+	// Priority 报警级别 1一级警情 2二级警情 3三级警情 4四级警情
+	Priority int `json:"priority" gorm:"column:priority"`
+	// Method 报警方式 1电话 2设备 3短信 4GPS 5视频 6设备故障 7其他
+	Method int `json:"method" gorm:"column:method"`
+	// Type 报警类型，含义与报警方式相关，如视频报警 2移动侦测 3区域入侵 6视频遮挡
+	Type int `json:"type" gorm:"column:type"`
+	// EventType 报警类型扩展参数，入侵检测时 1进入区域 2离开区域
+	EventType int `json:"eventtype" gorm:"column:eventtype"`
+	// Time 报警时间
+	Time int64 `json:"time" gorm:"column:time"`
+	// Description 报警内容描述
+	Description string `json:"description" gorm:"column:description"`
+	// Longitude 经度
+	Longitude float64 `json:"longitude" gorm:"column:longitude"`
+	// Latitude 纬度
+	Latitude float64 `json:"latitude" gorm:"column:latitude"`
+
+	sn int `gorm:"-"`
+}
+
+// MessageAlarmNotify 报警通知
+type MessageAlarmNotify struct {
+	CmdType     string  `xml:"CmdType"`
+	SN          int     `xml:"SN"`
+	DeviceID    string  `xml:"DeviceID"`
+	Priority    int     `xml:"AlarmPriority"`
+	Method      int     `xml:"AlarmMethod"`
+	Time        string  `xml:"AlarmTime"`
+	Description string  `xml:"AlarmDescription"`
+	Longitude   float64 `xml:"Longitude"`
+	Latitude    float64 `xml:"Latitude"`
+	Type        int     `xml:"Info>AlarmType"`
+	EventType   int     `xml:"Info>AlarmTypeParam>EventType"`
+}
+
+// 解析报警通知，保存报警记录后发送通知
+func sipMessageAlarm(u Devices, body []byte) (*Alarms, error) {
+	message := &MessageAlarmNotify{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return nil, err
+	}
+	alarm := &Alarms{
+		DeviceID:    u.DeviceID,
+		ChannelID:   message.DeviceID,
+		Priority:    message.Priority,
+		Method:      message.Method,
+		Type:        message.Type,
+		EventType:   message.EventType,
+		Time:        time.Now().Unix(),
+		Description: message.Description,
+		Longitude:   message.Longitude,
+		Latitude:    message.Latitude,
+		sn:          message.SN,
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", message.Time, time.Local); err == nil {
+		alarm.Time = t.Unix()
+	}
+	if err := db.Create(db.DBClient, alarm); err != nil {
+		logrus.Errorln("save alarm fail,", alarm.DeviceID, alarm.ChannelID, err)
+		return nil, err
+	}
+	go notify(notifyAlarmsNew(*alarm))
+	return alarm, nil
+}
+
+// 报警通知应答
+func sipAlarmResponse(u Devices, alarm *Alarms) {
+	if err := sipMessage(u, u.addr, sip.GetAlarmResponseXML(alarm.ChannelID, alarm.sn)); err != nil {
+		logrus.Warnln("sipAlarmResponse error,", alarm.DeviceID, alarm.ChannelID, err)
+	}
+}
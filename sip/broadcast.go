@@ -0,0 +1,605 @@
+package sipapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdp "github.com/panjjo/gosdp"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// TalkApp 浏览器推送语音流的应用名，流id为通道id
+const TalkApp = "broadcast"
+
+const (
+	// TalkModeBroadcast 语音广播，只向设备发送音频
+	TalkModeBroadcast = "broadcast"
+	// TalkModeTalk 语音对讲，同时接收设备音频
+	TalkModeTalk = "talk"
+
+	// TalkStatusWaiting 等待浏览器推流
+	TalkStatusWaiting = "waiting"
+	// TalkStatusInviting 已发送广播通知，等待设备邀请
+	TalkStatusInviting = "inviting"
+	// TalkStatusTalking 通话中
+	TalkStatusTalking = "talking"
+
+	// 等待推流和设备邀请的超时时间，秒
+	talkWaitTimeout = 30
+	// 对讲时接收设备音频的流id前缀
+	talkRecvPrefix = "talk_"
+)
+
+// TalkSession 语音广播/对讲会话，一个通道同时只有一个
+type TalkSession struct {
+	ChannelID string `json:"channelid"`
+	DeviceID  string `json:"deviceid"`
+	// Mode broadcast 广播 talk 对讲
+	Mode string `json:"mode"`
+	// Status waiting 等待推流 inviting 等待设备邀请 talking 通话中
+	Status string `json:"status"`
+	// 浏览器推流地址
+	PushRTMP   string `json:"pushrtmp"`
+	PushRTSP   string `json:"pushrtsp"`
+	PushWebRTC string `json:"pushwebrtc"`
+	// 对讲时设备音频播放地址
+	PlayRTMP  string `json:"playrtmp,omitempty"`
+	PlayWSFLV string `json:"playwsflv,omitempty"`
+	// Codec 协商的音频编码 PCMA PCMU
+	Codec     string `json:"codec"`
+	Msg       string `json:"msg"`
+	CreatedAt int64  `json:"createdat"`
+
+	// mu 保护Status、Msg、Codec、ssrc、dialog等会话状态
+	mu     sync.Mutex
+	ssrc   string
+	dialog *sip.Dialog
+	device Devices
+	// answering 正在应答设备邀请
+	answering bool
+	// stopped 会话已结束
+	stopped bool
+}
+
+// 会话的副本，用于接口返回
+func (s *TalkSession) copy() *TalkSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &TalkSession{
+		ChannelID:  s.ChannelID,
+		DeviceID:   s.DeviceID,
+		Mode:       s.Mode,
+		Status:     s.Status,
+		PushRTMP:   s.PushRTMP,
+		PushRTSP:   s.PushRTSP,
+		PushWebRTC: s.PushWebRTC,
+		PlayRTMP:   s.PlayRTMP,
+		PlayWSFLV:  s.PlayWSFLV,
+		Codec:      s.Codec,
+		Msg:        s.Msg,
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+// 状态为from时切换为to
+func (s *TalkSession) switchStatus(from, to string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped || s.Status != from {
+		return false
+	}
+	s.Status = to
+	return true
+}
+
+func (s *TalkSession) setMsg(msg string) {
+	s.mu.Lock()
+	s.Msg = msg
+	s.mu.Unlock()
+}
+
+func (s *TalkSession) getDialog() *sip.Dialog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dialog
+}
+
+// 开始应答设备邀请，同时只处理一个邀请
+func (s *TalkSession) startAnswer() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped || s.answering || s.Status != TalkStatusInviting {
+		return false
+	}
+	s.answering = true
+	return true
+}
+
+// 应答失败且会话未结束时，允许设备重新邀请
+func (s *TalkSession) endAnswer() {
+	s.mu.Lock()
+	s.answering = false
+	s.mu.Unlock()
+}
+
+// 当前语音会话 key:channelid
+var _talkSessions = &sync.Map{}
+
+// SipTalkStart 开始语音广播或对讲，浏览器推流到返回的地址后向设备发送广播通知
+func SipTalkStart(channelID, mode string) (*TalkSession, error) {
+	if mode == "" {
+		mode = TalkModeBroadcast
+	}
+	if mode != TalkModeBroadcast && mode != TalkModeTalk {
+		return nil, fmt.Errorf("不支持的语音模式:%s", mode)
+	}
+	if _, ok := _talkSessions.Load(channelID); ok {
+		return nil, errors.New("通道正在语音通话")
+	}
+	channel, device, err := talkChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	session := &TalkSession{
+		ChannelID:  channel.ChannelID,
+		DeviceID:   device.DeviceID,
+		Mode:       mode,
+		Status:     TalkStatusWaiting,
+		PushRTMP:   fmt.Sprintf("%s/%s/%s", config.Media.RTMP, TalkApp, channelID),
+		PushRTSP:   fmt.Sprintf("%s/%s/%s", config.Media.RTSP, TalkApp, channelID),
+		PushWebRTC: fmt.Sprintf("%s/index/api/webrtc?app=%s&stream=%s&type=push", config.Media.HTTP, TalkApp, channelID),
+		CreatedAt:  time.Now().Unix(),
+		device:     device,
+	}
+	if mode == TalkModeTalk {
+		session.PlayRTMP = fmt.Sprintf("%s/%s/%s%s", config.Media.RTMP, TalkApp, talkRecvPrefix, channelID)
+		session.PlayWSFLV = fmt.Sprintf("%s/%s/%s%s.live.flv", config.Media.WS, TalkApp, talkRecvPrefix, channelID)
+	}
+	if _, loaded := _talkSessions.LoadOrStore(channelID, session); loaded {
+		return nil, errors.New("通道正在语音通话")
+	}
+	if res := zlmGetMediaList(zlmGetMediaListReq{app: TalkApp, streamID: channelID, schema: "rtmp"}); len(res.Data) > 0 {
+		// 已经在推流，直接通知设备，推流注册回调可能已经通知
+		if session.switchStatus(TalkStatusWaiting, TalkStatusInviting) {
+			if err := sipBroadcast(session); err != nil {
+				_talkSessions.Delete(channelID)
+				return nil, err
+			}
+		}
+	} else {
+		talkTimeout(session, TalkStatusWaiting, "等待推流超时")
+	}
+	return session.copy(), nil
+}
+
+// 语音会话的通道，要求通道和设备在线
+func talkChannel(channelID string) (Channels, Devices, error) {
+	channel := Channels{ChannelID: channelID}
+	if err := db.Get(db.DBClient, &channel); err != nil {
+		if db.RecordNotFound(err) {
+			return channel, Devices{}, errors.New("通道不存在")
+		}
+		return channel, Devices{}, err
+	}
+	if channel.StreamType == m.StreamTypePull {
+		return channel, Devices{}, errors.New("拉流通道不支持语音")
+	}
+	if channel.Status != m.DeviceStatusON {
+		return channel, Devices{}, errors.New("通道已离线")
+	}
+	device, ok := _activeDevices.Get(channel.DeviceID)
+	if !ok {
+		return channel, Devices{}, errors.New("设备已离线")
+	}
+	return channel, device, nil
+}
+
+// SipTalkStop 结束语音会话，通话中时向设备发送BYE
+func SipTalkStop(channelID string) error {
+	v, ok := _talkSessions.LoadAndDelete(channelID)
+	if !ok {
+		return errors.New("语音会话不存在")
+	}
+	stopTalk(v.(*TalkSession), true)
+	zlmCloseAppStream(TalkApp, channelID)
+	return nil
+}
+
+// GetTalkSession 获取通道当前语音会话
+func GetTalkSession(channelID string) (*TalkSession, bool) {
+	v, ok := _talkSessions.Load(channelID)
+	if !ok {
+		return nil, false
+	}
+	return v.(*TalkSession).copy(), true
+}
+
+// TalkStreamRegist 浏览器推流注册，通知设备开始广播
+func TalkStreamRegist(stream string) {
+	v, ok := _talkSessions.Load(stream)
+	if !ok {
+		return
+	}
+	session := v.(*TalkSession)
+	if !session.switchStatus(TalkStatusWaiting, TalkStatusInviting) {
+		return
+	}
+	if err := sipBroadcast(session); err != nil {
+		logrus.Warnln("talk broadcast fail,", session.ChannelID, err)
+		SipTalkStop(session.ChannelID)
+	}
+}
+
+// TalkStreamUnregist 浏览器停止推流，结束语音会话
+func TalkStreamUnregist(stream string) {
+	if _, ok := _talkSessions.Load(stream); ok {
+		logrus.Infoln("talk stream unregist,", stream)
+		SipTalkStop(stream)
+	}
+}
+
+// TalkStreamActive 语音会话中的推流和对讲接收流，无人观看也不关闭
+func TalkStreamActive(stream string) bool {
+	_, ok := _talkSessions.Load(strings.TrimPrefix(stream, talkRecvPrefix))
+	return ok
+}
+
+// 发送语音广播通知，设备收到后向平台发起INVITE，调用前会话已切换为等待设备邀请
+func sipBroadcast(session *TalkSession) error {
+	body := sip.GetBroadcastXML(_serverDevices.DeviceID, session.ChannelID, utils.RandInt(100000, 999999))
+	if err := sipMessage(session.device, session.device.addr, body); err != nil {
+		return err
+	}
+	talkTimeout(session, TalkStatusInviting, "等待设备邀请超时")
+	return nil
+}
+
+// 超时仍处于指定状态时结束会话
+func talkTimeout(session *TalkSession, status, msg string) {
+	time.AfterFunc(talkWaitTimeout*time.Second, func() {
+		if v, ok := _talkSessions.Load(session.ChannelID); !ok || v != session {
+			return
+		}
+		session.mu.Lock()
+		// 正在应答设备邀请时由应答结果决定
+		timeout := !session.stopped && !session.answering && session.Status == status
+		if timeout {
+			session.Msg = msg
+		}
+		session.mu.Unlock()
+		if timeout {
+			logrus.Warnln("talk session timeout,", session.ChannelID, msg)
+			SipTalkStop(session.ChannelID)
+		}
+	})
+}
+
+// 停止向设备发送音频，sendBye 为true时向设备发送BYE
+func stopTalk(session *TalkSession, sendBye bool) {
+	session.mu.Lock()
+	session.stopped = true
+	ssrc, dialog := session.ssrc, session.dialog
+	session.mu.Unlock()
+	if ssrc == "" {
+		// 还未开始发送
+		return
+	}
+	if sendBye && dialog != nil {
+		if req, err := dialog.NewRequest(sip.BYE, nil, nil); err == nil {
+			if tx, err := srv.Request(req); err == nil {
+				if _, err := sipResponse(tx); err != nil {
+					logrus.Warnln("talk bye response error,", session.ChannelID, err)
+				}
+			}
+		}
+	}
+	talkStopSendRtp(session.ChannelID, ssrc)
+}
+
+// 停止zlm向设备发送音频
+func talkStopSendRtp(channelID, ssrc string) {
+	values := url.Values{}
+	values.Set("secret", config.Media.Secret)
+	values.Set("vhost", "__defaultVhost__")
+	values.Set("app", TalkApp)
+	values.Set("stream", channelID)
+	values.Set("ssrc", ssrc)
+	if err := zlmStopSendRtp(values); err != nil {
+		logrus.Warnln("talk stop send rtp fail,", channelID, err)
+	}
+}
+
+// MessageBroadcastResponse 语音广播应答
+type MessageBroadcastResponse struct {
+	CmdType  string `xml:"CmdType"`
+	SN       int    `xml:"SN"`
+	DeviceID string `xml:"DeviceID"`
+	Result   string `xml:"Result"`
+}
+
+// 语音广播应答，设备拒绝时结束会话
+func sipMessageBroadcast(u Devices, body []byte) error {
+	message := &MessageBroadcastResponse{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	session, ok := getTalkSession(message.DeviceID)
+	if !ok {
+		return errors.New("talk session not found")
+	}
+	if message.Result != "" && message.Result != "OK" {
+		logrus.Warnln("device refuse broadcast,", session.ChannelID, message.Result)
+		session.setMsg(fmt.Sprintf("设备拒绝语音广播:%s", message.Result))
+		SipTalkStop(session.ChannelID)
+	}
+	return nil
+}
+
+// 按通道或设备编号查找语音会话，部分设备使用设备编号发送应答和邀请
+func getTalkSession(id string) (*TalkSession, bool) {
+	if v, ok := _talkSessions.Load(id); ok {
+		return v.(*TalkSession), true
+	}
+	var session *TalkSession
+	_talkSessions.Range(func(key, value interface{}) bool {
+		item := value.(*TalkSession)
+		if item.DeviceID == id {
+			session = item
+			return false
+		}
+		return true
+	})
+	return session, session != nil
+}
+
+// 按对话查找语音会话
+func getTalkByDialog(req *sip.Request) (*TalkSession, bool) {
+	var session *TalkSession
+	_talkSessions.Range(func(key, value interface{}) bool {
+		item := value.(*TalkSession)
+		if dialog := item.getDialog(); dialog != nil && dialog.Match(req) {
+			session = item
+			return false
+		}
+		return true
+	})
+	return session, session != nil
+}
+
+// 上级平台的点播INVITE，或设备收到语音广播通知后发起的INVITE，协商G.711音频并由zlm发送浏览器推送的语音
+func handlerInvite(req *sip.Request, tx *sip.Transaction) {
+	if p, ok := platformFromRequest(req); ok {
+		if p == nil {
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil))
+			return
+		}
+		// 上级平台点播
+		platformInvite(p, req, tx)
+		return
+	}
+	from, ok := req.From()
+	if !ok || from.Address == nil || from.Address.FUser == nil {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		return
+	}
+	session, ok := getTalkSession(from.Address.FUser.String())
+	if !ok || !session.startAnswer() {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusNotFound, http.StatusText(http.StatusNotFound), nil))
+		return
+	}
+	offer, err := sdp.Decode(req.Body())
+	if err != nil {
+		logrus.Warnln("talk invite sdp error,", session.ChannelID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		session.endAnswer()
+		return
+	}
+	answer, err := talkAnswer(session, offer, sdpSSRC(req.Body()))
+	if err != nil {
+		logrus.Warnln("talk invite fail,", session.ChannelID, err)
+		// 488 Not Acceptable Here
+		tx.Respond(sip.NewResponseFromRequest("", req, 488, "Not Acceptable Here", nil))
+		session.setMsg(fmt.Sprint(err))
+		SipTalkStop(session.ChannelID)
+		return
+	}
+	response := sip.NewResponseFromRequest("", req, http.StatusOK, "OK", answer)
+	if to, ok := response.To(); ok {
+		if to.Params == nil {
+			to.Params = sip.NewParams()
+		}
+		to.Params.Add("tag", sip.String{Str: utils.RandString(20)})
+	}
+	response.AppendHeader(&sip.ContactHeader{Address: _serverDevices.addr.URI, Params: sip.NewParams()})
+	response.AppendHeader(&sip.ContentTypeSDP)
+	dialog, err := sip.NewDialogFromRequest(req, response)
+	if err != nil {
+		logrus.Warnln("talk invite dialog fail,", session.ChannelID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		talkInviteFail(session, fmt.Sprint(err))
+		return
+	}
+	session.mu.Lock()
+	stopped := session.stopped
+	session.dialog = dialog
+	session.mu.Unlock()
+	if stopped {
+		// 应答期间会话已结束，停止发送由talkAnswer或stopTalk处理
+		tx.Respond(sip.NewResponseFromRequest("", req, sip.StatusRequestTerminated, "Request Terminated", nil))
+		return
+	}
+	if err := tx.Respond(response); err != nil {
+		// 设备已取消邀请或事务已超时
+		logrus.Warnln("talk invite respond fail,", session.ChannelID, err)
+		talkInviteFail(session, fmt.Sprint(err))
+		return
+	}
+	session.mu.Lock()
+	session.Status = TalkStatusTalking
+	session.answering = false
+	codec := session.Codec
+	session.mu.Unlock()
+	logrus.Infoln("talk session start,", session.DeviceID, session.ChannelID, session.Mode, codec)
+}
+
+// 应答设备邀请失败，结束会话并停止已开始的音频发送，设备未建立会话不发送BYE
+func talkInviteFail(session *TalkSession, msg string) {
+	session.setMsg(msg)
+	if v, ok := _talkSessions.LoadAndDelete(session.ChannelID); ok {
+		stopTalk(v.(*TalkSession), false)
+		zlmCloseAppStream(TalkApp, session.ChannelID)
+	}
+}
+
+// 根据设备的SDP开始发送音频，生成应答SDP
+func talkAnswer(session *TalkSession, offer *sdp.Message, ssrc string) ([]byte, error) {
+	var audio *sdp.Media
+	for i := range offer.Medias {
+		if offer.Medias[i].Description.Type == "audio" {
+			audio = &offer.Medias[i]
+			break
+		}
+	}
+	if audio == nil {
+		return nil, errors.New("设备未提供音频媒体")
+	}
+	pt, codec := "", ""
+	for _, f := range audio.Description.Formats {
+		format := strings.ToUpper(audio.PayloadFormat(f))
+		if f == "8" || strings.HasPrefix(format, "PCMA") {
+			pt, codec = f, "PCMA"
+			break
+		}
+		if f == "0" || strings.HasPrefix(format, "PCMU") {
+			pt, codec = f, "PCMU"
+			break
+		}
+	}
+	if pt == "" {
+		return nil, fmt.Errorf("设备不支持G.711音频:%v", audio.Description.Formats)
+	}
+	ip := audio.Connection.IP
+	if ip == nil {
+		ip = offer.Connection.IP
+	}
+	if ip == nil {
+		return nil, errors.New("设备未提供音频接收地址")
+	}
+	if ssrc == "" {
+		ssrc = strconv.Itoa(utils.RandInt(100000000, 999999999))
+	}
+	tcp := strings.HasPrefix(strings.ToUpper(audio.Description.Protocol), "TCP")
+	// 设备主动连接时平台被动等待
+	passive := tcp && audio.Attribute("setup") == "active"
+	values := url.Values{}
+	values.Set("secret", config.Media.Secret)
+	values.Set("vhost", "__defaultVhost__")
+	values.Set("app", TalkApp)
+	values.Set("stream", session.ChannelID)
+	values.Set("ssrc", ssrc)
+	values.Set("pt", pt)
+	values.Set("use_ps", "0")
+	values.Set("only_audio", "1")
+	if !passive {
+		values.Set("dst_url", ip.String())
+		values.Set("dst_port", strconv.Itoa(audio.Description.Port))
+		if tcp {
+			values.Set("is_udp", "0")
+		} else {
+			values.Set("is_udp", "1")
+		}
+	}
+	if session.Mode == TalkModeTalk {
+		values.Set("recv_stream_id", talkRecvPrefix+session.ChannelID)
+	}
+	localPort, err := zlmStartSendRtp(values, passive)
+	if err != nil {
+		return nil, err
+	}
+	session.mu.Lock()
+	stopped := session.stopped
+	if !stopped {
+		session.ssrc = ssrc
+		session.Codec = codec
+	}
+	session.mu.Unlock()
+	if stopped {
+		// 开始发送期间会话已结束
+		talkStopSendRtp(session.ChannelID, ssrc)
+		return nil, errors.New("语音会话已结束")
+	}
+
+	media := sdp.Media{
+		Description: sdp.MediaDescription{
+			Type:     "audio",
+			Port:     localPort,
+			Formats:  []string{pt},
+			Protocol: audio.Description.Protocol,
+		},
+	}
+	if session.Mode == TalkModeTalk {
+		media.AddAttribute("sendrecv")
+	} else {
+		media.AddAttribute("sendonly")
+	}
+	media.AddAttribute("rtpmap", pt, codec+"/8000")
+	if tcp {
+		if passive {
+			media.AddAttribute("setup", "passive")
+		} else {
+			media.AddAttribute("setup", "active")
+		}
+		media.AddAttribute("connection", "new")
+	}
+	msg := &sdp.Message{
+		Origin: sdp.Origin{
+			Username: _serverDevices.DeviceID,
+			Address:  _sysinfo.MediaServerRtpIP.String(),
+		},
+		Name: "Play",
+		Connection: sdp.ConnectionData{
+			IP:  _sysinfo.MediaServerRtpIP,
+			TTL: 0,
+		},
+		Timing: []sdp.Timing{{}},
+		Medias: []sdp.Media{media},
+		SSRC:   ssrc,
+	}
+	var s sdp.Session
+	s = msg.Append(s)
+	return s.AppendTo(nil), nil
+}
+
+// gosdp 不解析GB28181的y=字段，单独读取ssrc
+func sdpSSRC(body []byte) string {
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "y=") {
+			return strings.TrimPrefix(line, "y=")
+		}
+	}
+	return ""
+}
+
+// 设备挂断语音会话
+func talkBye(session *TalkSession, req *sip.Request) {
+	if err := session.getDialog().ReceiveRequest(req); err != nil {
+		logrus.Warnln("talk bye dialog error,", session.ChannelID, err)
+	}
+	logrus.Infoln("device talk bye,", session.DeviceID, session.ChannelID)
+	if v, ok := _talkSessions.LoadAndDelete(session.ChannelID); ok {
+		stopTalk(v.(*TalkSession), false)
+		zlmCloseAppStream(TalkApp, session.ChannelID)
+	}
+}
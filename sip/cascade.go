@@ -0,0 +1,620 @@
+package sipapi
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdp "github.com/panjjo/gosdp"
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 目录应答每条消息携带的通道数
+	platformCatalogPageSize = 5
+	// 等待设备推流的时间，秒
+	platformStreamWait = 10
+)
+
+// 上级平台点播会话
+type cascadeSession struct {
+	PlatformID string
+	ChannelID  string
+	// 本平台流ID
+	StreamID string
+	// 0 直播 1 回放
+	T int
+	// 上级平台指定的ssrc
+	ssrc   string
+	dialog *sip.Dialog
+}
+
+// 上级平台点播会话 key:dialog id
+var _cascadeSessions = &sync.Map{}
+
+// 上级平台查询请求
+type platformQuery struct {
+	CmdType   string `xml:"CmdType"`
+	SN        int    `xml:"SN"`
+	DeviceID  string `xml:"DeviceID"`
+	StartTime string `xml:"StartTime"`
+	EndTime   string `xml:"EndTime"`
+}
+
+// 目录查询应答
+type platformCatalogResponse struct {
+	XMLName    xml.Name            `xml:"Response"`
+	CmdType    string              `xml:"CmdType"`
+	SN         int                 `xml:"SN"`
+	DeviceID   string              `xml:"DeviceID"`
+	SumNum     int                 `xml:"SumNum"`
+	DeviceList platformCatalogList `xml:"DeviceList"`
+}
+
+type platformCatalogList struct {
+	Num  int                   `xml:"Num,attr"`
+	Item []platformCatalogItem `xml:"Item"`
+}
+
+type platformCatalogItem struct {
+	DeviceID     string `xml:"DeviceID"`
+	Name         string `xml:"Name"`
+	Manufacturer string `xml:"Manufacturer"`
+	Model        string `xml:"Model"`
+	Owner        string `xml:"Owner"`
+	CivilCode    string `xml:"CivilCode"`
+	Address      string `xml:"Address"`
+	Parental     int    `xml:"Parental"`
+	ParentID     string `xml:"ParentID"`
+	SafetyWay    int    `xml:"SafetyWay"`
+	RegisterWay  int    `xml:"RegisterWay"`
+	Secrecy      int    `xml:"Secrecy"`
+	Status       string `xml:"Status"`
+}
+
+// 录像查询应答
+type platformRecordInfoResponse struct {
+	XMLName    xml.Name           `xml:"Response"`
+	CmdType    string             `xml:"CmdType"`
+	SN         int                `xml:"SN"`
+	DeviceID   string             `xml:"DeviceID"`
+	Name       string             `xml:"Name"`
+	SumNum     int                `xml:"SumNum"`
+	RecordList platformRecordList `xml:"RecordList"`
+}
+
+type platformRecordList struct {
+	Num  int          `xml:"Num,attr"`
+	Item []RecordItem `xml:"Item"`
+}
+
+// 请求是否来自已注册的上级平台，From 为上级平台编号时返回true
+// 来源地址不是上级平台的信令地址时返回的平台为nil，防止伪造上级平台编号查询目录或点播
+func platformFromRequest(req *sip.Request) (*platform, bool) {
+	from, ok := req.From()
+	if !ok || from.Address == nil || from.Address.User() == nil {
+		return nil, false
+	}
+	p, ok := getPlatform(from.Address.User().String())
+	if !ok {
+		return nil, false
+	}
+	if !p.fromAddr(req.Source()) {
+		logrus.Warnln("platform request from unexpected source,", p.PlatformID, req.Source())
+		return nil, true
+	}
+	return p, true
+}
+
+// 上级平台的MESSAGE，先回复200，再异步发送查询应答
+func platformMessage(p *platform, req *sip.Request, tx *sip.Transaction) {
+	body, message, err := decodeMessageBody(req.Body())
+	if err != nil {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		return
+	}
+	query := &platformQuery{}
+	if err := utils.XMLDecode(body, query); err != nil {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		return
+	}
+	switch message.CmdType {
+	case "Catalog":
+		// 目录查询，应答共享的通道
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		go platformCatalog(p, query)
+		return
+	case "DeviceInfo":
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		go platformDeviceInfo(p, query)
+		return
+	case "DeviceStatus":
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		go platformDeviceStatus(p, query)
+		return
+	case "RecordInfo":
+		// 录像查询，转发给设备
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		go platformRecordInfo(p, query)
+		return
+	}
+	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+}
+
+// 向上级平台发送应答，xml 转为GB2312编码
+func platformSendMessage(p *platform, body []byte) error {
+	body, err := utils.Utf8ToGbk(body)
+	if err != nil {
+		return err
+	}
+	reg, _ := p.registration(false)
+	if reg == nil {
+		return errors.New("上级平台未注册")
+	}
+	res, err := reg.Request(sip.MESSAGE, &sip.ContentTypeXML, body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode() != http.StatusOK {
+		return fmt.Errorf("%d %s", res.StatusCode(), res.Reason())
+	}
+	return nil
+}
+
+func platformMarshal(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("<?xml version=\"1.0\" encoding=\"GB2312\"?>\n"), body...), nil
+}
+
+func platformCatalog(p *platform, query *platformQuery) {
+	channels, err := p.channels()
+	if err != nil {
+		logrus.Errorln("platform catalog find channels error,", p.PlatformID, err)
+		return
+	}
+	for i := 0; i < len(channels) || i == 0; i += platformCatalogPageSize {
+		end := i + platformCatalogPageSize
+		if end > len(channels) {
+			end = len(channels)
+		}
+		res := platformCatalogResponse{CmdType: "Catalog", SN: query.SN, DeviceID: _serverDevices.DeviceID, SumNum: len(channels)}
+		for _, channel := range channels[i:end] {
+			civilCode := channel.CivilCode
+			if civilCode == "" {
+				civilCode = _serverDevices.Region
+			}
+			registerWay := channel.RegisterWay
+			if registerWay == 0 {
+				registerWay = 1
+			}
+			res.DeviceList.Item = append(res.DeviceList.Item, platformCatalogItem{
+				DeviceID:     channel.ChannelID,
+				Name:         channel.Name,
+				Manufacturer: channel.Manufacturer,
+				Model:        channel.Model,
+				Owner:        channel.Owner,
+				CivilCode:    civilCode,
+				Address:      channel.Address,
+				ParentID:     _serverDevices.DeviceID,
+				SafetyWay:    channel.SafetyWay,
+				RegisterWay:  registerWay,
+				Secrecy:      channel.Secrecy,
+				Status:       platformChannelStatus(channel),
+			})
+		}
+		res.DeviceList.Num = len(res.DeviceList.Item)
+		body, err := platformMarshal(res)
+		if err != nil {
+			logrus.Errorln("platform catalog marshal error,", p.PlatformID, err)
+			return
+		}
+		if err := platformSendMessage(p, body); err != nil {
+			logrus.Warnln("platform catalog send fail,", p.PlatformID, err)
+			return
+		}
+	}
+}
+
+func platformChannelStatus(channel Channels) string {
+	if channel.Status == m.DeviceStatusON {
+		return m.DeviceStatusON
+	}
+	return m.DeviceStatusOFF
+}
+
+func platformDeviceInfo(p *platform, query *platformQuery) {
+	var body []byte
+	if channel, ok := platformChannel(p, query.DeviceID); ok {
+		body = sip.GetDeviceInfoResponseXML(channel.ChannelID, query.SN, channel.Name, channel.Manufacturer, channel.Model, "", 1)
+	} else {
+		channels, _ := p.channels()
+		body = sip.GetDeviceInfoResponseXML(_serverDevices.DeviceID, query.SN, "gosip", "gosip", "gosip", "", len(channels))
+	}
+	if err := platformSendMessage(p, body); err != nil {
+		logrus.Warnln("platform deviceinfo send fail,", p.PlatformID, err)
+	}
+}
+
+func platformDeviceStatus(p *platform, query *platformQuery) {
+	id, online := _serverDevices.DeviceID, "ONLINE"
+	if channel, ok := platformChannel(p, query.DeviceID); ok {
+		id = channel.ChannelID
+		if platformChannelStatus(channel) != m.DeviceStatusON {
+			online = "OFFLINE"
+		}
+	}
+	if err := platformSendMessage(p, sip.GetDeviceStatusResponseXML(id, query.SN, online)); err != nil {
+		logrus.Warnln("platform devicestatus send fail,", p.PlatformID, err)
+	}
+}
+
+func platformRecordInfo(p *platform, query *platformQuery) {
+	channel, ok := platformChannel(p, query.DeviceID)
+	if !ok {
+		logrus.Warnln("platform recordinfo channel not shared,", p.PlatformID, query.DeviceID)
+		return
+	}
+	res := platformRecordInfoResponse{CmdType: "RecordInfo", SN: query.SN, DeviceID: channel.ChannelID, Name: channel.Name}
+	start, err1 := time.ParseInLocation("2006-01-02T15:04:05", query.StartTime, time.Local)
+	end, err2 := time.ParseInLocation("2006-01-02T15:04:05", query.EndTime, time.Local)
+	if err1 == nil && err2 == nil {
+		records, err := SipRecordList(&channel, start.Unix(), end.Unix())
+		if err != nil {
+			logrus.Warnln("platform recordinfo fail,", p.PlatformID, channel.ChannelID, err)
+		} else {
+			for _, date := range records.Data {
+				for _, item := range date.Items {
+					res.RecordList.Item = append(res.RecordList.Item, RecordItem{
+						DeviceID:  channel.ChannelID,
+						Name:      channel.Name,
+						StartTime: time.Unix(item.Start, 0).Format("2006-01-02T15:04:05"),
+						EndTime:   time.Unix(item.End, 0).Format("2006-01-02T15:04:05"),
+						Type:      "time",
+					})
+				}
+			}
+		}
+	}
+	res.SumNum = len(res.RecordList.Item)
+	res.RecordList.Num = res.SumNum
+	body, err := platformMarshal(res)
+	if err != nil {
+		logrus.Errorln("platform recordinfo marshal error,", p.PlatformID, err)
+		return
+	}
+	if err := platformSendMessage(p, body); err != nil {
+		logrus.Warnln("platform recordinfo send fail,", p.PlatformID, err)
+	}
+}
+
+// 共享给上级平台的通道
+func platformChannel(p *platform, channelID string) (Channels, bool) {
+	channel := Channels{ChannelID: channelID}
+	if channelID == "" || !p.hasChannel(channelID) {
+		return channel, false
+	}
+	if err := db.Get(db.DBClient, &channel); err != nil {
+		return channel, false
+	}
+	return channel, true
+}
+
+// 上级平台点播（直播/回放），本平台向设备点播后由zlm将流转发给上级平台
+func platformInvite(p *platform, req *sip.Request, tx *sip.Transaction) {
+	dest := p.destination()
+	if dest == nil {
+		// 上级平台配置已修改，等待重新注册
+		tx.Respond(sip.NewResponseFromRequest("", req, sip.StatusTemporarilyUnavailable, "Temporarily Unavailable", nil))
+		return
+	}
+	channelID := ""
+	if user := req.Recipient().User(); user != nil {
+		channelID = user.String()
+	}
+	if _, ok := platformChannel(p, channelID); !ok {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusNotFound, http.StatusText(http.StatusNotFound), nil))
+		return
+	}
+	offer, err := sdp.Decode(req.Body())
+	if err != nil {
+		logrus.Warnln("platform invite sdp error,", p.PlatformID, channelID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		return
+	}
+	session := &cascadeSession{PlatformID: p.PlatformID, ChannelID: channelID, ssrc: sdpSSRC(req.Body())}
+	stream := &Streams{ChannelID: channelID}
+	switch offer.Name {
+	case "Play":
+	case "Playback":
+		session.T, stream.T = 1, 1
+		start, end := sdpTiming(req.Body())
+		if start == 0 || start >= end {
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+			return
+		}
+		stream.S, stream.E = time.Unix(start, 0), time.Unix(end, 0)
+	default:
+		// 488 Not Acceptable Here
+		tx.Respond(sip.NewResponseFromRequest("", req, 488, "Not Acceptable Here", nil))
+		return
+	}
+	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusContinue, "Trying", nil))
+
+	if !tx.Pending() {
+		logrus.Infoln("platform invite canceled,", p.PlatformID, channelID)
+		return
+	}
+	if session.T == 0 {
+		// 直播复用已存在的流
+		if succ, ok := StreamList.Succ.Load(channelID); ok {
+			stream = succ.(*Streams)
+		}
+	}
+	if stream.StreamID == "" {
+		if stream, err = SipPlay(stream); err != nil {
+			logrus.Warnln("platform invite play fail,", p.PlatformID, channelID, err)
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusNotFound, http.StatusText(http.StatusNotFound), nil))
+			return
+		}
+	}
+	session.StreamID = stream.StreamID
+	answer, err := platformAnswer(session, offer)
+	if err != nil {
+		logrus.Warnln("platform invite fail,", p.PlatformID, channelID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, 488, "Not Acceptable Here", nil))
+		if session.T == 1 {
+			SipStopPlay(session.StreamID)
+		}
+		return
+	}
+	if !tx.Pending() {
+		// 等待设备推流期间上级平台已取消或事务超时
+		logrus.Infoln("platform invite canceled,", p.PlatformID, channelID, session.StreamID)
+		stopCascade(session)
+		return
+	}
+	response := sip.NewResponseFromRequest("", req, http.StatusOK, "OK", answer)
+	if to, ok := response.To(); ok {
+		if to.Params == nil {
+			to.Params = sip.NewParams()
+		}
+		to.Params.Add("tag", sip.String{Str: utils.RandString(20)})
+	}
+	response.AppendHeader(&sip.ContactHeader{Address: _serverDevices.addr.URI, Params: sip.NewParams()})
+	response.AppendHeader(&sip.ContentTypeSDP)
+	dialog, err := sip.NewDialogFromRequest(req, response)
+	if err != nil {
+		logrus.Warnln("platform invite dialog fail,", p.PlatformID, channelID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		stopCascade(session)
+		return
+	}
+	dialog.SetDestination(dest)
+	session.dialog = dialog
+	_cascadeSessions.Store(dialog.ID(), session)
+	if err := tx.Respond(response); err != nil {
+		logrus.Warnln("platform invite respond fail,", p.PlatformID, channelID, err)
+		_cascadeSessions.Delete(dialog.ID())
+		stopCascade(session)
+		return
+	}
+	logrus.Infoln("platform invite start,", p.PlatformID, channelID, session.StreamID)
+}
+
+// 等待设备推流后由zlm向上级平台发送，生成应答SDP
+func platformAnswer(session *cascadeSession, offer *sdp.Message) ([]byte, error) {
+	var video *sdp.Media
+	for i := range offer.Medias {
+		if offer.Medias[i].Description.Type == "video" {
+			video = &offer.Medias[i]
+			break
+		}
+	}
+	if video == nil {
+		return nil, errors.New("上级平台未提供视频媒体")
+	}
+	ip := video.Connection.IP
+	if ip == nil {
+		ip = offer.Connection.IP
+	}
+	if ip == nil {
+		return nil, errors.New("上级平台未提供视频接收地址")
+	}
+	if !platformWaitStream(session.StreamID) {
+		return nil, errors.New("等待设备推流超时")
+	}
+	if session.ssrc == "" {
+		session.ssrc = strconv.Itoa(utils.RandInt(100000000, 999999999))
+	}
+	tcp := strings.HasPrefix(strings.ToUpper(video.Description.Protocol), "TCP")
+	// 上级平台主动连接时本平台被动等待
+	passive := tcp && video.Attribute("setup") == "active"
+	values := url.Values{}
+	values.Set("secret", config.Media.Secret)
+	values.Set("vhost", "__defaultVhost__")
+	values.Set("app", "rtp")
+	values.Set("stream", session.StreamID)
+	values.Set("ssrc", session.ssrc)
+	values.Set("pt", "96")
+	values.Set("use_ps", "1")
+	if !passive {
+		values.Set("dst_url", ip.String())
+		values.Set("dst_port", strconv.Itoa(video.Description.Port))
+		if tcp {
+			values.Set("is_udp", "0")
+		} else {
+			values.Set("is_udp", "1")
+		}
+	}
+	localPort, err := zlmStartSendRtp(values, passive)
+	if err != nil {
+		return nil, err
+	}
+
+	media := sdp.Media{
+		Description: sdp.MediaDescription{
+			Type:     "video",
+			Port:     localPort,
+			Formats:  []string{"96"},
+			Protocol: video.Description.Protocol,
+		},
+	}
+	media.AddAttribute("sendonly")
+	media.AddAttribute("rtpmap", "96", "PS/90000")
+	if tcp {
+		if passive {
+			media.AddAttribute("setup", "passive")
+		} else {
+			media.AddAttribute("setup", "active")
+		}
+		media.AddAttribute("connection", "new")
+	}
+	msg := &sdp.Message{
+		Origin: sdp.Origin{
+			Username: session.ChannelID,
+			Address:  _sysinfo.MediaServerRtpIP.String(),
+		},
+		Name: offer.Name,
+		Connection: sdp.ConnectionData{
+			IP:  _sysinfo.MediaServerRtpIP,
+			TTL: 0,
+		},
+		Timing: []sdp.Timing{{}},
+		Medias: []sdp.Media{media},
+		SSRC:   session.ssrc,
+	}
+	var s sdp.Session
+	s = msg.Append(s)
+	return s.AppendTo(nil), nil
+}
+
+// 等待zlm收到设备推流
+func platformWaitStream(streamID string) bool {
+	for i := 0; i < platformStreamWait*2; i++ {
+		if resp := zlmGetMediaList(zlmGetMediaListReq{app: "rtp", streamID: streamID}); resp.Code == 0 && len(resp.Data) > 0 {
+			return true
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return false
+}
+
+// gosdp 按NTP时间解析t=字段，GB28181使用unix时间戳，单独读取
+func sdpTiming(body []byte) (int64, int64) {
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "t=") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "t="))
+		if len(fields) != 2 {
+			return 0, 0
+		}
+		start, _ := strconv.ParseInt(fields[0], 10, 64)
+		end, _ := strconv.ParseInt(fields[1], 10, 64)
+		return start, end
+	}
+	return 0, 0
+}
+
+func getCascadeByDialog(req *sip.Request) (*cascadeSession, bool) {
+	var session *cascadeSession
+	_cascadeSessions.Range(func(key, value interface{}) bool {
+		item := value.(*cascadeSession)
+		if item.dialog.Match(req) {
+			session = item
+			return false
+		}
+		return true
+	})
+	return session, session != nil
+}
+
+// 上级平台挂断点播
+func cascadeBye(session *cascadeSession, req *sip.Request) {
+	if err := session.dialog.ReceiveRequest(req); err != nil {
+		logrus.Warnln("platform bye dialog error,", session.PlatformID, session.ChannelID, err)
+	}
+	logrus.Infoln("platform bye,", session.PlatformID, session.ChannelID, session.StreamID)
+	if _, ok := _cascadeSessions.LoadAndDelete(session.dialog.ID()); ok {
+		stopCascade(session)
+	}
+}
+
+// 上级平台的回放控制，转发给设备
+func cascadeInfo(session *cascadeSession, req *sip.Request, tx *sip.Transaction) {
+	v, ok := StreamList.Response.Load(session.StreamID)
+	if !ok || session.T != 1 {
+		tx.Respond(sip.NewResponseFromRequest("", req, sip.StatusCallTransactionDoesNotExist, "Call/Transaction Does Not Exist", nil))
+		return
+	}
+	stream := v.(*Streams)
+	device, ok := _activeDevices.Get(stream.DeviceID)
+	if !ok || stream.Dialog == nil {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusNotFound, http.StatusText(http.StatusNotFound), nil))
+		return
+	}
+	stream.Dialog.SetDestination(device.source)
+	info, err := stream.Dialog.NewRequest(sip.INFO, &sip.ContentTypeRTSP, req.Body())
+	if err == nil {
+		var itx *sip.Transaction
+		if itx, err = srv.Request(info); err == nil {
+			_, err = sipResponse(itx)
+		}
+	}
+	if err != nil {
+		logrus.Warnln("platform info relay fail,", session.PlatformID, session.StreamID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil))
+		return
+	}
+	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+}
+
+// 停止向上级平台发送，回放流同时关闭，直播流无人观看时自动关闭
+func stopCascade(session *cascadeSession) {
+	values := url.Values{}
+	values.Set("secret", config.Media.Secret)
+	values.Set("vhost", "__defaultVhost__")
+	values.Set("app", "rtp")
+	values.Set("stream", session.StreamID)
+	values.Set("ssrc", session.ssrc)
+	if err := zlmStopSendRtp(values); err != nil {
+		logrus.Warnln("platform stop send rtp fail,", session.PlatformID, session.StreamID, err)
+	}
+	if session.T == 1 {
+		SipStopPlay(session.StreamID)
+	}
+}
+
+// 结束上级平台的所有点播，向上级平台发送BYE
+func stopPlatformInvites(platformID string) {
+	_cascadeSessions.Range(func(key, value interface{}) bool {
+		session := value.(*cascadeSession)
+		if session.PlatformID != platformID {
+			return true
+		}
+		_cascadeSessions.Delete(key)
+		if req, err := session.dialog.NewRequest(sip.BYE, nil, nil); err == nil {
+			if tx, err := srv.Request(req); err == nil {
+				if _, err := sipResponse(tx); err != nil {
+					logrus.Warnln("platform bye response error,", platformID, session.ChannelID, err)
+				}
+			}
+		}
+		stopCascade(session)
+		return true
+	})
+}
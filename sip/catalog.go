@@ -0,0 +1,161 @@
+package sipapi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// 目录变化事件 GB28181 A.2.5
+const (
+	// CatalogEventAdd 增加
+	CatalogEventAdd = "ADD"
+	// CatalogEventDel 删除
+	CatalogEventDel = "DEL"
+	// CatalogEventUpdate 更新
+	CatalogEventUpdate = "UPDATE"
+	// CatalogEventOn 上线
+	CatalogEventOn = "ON"
+	// CatalogEventOff 离线
+	CatalogEventOff = "OFF"
+	// CatalogEventVLost 视频丢失
+	CatalogEventVLost = "VLOST"
+	// CatalogEventDefect 故障
+	CatalogEventDefect = "DEFECT"
+)
+
+// MessageCatalogNotify 目录变化通知
+type MessageCatalogNotify struct {
+	CmdType  string              `xml:"CmdType"`
+	SN       int                 `xml:"SN"`
+	DeviceID string              `xml:"DeviceID"`
+	SumNum   int                 `xml:"SumNum"`
+	Item     []CatalogNotifyItem `xml:"DeviceList>Item"`
+}
+
+// CatalogNotifyItem 目录变化通知详情
+type CatalogNotifyItem struct {
+	Channels
+	Event string `xml:"Event"`
+}
+
+func sipNotifyCatalog(u Devices, body []byte) error {
+	message := &MessageCatalogNotify{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	device := Devices{DeviceID: u.DeviceID}
+	if err := db.Get(db.DBClient, &device); err != nil {
+		logrus.Warnln("catalog notify device not found,deviceid:", u.DeviceID, err)
+		return err
+	}
+	for _, item := range message.Item {
+		if err := catalogEvent(device, item); err != nil {
+			logrus.Warnln("catalog notify event fail,deviceid:", u.DeviceID, "channelid:", item.ChannelID, "event:", item.Event, err)
+		}
+	}
+	return nil
+}
+
+func catalogEvent(device Devices, item CatalogNotifyItem) error {
+	deviceID := device.DeviceID
+	if item.Event == "" {
+		// 部分设备变化通知不携带Event，与目录查询应答一样存在时更新、不存在时按配置创建
+		item.Event = CatalogEventAdd
+	}
+	if nodeType, ok := orgNodeType(item.ChannelID); ok {
+		return catalogNodeEvent(deviceID, nodeType, item)
+	}
+	channel := Channels{ChannelID: item.ChannelID, DeviceID: deviceID}
+	err := db.Get(db.DBClient, &channel)
+	if err != nil && !db.RecordNotFound(err) {
+		return err
+	}
+	exist := err == nil
+	switch item.Event {
+	case CatalogEventAdd, CatalogEventUpdate:
+		if !exist && item.Event == CatalogEventUpdate {
+			return err
+		}
+		if !exist {
+			// 设备新增的通道，根据设备配置自动创建
+			if !device.autoCreateChannels() || !isChannelID(item.ChannelID) {
+				return err
+			}
+			channel.StreamType = m.StreamTypePush
+		}
+		updateChannelFromCatalog(&channel, item.Channels)
+		if item.Status == "" {
+			channel.Status = m.DeviceStatusON
+		}
+	case CatalogEventDel:
+		if !exist {
+			return nil
+		}
+		if err := db.Del(db.DBClient, &Channels{DBModel: db.DBModel{ID: channel.ID}}); err != nil {
+			return err
+		}
+		db.DelQ(db.DBClient, new(Presets), db.M{"channelid=?": channel.ChannelID})
+		channel.Status = m.DeviceStatusOFF
+		go notify(notifyChannelsActive(channel))
+		return nil
+	case CatalogEventOn:
+		if !exist {
+			return err
+		}
+		channel.Active = time.Now().Unix()
+		channel.Status = m.DeviceStatusON
+	case CatalogEventOff, CatalogEventVLost, CatalogEventDefect:
+		// 视频丢失和故障时通道不可用，按离线处理
+		if !exist {
+			return err
+		}
+		channel.Status = m.DeviceStatusOFF
+	default:
+		logrus.Infoln("catalog notify event not support,", item.Event)
+		return nil
+	}
+	if err := db.Save(db.DBClient, &channel); err != nil {
+		return err
+	}
+	go notify(notifyChannelsActive(channel))
+	return nil
+}
+
+// 组织节点变化
+func catalogNodeEvent(deviceID, nodeType string, item CatalogNotifyItem) error {
+	switch item.Event {
+	case CatalogEventAdd, CatalogEventUpdate:
+		return saveCatalogNode(deviceID, nodeType, item.Channels)
+	case CatalogEventDel:
+		return db.DelQ(db.DBClient, new(Nodes), db.M{"deviceid=?": deviceID, "nodeid=?": item.ChannelID})
+	}
+	return nil
+}
+
+// 设备最后一次全量同步目录时间
+var _catalogSyncAt = &sync.Map{}
+
+// 全量同步设备目录
+func syncCatalog(device Devices) {
+	_catalogSyncAt.Store(device.DeviceID, time.Now().Unix())
+	sipCatalog(device)
+}
+
+// SyncCatalogs 定时全量同步在线设备目录
+func SyncCatalogs() {
+	now := time.Now().Unix()
+	_activeDevices.Range(func(key, value any) bool {
+		device := value.(Devices)
+		if last, ok := _catalogSyncAt.Load(device.DeviceID); ok && now-last.(int64) < int64(config.Catalog.Interval) {
+			return true
+		}
+		go syncCatalog(device)
+		return true
+	})
+}
@@ -0,0 +1,122 @@
+package sipapi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// 设备控制命令 GB28181 A.2.3
+const (
+	// ControlReboot 远程启动
+	ControlReboot = "reboot"
+	// ControlRecordStart 开始手动录像
+	ControlRecordStart = "recordstart"
+	// ControlRecordStop 停止手动录像
+	ControlRecordStop = "recordstop"
+	// ControlGuardSet 布防
+	ControlGuardSet = "guardset"
+	// ControlGuardReset 撤防
+	ControlGuardReset = "guardreset"
+	// ControlAlarmReset 报警复位
+	ControlAlarmReset = "alarmreset"
+)
+
+type deviceControl struct {
+	// xml 命令字段名和值
+	name, value string
+	// 设备是否会返回控制应答，远程启动设备直接重启不会应答
+	response bool
+}
+
+var deviceControls = map[string]deviceControl{
+	ControlReboot:      {name: "TeleBoot", value: "Boot"},
+	ControlRecordStart: {name: "RecordCmd", value: "Record", response: true},
+	ControlRecordStop:  {name: "RecordCmd", value: "StopRecord", response: true},
+	ControlGuardSet:    {name: "GuardCmd", value: "SetGuard", response: true},
+	ControlGuardReset:  {name: "GuardCmd", value: "ResetGuard", response: true},
+	ControlAlarmReset:  {name: "AlarmCmd", value: "ResetAlarm", response: true},
+}
+
+// 等待控制应答的请求 key:deviceid+sn
+var _controlResponses = &sync.Map{}
+
+// SipDevicesControl 对注册设备发送控制命令，返回设备应答的Result
+func SipDevicesControl(deviceID, cmd string) (string, error) {
+	device, ok := _activeDevices.Get(deviceID)
+	if !ok {
+		return "", errors.New("设备不在线")
+	}
+	return sipControl(device, device.addr, device.DeviceID, cmd)
+}
+
+// SipChannelsControl 对通道发送控制命令，返回设备应答的Result
+func SipChannelsControl(channel *Channels, cmd string) (string, error) {
+	device, ok := _activeDevices.Get(channel.DeviceID)
+	if !ok {
+		return "", errors.New("设备不在线")
+	}
+	channelURI, err := sip.ParseURI(channel.URIStr)
+	if err != nil {
+		return "", err
+	}
+	transURIScheme(channelURI, device.TransPort)
+	channel.addr = &sip.Address{URI: channelURI}
+	return sipControl(device, channel.addr, channel.ChannelID, cmd)
+}
+
+func sipControl(device Devices, to *sip.Address, id, cmd string) (string, error) {
+	control, ok := deviceControls[cmd]
+	if !ok {
+		return "", fmt.Errorf("不支持的控制命令:%s", cmd)
+	}
+	sn := utils.RandInt(100000, 999999)
+	key := fmt.Sprintf("%s%d", id, sn)
+	resp := make(chan string, 1)
+	if control.response {
+		_controlResponses.Store(key, resp)
+		defer _controlResponses.Delete(key)
+	}
+	if err := sipMessage(device, to, sip.GetDeviceControlXML(id, sn, control.name, control.value)); err != nil {
+		return "", err
+	}
+	if !control.response {
+		return "OK", nil
+	}
+	select {
+	case result := <-resp:
+		return result, nil
+	case <-time.After(10 * time.Second):
+		return "", errors.New("等待设备控制应答超时")
+	}
+}
+
+// MessageDeviceControlResponse 设备控制应答
+type MessageDeviceControlResponse struct {
+	CmdType  string `xml:"CmdType"`
+	SN       int    `xml:"SN"`
+	DeviceID string `xml:"DeviceID"`
+	Result   string `xml:"Result"`
+}
+
+func sipMessageDeviceControl(u Devices, body []byte) error {
+	message := &MessageDeviceControlResponse{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	v, ok := _controlResponses.Load(fmt.Sprintf("%s%d", message.DeviceID, message.SN))
+	if !ok {
+		return errors.New("devicecontrol request not found")
+	}
+	select {
+	case v.(chan string) <- message.Result:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package sipapi
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// 设备配置类型 GB28181 A.2.4.7
+const (
+	// ConfigBasicParam 基本参数
+	ConfigBasicParam = "BasicParam"
+	// ConfigVideoParamOpt 视频参数范围
+	ConfigVideoParamOpt = "VideoParamOpt"
+	// ConfigSVACEncode SVAC编码配置
+	ConfigSVACEncode = "SVACEncodeConfig"
+	// ConfigSnapShot 图像抓拍配置
+	ConfigSnapShot = "SnapShot"
+)
+
+var configTypes = map[string]bool{
+	ConfigBasicParam:    true,
+	ConfigVideoParamOpt: true,
+	ConfigSVACEncode:    true,
+	ConfigSnapShot:      true,
+}
+
+// DeviceConfigs 设备配置，只返回查询的配置类型
+type DeviceConfigs struct {
+	BasicParam       *BasicParam       `xml:"BasicParam" json:"basicparam,omitempty"`
+	VideoParamOpt    *VideoParamOpt    `xml:"VideoParamOpt" json:"videoparamopt,omitempty"`
+	SVACEncodeConfig *SVACEncodeConfig `xml:"SVACEncodeConfig" json:"svacencodeconfig,omitempty"`
+	SnapShot         *SnapShotConfig   `xml:"SnapShot" json:"snapshot,omitempty"`
+}
+
+// BasicParam 基本参数配置
+type BasicParam struct {
+	// Name 设备名称
+	Name string `xml:"Name,omitempty" json:"name"`
+	// Expiration 注册过期时间，秒
+	Expiration int `xml:"Expiration,omitempty" json:"expiration"`
+	// HeartBeatInterval 心跳间隔时间，秒
+	HeartBeatInterval int `xml:"HeartBeatInterval,omitempty" json:"heartbeatinterval"`
+	// HeartBeatCount 心跳超时次数
+	HeartBeatCount int `xml:"HeartBeatCount,omitempty" json:"heartbeatcount"`
+	// PositionCapability 定位功能支持情况 0不支持 1支持GPS 2支持北斗 3支持GPS和北斗
+	PositionCapability int `xml:"PositionCapability,omitempty" json:"positioncapability"`
+}
+
+// VideoParamOpt 视频参数范围
+type VideoParamOpt struct {
+	// DownloadSpeed 下载倍速范围，多个用/分隔，如1/2/4
+	DownloadSpeed string `xml:"DownloadSpeed" json:"downloadspeed"`
+	// Resolution 摄像机支持的分辨率，多个用/分隔
+	Resolution string `xml:"Resolution" json:"resolution"`
+}
+
+// SVACEncodeConfig SVAC编码配置
+type SVACEncodeConfig struct {
+	ROIParam struct {
+		ROIFlag            int `xml:"ROIFlag" json:"roiflag"`
+		ROINumber          int `xml:"ROINumber" json:"roinumber"`
+		BackGroundQP       int `xml:"BackGroundQP" json:"backgroundqp"`
+		BackGroundSkipFlag int `xml:"BackGroundSkipFlag" json:"backgroundskipflag"`
+		Item               []struct {
+			ROISeq      int `xml:"ROISeq" json:"roiseq"`
+			TopLeft     int `xml:"TopLeft" json:"topleft"`
+			BottomRight int `xml:"BottomRight" json:"bottomright"`
+			ROIQP       int `xml:"ROIQP" json:"roiqp"`
+		} `xml:"Item" json:"item"`
+	} `xml:"ROIParam" json:"roiparam"`
+	SVCParam struct {
+		SVCSpaceDomainMode  int `xml:"SVCSpaceDomainMode" json:"svcspacedomainmode"`
+		SVCTimeDomainMode   int `xml:"SVCTimeDomainMode" json:"svctimedomainmode"`
+		SVCSpaceSupportMode int `xml:"SVCSpaceSupportMode" json:"svcspacesupportmode"`
+		SVCTimeSupportMode  int `xml:"SVCTimeSupportMode" json:"svctimesupportmode"`
+	} `xml:"SVCParam" json:"svcparam"`
+	SurveillanceParam struct {
+		TimeFlag  int `xml:"TimeFlag" json:"timeflag"`
+		EventFlag int `xml:"EventFlag" json:"eventflag"`
+		AlertFlag int `xml:"AlertFlag" json:"alertflag"`
+	} `xml:"SurveillanceParam" json:"surveillanceparam"`
+	AudioParam struct {
+		AudioRecognitionFlag int `xml:"AudioRecognitionFlag" json:"audiorecognitionflag"`
+	} `xml:"AudioParam" json:"audioparam"`
+}
+
+// SnapShotConfig 图像抓拍配置
+type SnapShotConfig struct {
+	// SnapNum 连拍张数
+	SnapNum int `xml:"SnapNum" json:"snapnum"`
+	// Interval 单张抓拍间隔，秒
+	Interval int `xml:"Interval" json:"interval"`
+	// UploadURL 抓拍图像上传路径
+	UploadURL string `xml:"UploadURL" json:"uploadurl"`
+	// SessionID 会话ID
+	SessionID string `xml:"SessionID" json:"sessionid"`
+}
+
+// MessageDeviceConfigResponse 设备配置查询和设备配置应答
+type MessageDeviceConfigResponse struct {
+	DeviceConfigs
+	CmdType  string `xml:"CmdType"`
+	SN       int    `xml:"SN"`
+	DeviceID string `xml:"DeviceID"`
+	Result   string `xml:"Result"`
+}
+
+// 等待设备配置应答的请求 key:deviceid+sn
+var _configResponses = &sync.Map{}
+
+// SipConfigDownload 查询设备配置，types 为空时查询基本参数
+func SipConfigDownload(deviceID string, types []string) (*DeviceConfigs, error) {
+	if len(types) == 0 {
+		types = []string{ConfigBasicParam}
+	}
+	for _, t := range types {
+		if !configTypes[t] {
+			return nil, fmt.Errorf("不支持的配置类型:%s", t)
+		}
+	}
+	message, err := sipConfigRequest(deviceID, func(id string, sn int) []byte {
+		return sip.GetConfigDownloadXML(id, sn, strings.Join(types, "/"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if message.Result != "" && message.Result != "OK" {
+		return nil, fmt.Errorf("设备返回错误:%s", message.Result)
+	}
+	return &message.DeviceConfigs, nil
+}
+
+// SipDeviceConfig 修改设备基本参数，只发送不为空的参数，返回设备应答的Result
+func SipDeviceConfig(deviceID string, param BasicParam) (string, error) {
+	// 定位功能为设备能力，不可配置
+	param.PositionCapability = 0
+	if param == (BasicParam{}) {
+		return "", errors.New("配置参数不能为空")
+	}
+	data, err := xml.Marshal(param)
+	if err != nil {
+		return "", err
+	}
+	message, err := sipConfigRequest(deviceID, func(id string, sn int) []byte {
+		return sip.GetDeviceConfigXML(id, sn, string(data))
+	})
+	if err != nil {
+		return "", err
+	}
+	if message.Result == "OK" {
+		saveHeartBeatConfig(deviceID, param)
+	}
+	return message.Result, nil
+}
+
+// 保存设备配置成功的心跳参数，心跳超时检查按设备的心跳参数计算
+func saveHeartBeatConfig(deviceID string, param BasicParam) {
+	update := db.M{}
+	if param.HeartBeatInterval > 0 {
+		update["heartbeatinterval"] = param.HeartBeatInterval
+	}
+	if param.HeartBeatCount > 0 {
+		update["heartbeatcount"] = param.HeartBeatCount
+	}
+	if len(update) == 0 {
+		return
+	}
+	if _, err := db.UpdateAll(db.DBClient, new(Devices), db.M{"deviceid=?": deviceID}, update); err != nil {
+		logrus.Errorln("save device heartbeat config fail,id:", deviceID, err)
+	}
+}
+
+// 批量配置时同时等待应答的设备数
+const devicesConfigConcurrency = 32
+
+// DeviceConfigResult 批量配置中单个设备的结果
+type DeviceConfigResult struct {
+	DeviceID string `json:"deviceid"`
+	// Result 设备应答结果 OK/ERROR
+	Result string `json:"result"`
+	// Msg 下发失败原因，如设备不在线、应答超时
+	Msg string `json:"msg,omitempty"`
+}
+
+// SipDevicesConfig 并发修改多个设备的基本参数，deviceIDs 为空时修改所有在线设备，结果顺序与deviceIDs一致
+func SipDevicesConfig(deviceIDs []string, param BasicParam) ([]DeviceConfigResult, error) {
+	param.PositionCapability = 0
+	if param == (BasicParam{}) {
+		return nil, errors.New("配置参数不能为空")
+	}
+	if len(deviceIDs) == 0 {
+		_activeDevices.Range(func(key, value any) bool {
+			deviceIDs = append(deviceIDs, key.(string))
+			return true
+		})
+	}
+	results := make([]DeviceConfigResult, len(deviceIDs))
+	limit := make(chan struct{}, devicesConfigConcurrency)
+	wg := sync.WaitGroup{}
+	for i, id := range deviceIDs {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(i int, id string) {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+			results[i].DeviceID = id
+			result, err := SipDeviceConfig(id, param)
+			if err != nil {
+				results[i].Msg = err.Error()
+				return
+			}
+			results[i].Result = result
+		}(i, id)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// 发送配置请求并等待设备应答
+func sipConfigRequest(deviceID string, body func(id string, sn int) []byte) (*MessageDeviceConfigResponse, error) {
+	device, ok := _activeDevices.Get(deviceID)
+	if !ok {
+		return nil, errors.New("设备不在线")
+	}
+	sn := utils.RandInt(100000, 999999)
+	key := fmt.Sprintf("%s%d", device.DeviceID, sn)
+	resp := make(chan *MessageDeviceConfigResponse, 1)
+	_configResponses.Store(key, resp)
+	defer _configResponses.Delete(key)
+	if err := sipMessage(device, device.addr, body(device.DeviceID, sn)); err != nil {
+		return nil, err
+	}
+	select {
+	case message := <-resp:
+		return message, nil
+	case <-time.After(10 * time.Second):
+		return nil, errors.New("等待设备配置应答超时")
+	}
+}
+
+// 设备配置查询和设备配置应答
+func sipMessageDeviceConfig(u Devices, body []byte) error {
+	message := &MessageDeviceConfigResponse{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	v, ok := _configResponses.Load(fmt.Sprintf("%s%d", message.DeviceID, message.SN))
+	if !ok {
+		return errors.New("deviceconfig request not found")
+	}
+	select {
+	case v.(chan *MessageDeviceConfigResponse) <- message:
+	default:
+	}
+	return nil
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/xml"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/panjjo/gosip/db"
@@ -53,8 +56,20 @@ type Devices struct {
 	ActiveAt int64 `json:"active" gorm:"column:active"`
 	// Regist 是否注册
 	Regist bool `json:"regist"  gorm:"column:regist"`
+	// Expire 注册过期时间，0 未注册或已注销
+	Expire int64 `json:"expire" gorm:"column:expire"`
 	// PWD 密码
 	PWD string `json:"pwd" gorm:"column:pwd"`
+	// Algorithms 接受的摘要认证算法，为空时使用全局配置
+	Algorithms db.StringArray `json:"algorithms" gorm:"column:algorithms"`
+	// AutoCreate 自动创建目录中未注册的通道 0使用全局配置 1创建 -1不创建
+	AutoCreate int `json:"autocreate" gorm:"column:autocreate"`
+	// HeartBeatInterval 设备配置下发的心跳间隔(秒)，0使用全局配置
+	HeartBeatInterval int `json:"heartbeatinterval" gorm:"column:heartbeatinterval"`
+	// HeartBeatCount 设备配置下发的心跳超时次数，0使用全局配置
+	HeartBeatCount int `json:"heartbeatcount" gorm:"column:heartbeatcount"`
+	// DeviceStatus 最近一次查询的设备状态
+	DeviceStatus *DeviceStatus `json:"devicestatus" gorm:"column:devicestatus" sql:"type:json"`
 	// Source
 	Source string `json:"source"  gorm:"column:source"`
 
@@ -65,6 +80,62 @@ type Devices struct {
 	source net.Addr     `gorm:"-"`
 }
 
+// 设备接受的摘要认证算法，设备未配置时使用全局配置
+func (d Devices) authAlgorithms() []string {
+	algorithms := parseAlgorithms(d.Algorithms)
+	if len(algorithms) == 0 {
+		return config.Auth.Algorithms
+	}
+	return algorithms
+}
+
+// 是否自动创建目录中未注册的通道，设备未配置时使用全局配置
+func (d Devices) autoCreateChannels() bool {
+	if d.AutoCreate == 0 {
+		return config.Catalog.AutoCreate
+	}
+	return d.AutoCreate > 0
+}
+
+// 心跳超时时间(秒)，设备未配置心跳参数时使用全局配置
+func (d Devices) keepaliveTimeout() int64 {
+	interval, count := d.HeartBeatInterval, d.HeartBeatCount
+	if interval <= 0 {
+		interval = config.Keepalive.Interval
+	}
+	if count <= 0 {
+		count = config.Keepalive.Count
+	}
+	return int64(interval * count)
+}
+
+// ParseAuthAlgorithms 解析逗号分隔的摘要认证算法
+func ParseAuthAlgorithms(str string) (db.StringArray, error) {
+	algorithms := db.StringArray{}
+	for _, v := range strings.Split(str, ",") {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		algorithm, ok := sip.ParseAlgorithm(v)
+		if !ok {
+			return nil, fmt.Errorf("不支持的摘要算法:%s", v)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms, nil
+}
+
+// 过滤不支持的摘要算法并转换为标准名称
+func parseAlgorithms(list []string) []string {
+	algorithms := []string{}
+	for _, v := range list {
+		if algorithm, ok := sip.ParseAlgorithm(v); ok {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
 // Channels 摄像头通道信息
 type Channels struct {
 	db.DBModel
@@ -80,6 +151,10 @@ type Channels struct {
 	Model        string `xml:"Model" json:"model"  gorm:"column:model"`
 	Owner        string `xml:"Owner"  json:"owner"  gorm:"column:owner"`
 	CivilCode    string `xml:"CivilCode" json:"civilcode"  gorm:"column:civilcode"`
+	// ParentID 父节点编码（设备、业务分组或虚拟组织）
+	ParentID string `xml:"ParentID" json:"parentid"  gorm:"column:parentid"`
+	// BusinessGroupID 所属业务分组
+	BusinessGroupID string `xml:"BusinessGroupID" json:"businessgroupid"  gorm:"column:businessgroupid"`
 	// Address ip地址
 	Address     string `xml:"Address"  json:"address"  gorm:"column:address"`
 	Parental    int    `xml:"Parental"  json:"parental"  gorm:"column:parental"`
@@ -104,6 +179,10 @@ type Channels struct {
 	StreamType string `json:"streamtype"  gorm:"column:streamtype"`
 	// streamtype=pull时，拉流地址
 	URL string `json:"url"  gorm:"column:url"`
+	// 最新位置，移动设备上报
+	Longitude  float64 `xml:"-" json:"longitude"  gorm:"column:longitude"`
+	Latitude   float64 `xml:"-" json:"latitude"  gorm:"column:latitude"`
+	PositionAt int64   `xml:"-" json:"positionat"  gorm:"column:positionat"`
 
 	addr *sip.Address `gorm:"-"`
 }
@@ -186,10 +265,12 @@ func parserDevicesFromReqeust(req *sip.Request) (Devices, bool) {
 
 // 获取设备信息（注册设备）
 func sipDeviceInfo(to Devices) {
-	hb := sip.NewHeaderBuilder().SetTo(to.addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
-		Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+	addr := deviceAddress(to.addr, to.TransPort)
+	hb := sip.NewHeaderBuilder().SetTo(addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
+		Transport: to.TransPort,
+		Params:    sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
 	}).SetContentType(&sip.ContentTypeXML).SetMethod(sip.MESSAGE)
-	req := sip.NewRequest("", sip.MESSAGE, to.addr.URI, sip.DefaultSipVersion, hb.Build(), sip.GetDeviceInfoXML(to.DeviceID))
+	req := sip.NewRequest("", sip.MESSAGE, addr.URI, sip.DefaultSipVersion, hb.Build(), sip.GetDeviceInfoXML(to.DeviceID))
 	req.SetDestination(to.source)
 	tx, err := srv.Request(req)
 	if err != nil {
@@ -205,10 +286,12 @@ func sipDeviceInfo(to Devices) {
 
 // sipCatalog 获取注册设备包含的列表
 func sipCatalog(to Devices) {
-	hb := sip.NewHeaderBuilder().SetTo(to.addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
-		Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+	addr := deviceAddress(to.addr, to.TransPort)
+	hb := sip.NewHeaderBuilder().SetTo(addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
+		Transport: to.TransPort,
+		Params:    sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
 	}).SetContentType(&sip.ContentTypeXML).SetMethod(sip.MESSAGE)
-	req := sip.NewRequest("", sip.MESSAGE, to.addr.URI, sip.DefaultSipVersion, hb.Build(), sip.GetCatalogXML(to.DeviceID))
+	req := sip.NewRequest("", sip.MESSAGE, addr.URI, sip.DefaultSipVersion, hb.Build(), sip.GetCatalogXML(to.DeviceID))
 	req.SetDestination(to.source)
 	tx, err := srv.Request(req)
 	if err != nil {
@@ -264,34 +347,164 @@ func sipMessageCatalog(u Devices, body []byte) error {
 		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
 		return err
 	}
-	if message.SumNum > 0 {
-		for _, d := range message.Item {
-			channel := Channels{ChannelID: d.ChannelID, DeviceID: message.DeviceID}
-			if err := db.Get(db.DBClient, &channel); err == nil {
-				channel.Active = time.Now().Unix()
-				channel.URIStr = fmt.Sprintf("sip:%s@%s", d.ChannelID, _sysinfo.Region)
-				channel.Status = transDeviceStatus(d.Status)
-				channel.Name = d.Name
-				channel.Manufacturer = d.Manufacturer
-				channel.Model = d.Model
-				channel.Owner = d.Owner
-				channel.CivilCode = d.CivilCode
-				// Address ip地址
-				channel.Address = d.Address
-				channel.Parental = d.Parental
-				channel.SafetyWay = d.SafetyWay
-				channel.RegisterWay = d.RegisterWay
-				channel.Secrecy = d.Secrecy
-				db.Save(db.DBClient, &channel)
-				go notify(notifyChannelsActive(channel))
-			} else {
-				logrus.Infoln("deviceid not found,deviceid:", d.DeviceID, "pdid:", message.DeviceID, "err", err)
+	// 目录分多个包返回，按SN收集完整后再处理
+	key := fmt.Sprintf("%s%d", message.DeviceID, message.SN)
+	v, _ := _catalogList.LoadOrStore(key, &catalogList{deviceID: u.DeviceID, sumNum: message.SumNum})
+	list := v.(*catalogList)
+	list.l.Lock()
+	defer list.l.Unlock()
+	if list.done {
+		return nil
+	}
+	if list.timer == nil {
+		list.timer = time.AfterFunc(catalogTimeout, func() {
+			// 超时未收齐，只处理已收到的部分
+			list.l.Lock()
+			defer list.l.Unlock()
+			if list.done {
+				return
 			}
-		}
+			list.done = true
+			_catalogList.Delete(key)
+			logrus.Warnln("catalog incomplete,deviceid:", list.deviceID, "sumnum:", list.sumNum, "received:", len(list.items))
+			go applyCatalog(list.deviceID, list.items, false)
+		})
+	}
+	list.items = append(list.items, message.Item...)
+	if len(list.items) >= list.sumNum {
+		list.done = true
+		list.timer.Stop()
+		_catalogList.Delete(key)
+		go applyCatalog(list.deviceID, list.items, true)
 	}
 	return nil
 }
 
+// 等待目录分包的最长时间
+const catalogTimeout = 30 * time.Second
+
+type catalogList struct {
+	deviceID string
+	sumNum   int
+	items    []Channels
+	done     bool
+	timer    *time.Timer
+	l        sync.Mutex
+}
+
+// 正在接收的目录 key:deviceid+sn
+var _catalogList = &sync.Map{}
+
+// 处理目录，更新已存在的通道，根据设备配置创建新通道，完整目录时可将不存在的通道标记为已移除
+func applyCatalog(deviceID string, items []Channels, complete bool) {
+	device := Devices{DeviceID: deviceID}
+	if err := db.Get(db.DBClient, &device); err != nil {
+		logrus.Warnln("catalog device not found,deviceid:", deviceID, err)
+		return
+	}
+	autoCreate := device.autoCreateChannels()
+	reported := map[string]bool{}
+	for _, d := range items {
+		reported[d.ChannelID] = true
+		if nodeType, ok := orgNodeType(d.ChannelID); ok {
+			// 组织节点
+			if err := saveCatalogNode(deviceID, nodeType, d); err != nil {
+				logrus.Errorln("catalog save node fail,deviceid:", deviceID, "nodeid:", d.ChannelID, err)
+			}
+			continue
+		}
+		channel := Channels{ChannelID: d.ChannelID, DeviceID: deviceID}
+		err := db.Get(db.DBClient, &channel)
+		if err != nil {
+			if !db.RecordNotFound(err) {
+				logrus.Errorln("catalog get channel fail,deviceid:", deviceID, "channelid:", d.ChannelID, err)
+				continue
+			}
+			if !autoCreate || !isChannelID(d.ChannelID) {
+				logrus.Infoln("deviceid not found,deviceid:", d.ChannelID, "pdid:", deviceID, "err", err)
+				continue
+			}
+			// 自动创建通道
+			channel.StreamType = m.StreamTypePush
+			logrus.Infoln("catalog auto create channel,deviceid:", deviceID, "channelid:", d.ChannelID)
+		}
+		updateChannelFromCatalog(&channel, d)
+		db.Save(db.DBClient, &channel)
+		go notify(notifyChannelsActive(channel))
+	}
+	if !complete {
+		return
+	}
+	removeMissingNodes(deviceID, reported)
+	if !config.Catalog.RemoveMissing {
+		return
+	}
+	channels := []Channels{}
+	if _, err := db.FindT(db.DBClient, new(Channels), &channels, db.M{"deviceid=?": deviceID, "status<>?": m.DeviceStatusREMOVED}, "", 0, -1, false); err != nil {
+		logrus.Errorln("catalog find channels fail,deviceid:", deviceID, err)
+		return
+	}
+	for _, channel := range channels {
+		if reported[channel.ChannelID] {
+			continue
+		}
+		channel.Status = m.DeviceStatusREMOVED
+		db.UpdateAll(db.DBClient, new(Channels), db.M{"id=?": channel.ID}, db.M{"status": channel.Status})
+		logrus.Infoln("catalog channel removed,deviceid:", deviceID, "channelid:", channel.ChannelID)
+		go notify(notifyChannelsActive(channel))
+	}
+}
+
+// 目录项是否为通道（前端外围设备，类型编码131-199），行政区划、业务分组、虚拟组织等节点不创建通道
+func isChannelID(id string) bool {
+	if len(id) != 20 {
+		return false
+	}
+	t, err := strconv.Atoi(id[10:13])
+	if err != nil {
+		return false
+	}
+	return t >= 131 && t <= 199
+}
+
+// 使用目录信息更新通道
+func updateChannelFromCatalog(channel *Channels, d Channels) {
+	channel.Active = time.Now().Unix()
+	channel.URIStr = fmt.Sprintf("sip:%s@%s", d.ChannelID, _sysinfo.Region)
+	channel.Status = transDeviceStatus(d.Status)
+	channel.Name = d.Name
+	channel.Manufacturer = d.Manufacturer
+	channel.Model = d.Model
+	channel.Owner = d.Owner
+	channel.CivilCode = d.CivilCode
+	channel.ParentID = d.ParentID
+	channel.BusinessGroupID = d.BusinessGroupID
+	// Address ip地址
+	channel.Address = d.Address
+	channel.Parental = d.Parental
+	channel.SafetyWay = d.SafetyWay
+	channel.RegisterWay = d.RegisterWay
+	channel.Secrecy = d.Secrecy
+}
+
+// transURIScheme 通过tls注册的设备请求时使用sips uri
+func transURIScheme(uri *sip.URI, transport string) {
+	if uri == nil {
+		return
+	}
+	uri.SetEncrypted(strings.EqualFold(transport, "TLS"))
+}
+
+// deviceAddress 向设备发送请求的To地址，复制后按传输协议设置uri scheme
+func deviceAddress(addr *sip.Address, transport string) *sip.Address {
+	if addr == nil {
+		return nil
+	}
+	to := addr.Clone()
+	transURIScheme(to.URI, transport)
+	return to
+}
+
 var deviceStatusMap = map[string]string{
 	"ON":     m.DeviceStatusON,
 	"OK":     m.DeviceStatusON,
@@ -0,0 +1,176 @@
+package sipapi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// 媒体通知类型 121 历史媒体文件发送结束
+const mediaStatusFileEnd = "121"
+
+// 默认下载倍速
+const defaultDownloadSpeed = 4
+
+// SipDownload 下载历史视频，设备按倍速发送，收到流后录制为mp4
+func SipDownload(channelID string, start, end int64, speed int) (*Streams, error) {
+	if speed <= 0 {
+		speed = defaultDownloadSpeed
+	}
+	return SipPlay(&Streams{T: 2, ChannelID: channelID, S: time.Unix(start, 0), E: time.Unix(end, 0), Speed: speed})
+}
+
+// Downloading 下载流是否未完成，未完成时无人观看也不关闭
+func (s *Streams) Downloading() bool {
+	return s.T == 2 && s.Progress < 100
+}
+
+// DownloadProgress 下载进度，设备通知发送结束前按倍速估算，最多99
+func (s *Streams) DownloadProgress() int {
+	if s.Progress >= 100 || s.End <= s.Start {
+		return s.Progress
+	}
+	progress := int(s.PlaybackPosition() * 100 / float64(s.End-s.Start))
+	if progress > 99 {
+		progress = 99
+	}
+	return progress
+}
+
+// StartDownloadRecord 下载流注册后开始录制mp4
+func StartDownloadRecord(stream *Streams) {
+	values := url.Values{}
+	values.Set("secret", config.Media.Secret)
+	values.Set("type", "1")
+	values.Set("vhost", "__defaultVhost__")
+	values.Set("app", "rtp")
+	values.Set("stream", stream.StreamID)
+	// 按实时速度计算切片时长，保证只生成一个文件
+	values.Set("max_second", strconv.FormatInt(stream.End-stream.Start+300, 10))
+	if err := zlmStartRecord(values); err != nil {
+		logrus.Errorln("startDownloadRecord fail,", stream.StreamID, err)
+		stream.Msg = fmt.Sprint(err)
+		db.Save(db.DBClient, stream)
+	}
+}
+
+// MessageMediaStatus 媒体通知
+type MessageMediaStatus struct {
+	CmdType    string `xml:"CmdType"`
+	SN         int    `xml:"SN"`
+	DeviceID   string `xml:"DeviceID"`
+	NotifyType string `xml:"NotifyType"`
+}
+
+// 设备通知历史媒体文件发送结束，下载流停止录制，录制完成后关闭流
+func sipMessageMediaStatus(req *sip.Request, body []byte) error {
+	message := &MessageMediaStatus{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	if message.NotifyType != mediaStatusFileEnd {
+		return nil
+	}
+	stream, ok := getMediaStatusStream(req, message.DeviceID)
+	if !ok {
+		return errors.New("mediastatus stream not found")
+	}
+	logrus.Infoln("mediastatus file end,", stream.ChannelID, stream.StreamID, stream.T)
+	if stream.T != 2 {
+		return nil
+	}
+	values := url.Values{}
+	values.Set("secret", config.Media.Secret)
+	values.Set("type", "1")
+	values.Set("vhost", "__defaultVhost__")
+	values.Set("app", "rtp")
+	values.Set("stream", stream.StreamID)
+	if err := zlmStopRecord(values); err != nil {
+		// 未在录制，直接关闭流
+		logrus.Warnln("mediastatus stop record fail,", stream.StreamID, err)
+		SipStopPlay(stream.StreamID)
+	}
+	return nil
+}
+
+// 媒体通知对应的流，优先按Call-ID查找，设备不在对话内发送时按通道查找
+func getMediaStatusStream(req *sip.Request, channelID string) (*Streams, bool) {
+	var stream, byChannel *Streams
+	callid, _ := req.CallID()
+	StreamList.Response.Range(func(key, value interface{}) bool {
+		item := value.(*Streams)
+		if callid != nil && item.CallID == string(*callid) {
+			stream = item
+			return false
+		}
+		if item.ChannelID == channelID && item.Downloading() {
+			byChannel = item
+		}
+		return true
+	})
+	if stream == nil {
+		stream = byChannel
+	}
+	return stream, stream != nil
+}
+
+// DownloadRecorded 下载流录制完成，保存文件地址并关闭流，不是下载流时返回false
+func DownloadRecorded(streamID, file string) bool {
+	var stream *Streams
+	if v, ok := StreamList.Response.Load(streamID); ok {
+		stream = v.(*Streams)
+	} else {
+		// 流已关闭后才录制完成
+		streams := []Streams{}
+		db.FindT(db.DBClient, new(Streams), &streams, db.M{"streamid=?": streamID, "t=?": 2, "file=?": ""}, "-id", 0, 1, false)
+		if len(streams) == 0 {
+			return false
+		}
+		stream = &streams[0]
+	}
+	if stream.T != 2 {
+		return false
+	}
+	stream.Progress = 100
+	stream.File = fmt.Sprintf("%s/%s", config.Media.HTTP, file)
+	db.Save(db.DBClient, stream)
+	// 按录制文件过期时间清理
+	if err := db.Create(db.DBClient, &Files{
+		FID:    utils.RandString(32),
+		Stream: stream.StreamID,
+		Start:  time.Now().Unix(),
+		End:    time.Now().Unix(),
+		Status: 1,
+		File:   file,
+	}); err != nil {
+		logrus.Errorln("save download file fail,", stream.StreamID, err)
+	}
+	SipStopPlay(streamID)
+	go notify(notifyDownloadsDone(*stream))
+	return true
+}
+
+// GetDownload 下载任务，未完成时返回估算进度，流id会复用，已关闭时取最近一次下载
+func GetDownload(streamID string) (*Streams, error) {
+	if v, ok := StreamList.Response.Load(streamID); ok && v.(*Streams).T == 2 {
+		stream := *v.(*Streams)
+		stream.Progress = stream.DownloadProgress()
+		return &stream, nil
+	}
+	streams := []Streams{}
+	if _, err := db.FindT(db.DBClient, new(Streams), &streams, db.M{"streamid=?": streamID, "t=?": 2}, "-id", 0, 1, false); err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, errors.New("下载任务不存在")
+	}
+	return &streams[0], nil
+}
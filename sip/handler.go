@@ -3,6 +3,8 @@ package sipapi
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/panjjo/gosip/db"
 	sip "github.com/panjjo/gosip/sip/s"
@@ -17,6 +19,15 @@ type MessageReceive struct {
 }
 
 func handlerMessage(req *sip.Request, tx *sip.Transaction) {
+	if p, ok := platformFromRequest(req); ok {
+		if p == nil {
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil))
+			return
+		}
+		// 上级平台的查询
+		platformMessage(p, req, tx)
+		return
+	}
 	u, ok := parserDevicesFromReqeust(req)
 	if !ok {
 		// 未解析出来源用户返回错误
@@ -29,21 +40,10 @@ func handlerMessage(req *sip.Request, tx *sip.Transaction) {
 		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
 		return
 	}
-	body := req.Body()
-	message := &MessageReceive{}
-
-	if err := utils.XMLDecode(body, message); err != nil {
-		logrus.Warnln("Message Unmarshal xml err:", err, "body:", string(body))
-		// 有些body xml发送过来的不带encoding ，而且格式不是utf8的，导致xml解析失败，此处使用gbk转utf8后再次尝试xml解析
-		body, err = utils.GbkToUtf8(body)
-		if err != nil {
-			logrus.Errorln("message gbk to utf8 err", err)
-		}
-		if err := utils.XMLDecode(body, message); err != nil {
-			logrus.Errorln("Message Unmarshal xml after gbktoutf8 err:", err, "body:", string(body))
-			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
-			return
-		}
+	body, message, err := decodeMessageBody(req.Body())
+	if err != nil {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		return
 	}
 	switch message.CmdType {
 	case "Catalog":
@@ -53,10 +53,15 @@ func handlerMessage(req *sip.Request, tx *sip.Transaction) {
 		return
 	case "Keepalive":
 		// heardbeat
-		if err := sipMessageKeepalive(u, body); err == nil {
+		switch err := sipMessageKeepalive(u, body); err {
+		case nil:
 			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
-			// 心跳后同步注册设备列表信息
-			sipCatalog(u)
+			return
+		case errKeepaliveNotFound:
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusNotFound, http.StatusText(http.StatusNotFound), nil))
+			return
+		case errKeepaliveUnregistered:
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil))
 			return
 		}
 	case "RecordInfo":
@@ -68,6 +73,105 @@ func handlerMessage(req *sip.Request, tx *sip.Transaction) {
 		sipMessageDeviceInfo(u, body)
 		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
 		return
+	case "DeviceStatus":
+		// 设备状态
+		sipMessageDeviceStatus(u, body)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	case "ConfigDownload", "DeviceConfig":
+		// 设备配置查询应答、设备配置应答
+		sipMessageDeviceConfig(u, body)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	case "DeviceControl":
+		// 设备控制应答
+		sipMessageDeviceControl(u, body)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	case "Alarm":
+		// 报警通知，回复200后发送报警通知应答
+		if alarm, err := sipMessageAlarm(u, body); err == nil {
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+			go sipAlarmResponse(u, alarm)
+			return
+		}
+	case "MobilePosition":
+		// 移动设备位置
+		sipMessageMobilePosition(u, body)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	case "MediaStatus":
+		// 媒体通知，历史媒体文件发送结束
+		sipMessageMediaStatus(req, body)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	case "Broadcast":
+		// 语音广播应答
+		sipMessageBroadcast(u, body)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	case "PresetQuery":
+		// 通道预置位列表
+		sipMessagePresetQuery(u, body)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	}
+	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+}
+
+// 解析MESSAGE/NOTIFY的xml body，返回可能经过gbk转码后的body
+func decodeMessageBody(body []byte) ([]byte, *MessageReceive, error) {
+	message := &MessageReceive{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Warnln("Message Unmarshal xml err:", err, "body:", string(body))
+		// 有些body xml发送过来的不带encoding ，而且格式不是utf8的，导致xml解析失败，此处使用gbk转utf8后再次尝试xml解析
+		body, err = utils.GbkToUtf8(body)
+		if err != nil {
+			logrus.Errorln("message gbk to utf8 err", err)
+		}
+		if err := utils.XMLDecode(body, message); err != nil {
+			logrus.Errorln("Message Unmarshal xml after gbktoutf8 err:", err, "body:", string(body))
+			return nil, nil, err
+		}
+	}
+	return body, message, nil
+}
+
+// 订阅的事件通知
+func handlerNotify(req *sip.Request, tx *sip.Transaction) {
+	u, ok := parserDevicesFromReqeust(req)
+	if !ok {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		return
+	}
+	if len, have := req.ContentLength(); !have || len.Equals(0) {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		return
+	}
+	body, message, err := decodeMessageBody(req.Body())
+	if err != nil {
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
+		return
+	}
+	switch message.CmdType {
+	case "Alarm":
+		// 订阅的报警通知只需回复200
+		if _, err := sipMessageAlarm(u, body); err == nil {
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+			return
+		}
+	case "MobilePosition":
+		// 订阅的移动设备位置
+		if err := sipMessageMobilePosition(u, body); err == nil {
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+			return
+		}
+	case "Catalog":
+		// 订阅的目录变化通知
+		if err := sipNotifyCatalog(u, body); err == nil {
+			tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+			return
+		}
 	}
 	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), nil))
 }
@@ -86,6 +190,7 @@ func handlerRegister(req *sip.Request, tx *sip.Transaction) {
 				fromUser.ID = user.ID
 				fromUser.Name = user.Name
 				fromUser.PWD = user.PWD
+				fromUser.Algorithms = user.Algorithms
 				user = fromUser
 			}
 			user.addr = fromUser.addr
@@ -95,27 +200,189 @@ func handlerRegister(req *sip.Request, tx *sip.Transaction) {
 			auth.SetUsername(user.DeviceID)
 			auth.SetMethod(string(req.Method()))
 			auth.SetURI(auth.Get("uri"))
+			algorithms := user.authAlgorithms()
+			if !acceptAlgorithm(algorithms, auth.Algorithm()) {
+				logrus.Warnln("register algorithm not accepted,id:", user.DeviceID, "algorithm:", auth.Algorithm())
+				registerUnauthorized(req, tx, algorithms, false)
+				return
+			}
 			if auth.CalcResponse() == auth.Get("response") {
+				// 摘要正确，校验nonce防止重放
+				if err := _nonces.Check(auth.Get("nonce"), auth.Get("qop"), auth.Get("nc")); err != nil {
+					logrus.Warnln("register nonce check fail,id:", user.DeviceID, "err:", err)
+					registerUnauthorized(req, tx, algorithms, err == sip.ErrNonceStale)
+					return
+				}
 				// 验证成功
+				expires := registerExpires(req)
+				if expires == 0 {
+					// 注销
+					tx.Respond(registerResponse(req, expires))
+					unregisterDevice(user.DeviceID, "logout")
+					return
+				}
 				// 记录活跃设备
 				user.source = fromUser.source
 				user.addr = fromUser.addr
+				user.TransPort = fromUser.TransPort
+				user.Expire = time.Now().Unix() + int64(expires)
+				// 注册成功作为一次心跳
+				user.ActiveAt = time.Now().Unix()
+				_, online := _activeDevices.Get(user.DeviceID)
 				_activeDevices.Store(user.DeviceID, user)
 				if !user.Regist {
 					// 第一次激活，保存数据库
 					user.Regist = true
 					db.DBClient.Save(&user)
 					logrus.Infoln("new user regist,id:", user.DeviceID)
+				} else {
+					db.UpdateAll(db.DBClient, new(Devices), db.M{"deviceid=?": user.DeviceID}, db.M{"expire": user.Expire, "active": user.ActiveAt})
 				}
-				tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+				tx.Respond(registerResponse(req, expires))
 				// 注册成功后查询设备信息，获取制作厂商等信息
 				go notify(notifyDevicesRegister(user))
 				go sipDeviceInfo(fromUser)
+				if !online {
+					// 设备上线，同步目录并恢复事件订阅
+					go syncCatalog(user)
+					go subscribeDevice(user)
+				}
 				return
 			}
 		}
 	}
+	algorithms := config.Auth.Algorithms
+	if fromUser, ok := parserDevicesFromReqeust(req); ok {
+		user := Devices{DeviceID: fromUser.DeviceID}
+		if err := db.Get(db.DBClient, &user); err == nil {
+			algorithms = user.authAlgorithms()
+		}
+	}
+	registerUnauthorized(req, tx, algorithms, false)
+}
+
+// 注册有效期，Contact 的expires参数优先于Expires头域，都不存在时使用默认值 RFC 3261 10.2.1.1
+func registerExpires(req *sip.Request) uint32 {
+	if contact, ok := req.Contact(); ok && contact.Params != nil {
+		if v, ok := contact.Params.Get("expires"); ok && v != nil {
+			if expires, err := strconv.ParseUint(v.String(), 10, 32); err == nil {
+				return uint32(expires)
+			}
+		}
+	}
+	if hdrs := req.GetHeaders("Expires"); len(hdrs) > 0 {
+		if expires, ok := hdrs[0].(*sip.Expires); ok {
+			return uint32(*expires)
+		}
+	}
+	return defaultRegisterExpires
+}
+
+// 注册成功响应，携带实际的有效期和用于设备校时的Date头域
+func registerResponse(req *sip.Request, expires uint32) *sip.Response {
+	resp := sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil)
+	exp := sip.Expires(expires)
+	resp.AppendHeader(&exp)
+	resp.AppendHeader(&sip.GenericHeader{HeaderName: "Date", Contents: time.Now().Format("2006-01-02T15:04:05.000")})
+	return resp
+}
+
+// 回复401，每个接受的算法一个认证质询，stale=true 表示摘要正确但nonce已过期，设备使用新的nonce重新计算即可
+func registerUnauthorized(req *sip.Request, tx *sip.Transaction, algorithms []string, stale bool) {
 	resp := sip.NewResponseFromRequest("", req, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), nil)
-	resp.AppendHeader(&sip.GenericHeader{HeaderName: "WWW-Authenticate", Contents: fmt.Sprintf("Digest nonce=\"%s\", algorithm=MD5, realm=\"%s\",qop=\"auth\"", utils.RandString(32), _sysinfo.Region)})
+	nonce := _nonces.New()
+	for _, algorithm := range algorithms {
+		contents := fmt.Sprintf("Digest nonce=\"%s\", algorithm=%s, realm=\"%s\",qop=\"auth\"", nonce, algorithm, _sysinfo.Region)
+		if stale {
+			contents += ",stale=true"
+		}
+		resp.AppendHeader(&sip.GenericHeader{HeaderName: "WWW-Authenticate", Contents: contents})
+	}
 	tx.Respond(resp)
 }
+
+func acceptAlgorithm(algorithms []string, algorithm string) bool {
+	algorithm, ok := sip.ParseAlgorithm(algorithm)
+	if !ok {
+		return false
+	}
+	for _, v := range algorithms {
+		if v == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// 设备主动结束对话(停止推流)
+func handlerBye(req *sip.Request, tx *sip.Transaction) {
+	if session, ok := getTalkByDialog(req); ok {
+		// 设备挂断语音会话
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		talkBye(session, req)
+		return
+	}
+	if session, ok := getCascadeByDialog(req); ok {
+		// 上级平台挂断点播
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+		cascadeBye(session, req)
+		return
+	}
+	stream, ok := getStreamByDialog(req)
+	if !ok {
+		tx.Respond(sip.NewResponseFromRequest("", req, sip.StatusCallTransactionDoesNotExist, "Call/Transaction Does Not Exist", nil))
+		return
+	}
+	if err := stream.Dialog.ReceiveRequest(req); err != nil {
+		logrus.Warnln("handlerBye dialog error,", stream.StreamID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil))
+		return
+	}
+	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+	logrus.Infoln("device bye,", stream.DeviceID, stream.ChannelID, stream.StreamID)
+	closeStreamByDevice(stream, "device bye")
+}
+
+// 设备取消尚未完成的INVITE请求，对CANCEL回复200，INVITE回复487
+func handlerCancel(req *sip.Request, tx *sip.Transaction) {
+	inviteTX := srv.CancelTX(req)
+	if inviteTX == nil {
+		tx.Respond(sip.NewResponseFromRequest("", req, sip.StatusCallTransactionDoesNotExist, "Call/Transaction Does Not Exist", nil))
+		return
+	}
+	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+	// INVITE 已经回复最终响应时不再处理
+	inviteTX.Respond(sip.NewResponseFromRequest("", inviteTX.Origin(), sip.StatusRequestTerminated, "Request Terminated", nil))
+}
+
+// 2xx 的ACK，不需要回复
+func handlerAck(req *sip.Request, tx *sip.Transaction) {
+	if stream, ok := getStreamByDialog(req); ok {
+		logrus.Traceln("receive ack,", stream.DeviceID, stream.ChannelID, stream.StreamID)
+	} else if session, ok := getTalkByDialog(req); ok {
+		logrus.Traceln("receive talk ack,", session.DeviceID, session.ChannelID)
+	} else if session, ok := getCascadeByDialog(req); ok {
+		logrus.Traceln("receive platform ack,", session.PlatformID, session.ChannelID, session.StreamID)
+	}
+}
+
+// 对话内的INFO请求
+func handlerInfo(req *sip.Request, tx *sip.Transaction) {
+	if session, ok := getCascadeByDialog(req); ok {
+		// 上级平台的回放控制
+		cascadeInfo(session, req, tx)
+		return
+	}
+	stream, ok := getStreamByDialog(req)
+	if !ok {
+		tx.Respond(sip.NewResponseFromRequest("", req, sip.StatusCallTransactionDoesNotExist, "Call/Transaction Does Not Exist", nil))
+		return
+	}
+	if err := stream.Dialog.ReceiveRequest(req); err != nil {
+		logrus.Warnln("handlerInfo dialog error,", stream.StreamID, err)
+		tx.Respond(sip.NewResponseFromRequest("", req, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil))
+		return
+	}
+	logrus.Debugln("receive info,", stream.DeviceID, stream.ChannelID, stream.StreamID, string(req.Body()))
+	tx.Respond(sip.NewResponseFromRequest("", req, http.StatusOK, "OK", nil))
+}
@@ -1,9 +1,11 @@
 package sipapi
 
 import (
+	"errors"
 	"time"
 
 	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
 	"github.com/panjjo/gosip/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -17,35 +19,102 @@ type MessageNotify struct {
 	Info     string `xml:"Info"`
 }
 
+var (
+	// 心跳来自不存在的设备
+	errKeepaliveNotFound = errors.New("device not found")
+	// 心跳来自未注册或注册已过期的设备
+	errKeepaliveUnregistered = errors.New("device not registered")
+)
+
 func sipMessageKeepalive(u Devices, body []byte) error {
 	message := &MessageNotify{}
 	if err := utils.XMLDecode(body, message); err != nil {
 		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
 		return err
 	}
-	device, ok := _activeDevices.Get(u.DeviceID)
-	if !ok {
+	device, active := _activeDevices.Get(u.DeviceID)
+	if !active {
 		device = Devices{DeviceID: u.DeviceID}
 		if err := db.Get(db.DBClient, &device); err != nil {
 			logrus.Warnln("Device Keepalive not found ", u.DeviceID, err)
+			if db.RecordNotFound(err) {
+				return errKeepaliveNotFound
+			}
+			return err
 		}
 	}
+	if device.Expire <= time.Now().Unix() {
+		// 未注册、已注销或注册过期的设备需重新注册
+		logrus.Warnln("Device Keepalive not registered ", u.DeviceID)
+		return errKeepaliveUnregistered
+	}
 	if message.Status == "OK" {
 		device.ActiveAt = time.Now().Unix()
-		_activeDevices.Store(u.DeviceID, u)
+		device.source = u.source
+		device.addr = u.addr
+		device.TransPort = u.TransPort
+		_activeDevices.Store(u.DeviceID, device)
+		if !active {
+			// 心跳超时离线后恢复，离线时通道已置为离线，立即同步目录刷新通道状态
+			go syncCatalog(device)
+		}
 	} else {
 		device.ActiveAt = -1
 		_activeDevices.Delete(u.DeviceID)
 	}
 	go notify(notifyDevicesAcitve(u.DeviceID, message.Status))
 	_, err := db.UpdateAll(db.DBClient, new(Devices), map[string]interface{}{"deviceid=?": u.DeviceID}, Devices{
-		Host:     u.Host,
-		Port:     u.Port,
-		Rport:    u.Rport,
-		RAddr:    u.RAddr,
-		Source:   u.Source,
-		URIStr:   u.URIStr,
-		ActiveAt: device.ActiveAt,
+		Host:      u.Host,
+		Port:      u.Port,
+		TransPort: u.TransPort,
+		Rport:     u.Rport,
+		RAddr:     u.RAddr,
+		Source:    u.Source,
+		URIStr:    u.URIStr,
+		ActiveAt:  device.ActiveAt,
 	})
 	return err
 }
+
+// CheckKeepalive 定时检查心跳超时的设备，超时设备及其通道置为离线
+func CheckKeepalive() {
+	var skip int
+	now := time.Now().Unix()
+	// 设备配置了心跳参数时按设备的心跳间隔和次数计算超时
+	timeout := "active<?-(CASE WHEN heartbeatinterval>0 THEN heartbeatinterval ELSE ? END)*(CASE WHEN heartbeatcount>0 THEN heartbeatcount ELSE ? END)"
+	for {
+		devices := []Devices{}
+		db.FindT(db.DBClient, new(Devices), &devices, db.M{"active>?": 0, timeout: []any{now, config.Keepalive.Interval, config.Keepalive.Count}}, "", skip, 100, false)
+		for _, device := range devices {
+			if active, ok := _activeDevices.Get(device.DeviceID); ok && active.ActiveAt >= now-device.keepaliveTimeout() {
+				// 内存中心跳时间较新
+				continue
+			}
+			logrus.Infoln("device keepalive timeout,id:", device.DeviceID, "last active:", device.ActiveAt)
+			deviceOffline(device.DeviceID)
+			go notify(notifyDevicesAcitve(device.DeviceID, m.DeviceStatusOFF))
+		}
+		if len(devices) != 100 {
+			break
+		}
+		skip += 100
+	}
+}
+
+// 设备离线，从活跃设备中移除，设备及通道状态置为离线
+func deviceOffline(deviceID string) {
+	_activeDevices.Delete(deviceID)
+	// 重新上线后不受同步间隔限制
+	_catalogSyncAt.Delete(deviceID)
+	if _, err := db.UpdateAll(db.DBClient, new(Devices), db.M{"deviceid=?": deviceID}, db.M{"active": -1}); err != nil {
+		logrus.Errorln("device offline update fail,id:", deviceID, err)
+	}
+	// 已删除的通道保持删除状态
+	if _, err := db.UpdateAll(db.DBClient, new(Channels), db.M{"deviceid=?": deviceID, "status<>?": m.DeviceStatusREMOVED}, db.M{"status": m.DeviceStatusOFF}); err != nil {
+		logrus.Errorln("device offline update channels fail,id:", deviceID, err)
+	}
+	// 订阅随设备离线失效，设备上线后重新订阅
+	if _, err := db.UpdateAll(db.DBClient, new(Subscriptions), db.M{"deviceid=?": deviceID}, db.M{"expireat": 0}); err != nil {
+		logrus.Errorln("device offline update subscriptions fail,id:", deviceID, err)
+	}
+}
@@ -15,10 +15,16 @@ const (
 	NotifyMethodDevicesActive = "devices.active"
 	// NotifyMethodUserRegister 设备注册通知
 	NotifyMethodDevicesRegister = "devices.regiester"
+	// NotifyMethodDevicesUnregister 设备注销或注册过期通知
+	NotifyMethodDevicesUnregister = "devices.unregister"
 	// NotifyMethodDeviceActive 通道活跃通知
 	NotifyMethodChannelsActive = "channels.active"
 	// NotifyMethodRecordStop 视频录制结束
 	NotifyMethodRecordStop = "records.stop"
+	// NotifyMethodAlarmsNew 设备报警通知
+	NotifyMethodAlarmsNew = "alarms.new"
+	// NotifyMethodDownloadsDone 历史视频下载完成
+	NotifyMethodDownloadsDone = "downloads.done"
 )
 
 // Notify 消息通知结构
@@ -61,6 +67,17 @@ func notifyDevicesRegister(u Devices) *Notify {
 	}
 }
 
+func notifyDevicesUnregister(id, reason string) *Notify {
+	return &Notify{
+		Method: NotifyMethodDevicesUnregister,
+		Data: map[string]interface{}{
+			"deviceid": id,
+			"reason":   reason,
+			"time":     time.Now().Unix(),
+		},
+	}
+}
+
 func notifyChannelsActive(d Channels) *Notify {
 	return &Notify{
 		Method: NotifyMethodChannelsActive,
@@ -83,3 +100,17 @@ func notifyRecordStop(url string, req url.Values) *Notify {
 		Data:   d,
 	}
 }
+
+func notifyAlarmsNew(alarm Alarms) *Notify {
+	return &Notify{
+		Method: NotifyMethodAlarmsNew,
+		Data:   alarm,
+	}
+}
+
+func notifyDownloadsDone(stream Streams) *Notify {
+	return &Notify{
+		Method: NotifyMethodDownloadsDone,
+		Data:   stream,
+	}
+}
@@ -0,0 +1,360 @@
+package sipapi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPlatformExpires   = 3600
+	defaultPlatformKeepalive = 60
+	// 注册到期前多久刷新，秒
+	platformRefreshBefore = 60
+	// 连续心跳失败次数，超过后重新注册
+	platformKeepaliveCount = 3
+)
+
+// Platforms 上级平台，本平台作为下级平台向上级注册
+type Platforms struct {
+	db.DBModel
+	// PlatformID 上级平台编号
+	PlatformID string `json:"platformid" gorm:"column:platformid"`
+	// Name 上级平台名称
+	Name string `json:"name" gorm:"column:name"`
+	// Region 上级平台域
+	Region string `json:"region" gorm:"column:region"`
+	// Addr 上级平台信令地址 ip:port
+	Addr string `json:"addr" gorm:"column:addr"`
+	// TransPort 信令传输协议 UDP TCP
+	TransPort string `json:"transport" gorm:"column:transport"`
+	// Username 认证用户名，为空时使用本平台编号
+	Username string `json:"username" gorm:"column:username"`
+	// PWD 认证密码
+	PWD string `json:"pwd" gorm:"column:pwd"`
+	// Expires 注册有效期，秒
+	Expires int `json:"expires" gorm:"column:expires"`
+	// Keepalive 心跳间隔，秒
+	Keepalive int `json:"keepalive" gorm:"column:keepalive"`
+	// Enable 是否启用
+	Enable bool `json:"enable" gorm:"column:enable"`
+	// Online 是否注册成功
+	Online bool `json:"online" gorm:"column:online"`
+	// Expire 注册过期时间
+	Expire int64 `json:"expire" gorm:"column:expire"`
+	// ActiveAt 最后心跳成功时间
+	ActiveAt int64 `json:"active" gorm:"column:active"`
+	// Msg 注册或心跳失败原因
+	Msg string `json:"msg" gorm:"column:msg"`
+}
+
+// PlatformChannels 共享给上级平台的通道
+type PlatformChannels struct {
+	db.DBModel
+	PlatformID string `json:"platformid" gorm:"column:platformid"`
+	ChannelID  string `json:"channelid" gorm:"column:channelid"`
+}
+
+// 运行中的上级平台
+type platform struct {
+	// 注册、心跳、注销串行执行
+	op sync.Mutex
+	// 保护平台配置、注册状态和reg，不在发送请求期间持有
+	mu sync.RWMutex
+	Platforms
+	reg *sip.Registration
+	// 连续心跳失败次数
+	failures int
+	// 已注销，不再注册和发送心跳
+	stopped bool
+}
+
+// 已启用的上级平台 key:platformid
+var _platforms = &sync.Map{}
+
+// 已注册成功的上级平台
+func getPlatform(platformID string) (*platform, bool) {
+	v, ok := _platforms.Load(platformID)
+	if !ok {
+		return nil, false
+	}
+	p := v.(*platform)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.Online || p.reg == nil {
+		return nil, false
+	}
+	return p, true
+}
+
+// CheckPlatforms 定时注册启用的上级平台，到期前刷新注册，按心跳间隔发送心跳，停用的平台注销
+func CheckPlatforms() {
+	platforms := []Platforms{}
+	if _, err := db.FindT(db.DBClient, new(Platforms), &platforms, db.M{"enable=?": true}, "", 0, -1, false); err != nil {
+		logrus.Errorln("checkPlatforms find platforms error,", err)
+		return
+	}
+	enabled := map[string]bool{}
+	for _, item := range platforms {
+		enabled[item.PlatformID] = true
+		v, loaded := _platforms.LoadOrStore(item.PlatformID, &platform{Platforms: item})
+		if loaded {
+			v.(*platform).update(item)
+		}
+		go v.(*platform).check()
+	}
+	_platforms.Range(func(key, value interface{}) bool {
+		if !enabled[key.(string)] {
+			_platforms.Delete(key)
+			go value.(*platform).unregister()
+		}
+		return true
+	})
+}
+
+// PlatformUnregister 向上级平台注销，平台配置修改或删除后调用，启用的平台在下次检查时重新注册
+func PlatformUnregister(platformID string) {
+	if v, ok := _platforms.LoadAndDelete(platformID); ok {
+		v.(*platform).unregister()
+	}
+}
+
+// update 使用数据库中的最新配置，信令相关的配置变化时重新注册
+func (p *platform) update(item Platforms) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Region != item.Region || p.Addr != item.Addr || p.TransPort != item.TransPort || p.Username != item.Username || p.PWD != item.PWD {
+		logrus.Infoln("platform config changed, register again,", p.PlatformID)
+		p.reg = nil
+		p.Online = false
+	}
+	p.Name, p.Region, p.Addr, p.TransPort = item.Name, item.Region, item.Addr, item.TransPort
+	p.Username, p.PWD, p.Expires, p.Keepalive = item.Username, item.PWD, item.Expires, item.Keepalive
+}
+
+func (p *platform) check() {
+	if !p.op.TryLock() {
+		return
+	}
+	defer p.op.Unlock()
+	p.mu.RLock()
+	stopped, online, expire, active, keepalive := p.stopped, p.Online, p.Expire, p.ActiveAt, p.keepalive()
+	p.mu.RUnlock()
+	if stopped {
+		return
+	}
+	now := time.Now().Unix()
+	if !online || expire-now < platformRefreshBefore {
+		if err := p.register(); err != nil {
+			logrus.Warnln("platform register fail,", p.PlatformID, err)
+		}
+		return
+	}
+	if now-active >= int64(keepalive) {
+		p.sendKeepalive()
+	}
+}
+
+// expires 需持有mu
+func (p *platform) expires() int {
+	if p.Expires <= platformRefreshBefore {
+		return defaultPlatformExpires
+	}
+	return p.Expires
+}
+
+// keepalive 需持有mu
+func (p *platform) keepalive() int {
+	if p.Keepalive <= 0 {
+		return defaultPlatformKeepalive
+	}
+	return p.Keepalive
+}
+
+// registration 当前的注册，create 为true时不存在则按配置创建
+func (p *platform) registration(create bool) (*sip.Registration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reg != nil || !create {
+		return p.reg, nil
+	}
+	registrar, err := sip.ParseSipURI(fmt.Sprintf("sip:%s@%s", p.PlatformID, p.Region))
+	if err != nil {
+		return nil, err
+	}
+	username := p.Username
+	if username == "" {
+		username = _serverDevices.DeviceID
+	}
+	aor := &sip.Address{URI: _serverDevices.addr.URI.Clone(), Params: sip.NewParams()}
+	if p.reg, err = sip.NewRegistration(srv, aor, &registrar, p.TransPort, p.Addr, username, p.PWD); err != nil {
+		return nil, err
+	}
+	return p.reg, nil
+}
+
+// destination 上级平台的信令地址，未注册时为nil
+func (p *platform) destination() net.Addr {
+	if reg, _ := p.registration(false); reg != nil {
+		return reg.Destination()
+	}
+	return nil
+}
+
+// fromAddr 来源地址是否为上级平台的信令地址，udp 比较ip和端口，tcp 连接可能由上级平台发起，只比较ip
+func (p *platform) fromAddr(src net.Addr) bool {
+	switch dest := p.destination().(type) {
+	case *net.UDPAddr:
+		addr, ok := src.(*net.UDPAddr)
+		return ok && addr.IP.Equal(dest.IP) && addr.Port == dest.Port
+	case *net.TCPAddr:
+		addr, ok := src.(*net.TCPAddr)
+		return ok && addr.IP.Equal(dest.IP)
+	}
+	return false
+}
+
+func (p *platform) register() error {
+	reg, err := p.registration(true)
+	if err != nil {
+		p.offline(fmt.Sprint(err))
+		return err
+	}
+	p.mu.RLock()
+	expires := p.expires()
+	p.mu.RUnlock()
+	res, err := reg.Register(expires)
+	if err == nil && res.StatusCode() != http.StatusOK {
+		err = fmt.Errorf("%d %s", res.StatusCode(), res.Reason())
+	}
+	if err != nil {
+		p.offline(fmt.Sprint(err))
+		return err
+	}
+	// 上级平台可以修改有效期
+	if hdrs := res.GetHeaders("Expires"); len(hdrs) > 0 {
+		if v, ok := hdrs[0].(*sip.Expires); ok && int(*v) > 0 {
+			expires = int(*v)
+		}
+	}
+	now := time.Now().Unix()
+	p.mu.Lock()
+	if p.reg != reg {
+		// 注册期间配置已修改，下次检查时按新配置注册
+		p.mu.Unlock()
+		return nil
+	}
+	if !p.Online {
+		logrus.Infoln("platform registered,", p.PlatformID, p.Addr)
+	}
+	p.Online = true
+	p.Expire = now + int64(expires)
+	p.ActiveAt = now
+	p.failures = 0
+	p.Msg = ""
+	status := p.status()
+	p.mu.Unlock()
+	p.save(status)
+	return nil
+}
+
+func (p *platform) sendKeepalive() {
+	reg, _ := p.registration(false)
+	if reg == nil {
+		return
+	}
+	res, err := reg.Request(sip.MESSAGE, &sip.ContentTypeXML, sip.GetKeepaliveXML(_serverDevices.DeviceID, utils.RandInt(100000, 999999)))
+	if err == nil && res.StatusCode() != http.StatusOK {
+		err = fmt.Errorf("%d %s", res.StatusCode(), res.Reason())
+	}
+	p.mu.Lock()
+	if err != nil {
+		p.failures++
+		failures := p.failures
+		p.mu.Unlock()
+		logrus.Warnln("platform keepalive fail,", p.PlatformID, failures, err)
+		if failures >= platformKeepaliveCount {
+			// 下次检查时重新注册
+			p.offline(fmt.Sprint("keepalive fail,", err))
+		}
+		return
+	}
+	p.failures = 0
+	p.ActiveAt = time.Now().Unix()
+	status := p.status()
+	p.mu.Unlock()
+	p.save(status)
+}
+
+func (p *platform) offline(msg string) {
+	p.mu.Lock()
+	p.Online = false
+	p.Expire = 0
+	p.Msg = msg
+	status := p.status()
+	p.mu.Unlock()
+	p.save(status)
+}
+
+// 注销并结束上级平台的点播，等待进行中的注册或心跳完成
+func (p *platform) unregister() {
+	p.op.Lock()
+	defer p.op.Unlock()
+	stopPlatformInvites(p.PlatformID)
+	p.mu.Lock()
+	p.stopped = true
+	reg := p.reg
+	if !p.Online {
+		reg = nil
+	}
+	p.mu.Unlock()
+	if reg != nil {
+		if _, err := reg.Register(0); err != nil {
+			logrus.Warnln("platform unregister fail,", p.PlatformID, err)
+		}
+	}
+	logrus.Infoln("platform unregistered,", p.PlatformID)
+	p.offline("")
+}
+
+// status 需持有mu
+func (p *platform) status() db.M {
+	return db.M{"online": p.Online, "expire": p.Expire, "active": p.ActiveAt, "msg": p.Msg}
+}
+
+func (p *platform) save(status db.M) {
+	if _, err := db.UpdateAll(db.DBClient, new(Platforms), db.M{"platformid=?": p.PlatformID}, status); err != nil {
+		logrus.Errorln("platform save status fail,", p.PlatformID, err)
+	}
+}
+
+// 通道是否共享给上级平台
+func (p *platform) hasChannel(channelID string) bool {
+	err := db.GetQ(db.DBClient, &PlatformChannels{}, db.M{"platformid=?": p.PlatformID, "channelid=?": channelID})
+	return err == nil
+}
+
+// 共享给上级平台的通道
+func (p *platform) channels() ([]Channels, error) {
+	items := []PlatformChannels{}
+	if _, err := db.FindT(db.DBClient, new(PlatformChannels), &items, db.M{"platformid=?": p.PlatformID}, "id", 0, -1, false); err != nil {
+		return nil, err
+	}
+	channels := []Channels{}
+	if len(items) == 0 {
+		return channels, nil
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ChannelID)
+	}
+	_, err := db.FindT(db.DBClient, new(Channels), &channels, db.M{"channelid in (?)": ids}, "id", 0, -1, false)
+	return channels, err
+}
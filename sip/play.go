@@ -3,6 +3,7 @@ package sipapi
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -44,7 +45,12 @@ func SipPlay(data *Streams) (*Streams, error) {
 		// GB28181推流
 		if data.StreamID == "" {
 			ssrcLock.Lock()
-			data.ssrc = getSSRC(data.T)
+			// ssrc 首位0为实时，1为历史（回放、下载）
+			t := data.T
+			if t > 1 {
+				t = 1
+			}
+			data.ssrc = getSSRC(t)
 			data.StreamID = ssrc2stream(data.ssrc)
 
 			// 成功后保存
@@ -65,6 +71,15 @@ func SipPlay(data *Streams) (*Streams, error) {
 	data.WSFLV = fmt.Sprintf("%s/rtp/%s.live.flv", config.Media.WS, data.StreamID)
 
 	data.Ext = time.Now().Unix() + 2*60 // 2分钟等待时间
+	if data.T >= 1 {
+		data.Start = data.S.Unix()
+		data.End = data.E.Unix()
+		data.Scale = 1
+		if data.T == 2 {
+			data.Scale = float64(data.Speed)
+		}
+		data.PositionAt = time.Now().Unix()
+	}
 	StreamList.Response.Store(data.StreamID, data)
 	if data.T == 0 {
 		StreamList.Succ.Store(data.ChannelID, data)
@@ -82,9 +97,13 @@ func sipPlayPush(data *Streams, channel Channels, device Devices) (*Streams, err
 	)
 	name := "Play"
 	protocal := "TCP/RTP/AVP"
-	if data.T == 1 {
+	switch data.T {
+	case 1:
 		name = "Playback"
 		protocal = "RTP/RTCP"
+	case 2:
+		name = "Download"
+		protocal = "RTP/RTCP"
 	}
 
 	video := sdp.Media{
@@ -103,6 +122,9 @@ func sipPlayPush(data *Streams, channel Channels, device Devices) (*Streams, err
 	video.AddAttribute("rtpmap", "96", "PS/90000")
 	video.AddAttribute("rtpmap", "98", "H264/90000")
 	video.AddAttribute("rtpmap", "97", "MPEG4/90000")
+	if data.T == 2 {
+		video.AddAttribute("downloadspeed", strconv.Itoa(data.Speed))
+	}
 
 	// defining message
 	msg := &sdp.Message{
@@ -124,7 +146,7 @@ func sipPlayPush(data *Streams, channel Channels, device Devices) (*Streams, err
 		Medias: []sdp.Media{video},
 		SSRC:   data.ssrc,
 	}
-	if data.T == 1 {
+	if data.T >= 1 {
 		msg.URI = fmt.Sprintf("%s:0", channel.ChannelID)
 	}
 
@@ -133,10 +155,12 @@ func sipPlayPush(data *Streams, channel Channels, device Devices) (*Streams, err
 	// appending session to byte buffer
 	b = s.AppendTo(b)
 	uri, _ := sip.ParseURI(channel.URIStr)
+	transURIScheme(uri, device.TransPort)
 	channel.addr = &sip.Address{URI: uri}
 	_serverDevices.addr.Params.Add("tag", sip.String{Str: utils.RandString(20)})
 	hb := sip.NewHeaderBuilder().SetTo(channel.addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
-		Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+		Transport: device.TransPort,
+		Params:    sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
 	}).SetContentType(&sip.ContentTypeSDP).SetMethod(sip.INVITE).SetContact(_serverDevices.addr)
 	req := sip.NewRequest("", sip.INVITE, channel.addr.URI, sip.DefaultSipVersion, hb.Build(), b)
 	req.SetDestination(device.source)
@@ -153,26 +177,22 @@ func sipPlayPush(data *Streams, channel Channels, device Devices) (*Streams, err
 		logrus.Warningln("sipPlayPush response fail.id:", device.DeviceID, channel.ChannelID, "err:", err)
 		return data, err
 	}
-	data.Resp = response
+	dialog, err := sip.NewDialog(req, response)
+	if err != nil {
+		logrus.Warningln("sipPlayPush dialog fail.id:", device.DeviceID, channel.ChannelID, "err:", err)
+		return data, err
+	}
 	// ACK
-	tx.Request(sip.NewRequestFromResponse(sip.ACK, response))
-
-	callid, _ := response.CallID()
-	data.CallID = string(*callid)
-
-	cseq, _ := response.CSeq()
-	if cseq != nil {
-		data.CseqNo = cseq.SeqNo
+	ack, err := dialog.NewRequest(sip.ACK, nil, nil)
+	if err != nil {
+		logrus.Warningln("sipPlayPush ack fail.id:", device.DeviceID, channel.ChannelID, "err:", err)
+		return data, err
 	}
+	ack.SetDestination(device.source)
+	tx.Request(ack)
 
-	from, _ := response.From()
-	to, _ := response.To()
-	for k, v := range to.Params.Items() {
-		data.Ttag[k] = v.String()
-	}
-	for k, v := range from.Params.Items() {
-		data.Ftag[k] = v.String()
-	}
+	data.Dialog = dialog
+	data.CallID = dialog.CallID
 	data.Status = 0
 
 	return data, err
@@ -188,17 +208,24 @@ func SipStopPlay(ssrc string) {
 	play := data.(*Streams)
 	if play.StreamType == m.StreamTypePush {
 		// 推流，需要发送关闭请求
-		resp := play.Resp
+		if play.Dialog == nil {
+			return
+		}
 		u, ok := _activeDevices.Load(play.DeviceID)
 		if !ok {
 			return
 		}
 		user := u.(Devices)
-		req := sip.NewRequestFromResponse(sip.BYE, resp)
-		req.SetDestination(user.source)
+		play.Dialog.SetDestination(user.source)
+		req, err := play.Dialog.NewRequest(sip.BYE, nil, nil)
+		if err != nil {
+			logrus.Warningln("sipStopPlay bye fail.id:", play.DeviceID, play.ChannelID, "err:", err)
+			return
+		}
 		tx, err := srv.Request(req)
 		if err != nil {
 			logrus.Warningln("sipStopPlay bye fail.id:", play.DeviceID, play.ChannelID, "err:", err)
+			return
 		}
 		_, err = sipResponse(tx)
 		if err != nil {
@@ -0,0 +1,97 @@
+package sipapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/sirupsen/logrus"
+)
+
+// 回放控制 MANSRTSP CSeq
+var _rtspSeq uint32
+
+// PlaybackPosition 当前回放位置，相对回放开始时间的秒数
+func (s *Streams) PlaybackPosition() float64 {
+	if s.Paused || s.PositionAt == 0 {
+		return s.Position
+	}
+	scale := s.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return s.Position + float64(time.Now().Unix()-s.PositionAt)*scale
+}
+
+// SipPlaybackControl 回放控制，通过对话内INFO发送MANSRTSP
+// cmd PLAY 时 position>=0 跳转到指定位置，scale>0 修改播放倍速，都不传时为恢复播放
+func SipPlaybackControl(streamID, cmd string, position, scale float64) (*Streams, error) {
+	v, ok := StreamList.Response.Load(streamID)
+	if !ok {
+		return nil, errors.New("视频流不存在或已关闭")
+	}
+	stream := v.(*Streams)
+	if stream.T != 1 {
+		return nil, errors.New("直播流不支持回放控制")
+	}
+	if stream.StreamType != m.StreamTypePush || stream.Dialog == nil {
+		return nil, errors.New("视频流不支持回放控制")
+	}
+	device, ok := _activeDevices.Get(stream.DeviceID)
+	if !ok {
+		return nil, errors.New("设备已离线")
+	}
+	var rng, scl string
+	switch cmd {
+	case sip.RTSPPlay:
+		if position >= 0 {
+			rng = strconv.FormatFloat(position, 'f', -1, 64)
+		}
+		if scale > 0 {
+			scl = strconv.FormatFloat(scale, 'f', -1, 64)
+		}
+	case sip.RTSPPause, sip.RTSPTeardown:
+	default:
+		return nil, fmt.Errorf("不支持的回放控制命令:%s", cmd)
+	}
+	stream.Dialog.SetDestination(device.source)
+	req, err := stream.Dialog.NewRequest(sip.INFO, &sip.ContentTypeRTSP, sip.GetMANSRTSP(cmd, atomic.AddUint32(&_rtspSeq, 1), rng, scl))
+	if err != nil {
+		return nil, err
+	}
+	tx, err := srv.Request(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sipResponse(tx); err != nil {
+		logrus.Warnln("sipPlaybackControl response fail,", stream.StreamID, cmd, err)
+		return nil, err
+	}
+	if cmd == sip.RTSPTeardown {
+		// 设备停止发送后关闭流
+		SipStopPlay(streamID)
+		return stream, nil
+	}
+	// 以控制时的位置为新的起点
+	stream.Position = stream.PlaybackPosition()
+	stream.PositionAt = time.Now().Unix()
+	switch cmd {
+	case sip.RTSPPlay:
+		stream.Paused = false
+		if rng != "" {
+			stream.Position = position
+		}
+		if scl != "" {
+			stream.Scale = scale
+		}
+	case sip.RTSPPause:
+		stream.Paused = true
+	}
+	db.Save(db.DBClient, stream)
+	return stream, nil
+}
@@ -0,0 +1,88 @@
+package sipapi
+
+import (
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// 移动设备位置默认上报间隔，秒
+const defaultPositionInterval = 5
+
+// Positions 移动设备位置轨迹
+type Positions struct {
+	db.DBModel
+	// DeviceID 上报位置的注册设备编号
+	DeviceID string `json:"deviceid" gorm:"column:deviceid"`
+	// ChannelID 移动设备编码
+	ChannelID string `json:"channelid" gorm:"column:channelid;index:idx_positions_channelid_time"`
+	// Time 位置时间
+	Time int64 `json:"time" gorm:"column:time;index:idx_positions_channelid_time"`
+	// Longitude 经度
+	Longitude float64 `json:"longitude" gorm:"column:longitude"`
+	// Latitude 纬度
+	Latitude float64 `json:"latitude" gorm:"column:latitude"`
+	// Speed 速度，千米/小时
+	Speed float64 `json:"speed" gorm:"column:speed"`
+	// Direction 方向，正北方向顺时针夹角，度
+	Direction float64 `json:"direction" gorm:"column:direction"`
+	// Altitude 海拔高度，米
+	Altitude float64 `json:"altitude" gorm:"column:altitude"`
+}
+
+// MessageMobilePosition 移动设备位置通知
+type MessageMobilePosition struct {
+	CmdType   string  `xml:"CmdType"`
+	SN        int     `xml:"SN"`
+	DeviceID  string  `xml:"DeviceID"`
+	Time      string  `xml:"Time"`
+	Longitude float64 `xml:"Longitude"`
+	Latitude  float64 `xml:"Latitude"`
+	Speed     float64 `xml:"Speed"`
+	Direction float64 `xml:"Direction"`
+	Altitude  float64 `xml:"Altitude"`
+}
+
+// 解析移动设备位置，保存轨迹并更新通道最新位置
+func sipMessageMobilePosition(u Devices, body []byte) error {
+	message := &MessageMobilePosition{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	position := &Positions{
+		DeviceID:  u.DeviceID,
+		ChannelID: message.DeviceID,
+		Time:      time.Now().Unix(),
+		Longitude: message.Longitude,
+		Latitude:  message.Latitude,
+		Speed:     message.Speed,
+		Direction: message.Direction,
+		Altitude:  message.Altitude,
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", message.Time, time.Local); err == nil {
+		position.Time = t.Unix()
+	}
+	if err := db.Create(db.DBClient, position); err != nil {
+		logrus.Errorln("save position fail,", position.DeviceID, position.ChannelID, err)
+		return err
+	}
+	// 上报乱序时只保留时间最新的位置
+	if _, err := db.UpdateAll(db.DBClient, new(Channels), db.M{"channelid=?": position.ChannelID, "(positionat<=? OR positionat IS NULL)": position.Time}, db.M{
+		"longitude":  position.Longitude,
+		"latitude":   position.Latitude,
+		"positionat": position.Time,
+	}); err != nil {
+		logrus.Warnln("update channel position fail,", position.ChannelID, err)
+	}
+	return nil
+}
+
+// PositionList 通道时间段内的位置轨迹，按时间正序
+func PositionList(channelID string, start, end int64) ([]Positions, error) {
+	positions := []Positions{}
+	_, err := db.FindT(db.DBClient, new(Positions), &positions, db.M{"channelid=?": channelID, "time>=?": start, "time<=?": end}, "time", 0, -1, false)
+	return positions, err
+}
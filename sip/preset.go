@@ -0,0 +1,263 @@
+package sipapi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// 预置位、巡航指令码 GB28181 附录A.3.4 A.3.5
+const (
+	ptzPresetSet    byte = 0x81
+	ptzPresetCall   byte = 0x82
+	ptzPresetDel    byte = 0x83
+	ptzCruiseAdd    byte = 0x84
+	ptzCruiseDel    byte = 0x85
+	ptzCruiseSpeed  byte = 0x86
+	ptzCruiseDwell  byte = 0x87
+	ptzCruiseStart  byte = 0x88
+	presetIDMax          = 255
+	cruiseParamsMax      = 4095
+)
+
+// Presets 通道预置位
+type Presets struct {
+	db.DBModel
+	// ChannelID 通道编码
+	ChannelID string `json:"channelid" gorm:"column:channelid"`
+	// DeviceID 设备编号
+	DeviceID string `json:"deviceid" gorm:"column:deviceid"`
+	// PresetID 预置位编号 1-255
+	PresetID int `json:"presetid" gorm:"column:presetid"`
+	// Name 预置位名称
+	Name string `json:"name" gorm:"column:name"`
+}
+
+func checkPresetID(id int) error {
+	if id < 1 || id > presetIDMax {
+		return errors.New("预置位编号范围1-255")
+	}
+	return nil
+}
+
+// SipPresetSet 设置预置位，保存预置位名称
+func SipPresetSet(channel *Channels, id int, name string) (*Presets, error) {
+	if err := checkPresetID(id); err != nil {
+		return nil, err
+	}
+	if err := sipPTZCmd(channel, PTZCmd(ptzPresetSet, 0, byte(id), 0)); err != nil {
+		return nil, err
+	}
+	preset := &Presets{ChannelID: channel.ChannelID, PresetID: id}
+	if err := db.Get(db.DBClient, preset); err != nil && !db.RecordNotFound(err) {
+		return nil, err
+	}
+	preset.DeviceID = channel.DeviceID
+	preset.Name = name
+	if err := db.Save(db.DBClient, preset); err != nil {
+		return nil, err
+	}
+	return preset, nil
+}
+
+// SipPresetCall 调用预置位
+func SipPresetCall(channel *Channels, id int) error {
+	if err := checkPresetID(id); err != nil {
+		return err
+	}
+	return sipPTZCmd(channel, PTZCmd(ptzPresetCall, 0, byte(id), 0))
+}
+
+// SipPresetDelete 删除预置位
+func SipPresetDelete(channel *Channels, id int) error {
+	if err := checkPresetID(id); err != nil {
+		return err
+	}
+	if err := sipPTZCmd(channel, PTZCmd(ptzPresetDel, 0, byte(id), 0)); err != nil {
+		return err
+	}
+	return db.DelQ(db.DBClient, new(Presets), db.M{"channelid=?": channel.ChannelID, "presetid=?": id})
+}
+
+// CruiseConfig 巡航轨迹配置
+type CruiseConfig struct {
+	// CruiseID 巡航组号
+	CruiseID int
+	// Presets 巡航点（预置位编号），按顺序加入巡航组
+	Presets []int
+	// Speed 巡航速度，0-4095，0表示不设置
+	Speed int
+	// Dwell 预置位停留时间，秒，0-4095，0表示不设置
+	Dwell int
+}
+
+// SipCruiseSet 设置巡航轨迹，先清空巡航组再依次加入巡航点
+func SipCruiseSet(channel *Channels, cruise CruiseConfig) error {
+	if cruise.CruiseID < 0 || cruise.CruiseID > 255 {
+		return errors.New("巡航组号范围0-255")
+	}
+	if len(cruise.Presets) == 0 {
+		return errors.New("缺少巡航点")
+	}
+	if cruise.Speed < 0 || cruise.Speed > cruiseParamsMax || cruise.Dwell < 0 || cruise.Dwell > cruiseParamsMax {
+		return errors.New("巡航速度和停留时间范围0-4095")
+	}
+	for _, id := range cruise.Presets {
+		if err := checkPresetID(id); err != nil {
+			return err
+		}
+	}
+	group := byte(cruise.CruiseID)
+	cmds := []string{PTZCmd(ptzCruiseDel, group, 0, 0)}
+	for _, id := range cruise.Presets {
+		cmds = append(cmds, PTZCmd(ptzCruiseAdd, group, byte(id), 0))
+	}
+	// 字节6 数据低8位，字节7高4位 数据高4位
+	if cruise.Speed > 0 {
+		cmds = append(cmds, PTZCmd(ptzCruiseSpeed, group, byte(cruise.Speed&0xFF), byte(cruise.Speed>>8)))
+	}
+	if cruise.Dwell > 0 {
+		cmds = append(cmds, PTZCmd(ptzCruiseDwell, group, byte(cruise.Dwell&0xFF), byte(cruise.Dwell>>8)))
+	}
+	for _, cmd := range cmds {
+		if err := sipPTZCmd(channel, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SipCruiseStart 开始巡航
+func SipCruiseStart(channel *Channels, cruiseID int) error {
+	if cruiseID < 0 || cruiseID > 255 {
+		return errors.New("巡航组号范围0-255")
+	}
+	return sipPTZCmd(channel, PTZCmd(ptzCruiseStart, byte(cruiseID), 0, 0))
+}
+
+// SipCruiseStop 停止巡航，发送云台停止指令
+func SipCruiseStop(channel *Channels) error {
+	return sipPTZCmd(channel, PTZCmd(ptzCodes[PTZStop], 0, 0, 0))
+}
+
+// SipCruiseDelete 删除巡航组
+func SipCruiseDelete(channel *Channels, cruiseID int) error {
+	if cruiseID < 0 || cruiseID > 255 {
+		return errors.New("巡航组号范围0-255")
+	}
+	return sipPTZCmd(channel, PTZCmd(ptzCruiseDel, byte(cruiseID), 0, 0))
+}
+
+// MessagePresetQueryResponse 预置位查询应答
+type MessagePresetQueryResponse struct {
+	CmdType  string `xml:"CmdType"`
+	SN       int    `xml:"SN"`
+	DeviceID string `xml:"DeviceID"`
+	List     struct {
+		SumNum int          `xml:"Num,attr"`
+		Item   []PresetItem `xml:"Item"`
+	} `xml:"PresetList"`
+}
+
+// PresetItem 预置位详情
+type PresetItem struct {
+	PresetID   int    `xml:"PresetID"`
+	PresetName string `xml:"PresetName"`
+}
+
+type presetList struct {
+	resp  chan []PresetItem
+	items []PresetItem
+	l     *sync.Mutex
+}
+
+// 当前查询预置位的通道集合
+var _presetList = &sync.Map{}
+
+// SipPresetList 从设备查询预置位列表，同步到数据库后返回
+func SipPresetList(channel *Channels) ([]Presets, error) {
+	sn := utils.RandInt(100000, 999999)
+	key := fmt.Sprintf("%s%d", channel.ChannelID, sn)
+	list := &presetList{resp: make(chan []PresetItem, 1), l: &sync.Mutex{}}
+	_presetList.Store(key, list)
+	defer _presetList.Delete(key)
+	if err := sipChannelMessage(channel, sip.GetPresetQueryXML(channel.ChannelID, sn)); err != nil {
+		return nil, err
+	}
+	var items []PresetItem
+	select {
+	case items = <-list.resp:
+	case <-time.After(10 * time.Second):
+		// 超时返回当前获取到的数据
+		list.l.Lock()
+		items = list.items
+		list.l.Unlock()
+		if len(items) == 0 {
+			return nil, errors.New("获取数据超时")
+		}
+	}
+	return syncPresets(channel, items)
+}
+
+// 设备端预置位为准，设备未返回名称时保留本地名称
+func syncPresets(channel *Channels, items []PresetItem) ([]Presets, error) {
+	locals := []Presets{}
+	if _, err := db.FindT(db.DBClient, new(Presets), &locals, db.M{"channelid=?": channel.ChannelID}, "presetid", 0, -1, false); err != nil {
+		return nil, err
+	}
+	localMap := map[int]Presets{}
+	for _, preset := range locals {
+		localMap[preset.PresetID] = preset
+	}
+	result := []Presets{}
+	for _, item := range items {
+		preset, ok := localMap[item.PresetID]
+		if !ok {
+			preset = Presets{ChannelID: channel.ChannelID, DeviceID: channel.DeviceID, PresetID: item.PresetID}
+		}
+		delete(localMap, item.PresetID)
+		if item.PresetName != "" {
+			preset.Name = item.PresetName
+		}
+		if err := db.Save(db.DBClient, &preset); err != nil {
+			return nil, err
+		}
+		result = append(result, preset)
+	}
+	for _, preset := range localMap {
+		// 设备上已不存在的预置位
+		db.Del(db.DBClient, &Presets{DBModel: db.DBModel{ID: preset.ID}})
+	}
+	return result, nil
+}
+
+func sipMessagePresetQuery(u Devices, body []byte) error {
+	message := &MessagePresetQueryResponse{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	key := fmt.Sprintf("%s%d", message.DeviceID, message.SN)
+	v, ok := _presetList.Load(key)
+	if !ok {
+		return errors.New("presetlist channel not found")
+	}
+	list := v.(*presetList)
+	list.l.Lock()
+	defer list.l.Unlock()
+	list.items = append(list.items, message.List.Item...)
+	if len(list.items) >= message.List.SumNum {
+		// 获取到完整数据
+		select {
+		case list.resp <- list.items:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package sipapi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/sirupsen/logrus"
+)
+
+// 云台控制指令 GB28181 附录A.3
+const (
+	PTZStop      = "stop"
+	PTZUp        = "up"
+	PTZDown      = "down"
+	PTZLeft      = "left"
+	PTZRight     = "right"
+	PTZUpLeft    = "upleft"
+	PTZUpRight   = "upright"
+	PTZDownLeft  = "downleft"
+	PTZDownRight = "downright"
+	PTZZoomIn    = "zoomin"
+	PTZZoomOut   = "zoomout"
+	PTZFocusNear = "focusnear"
+	PTZFocusFar  = "focusfar"
+	PTZIrisOpen  = "irisopen"
+	PTZIrisClose = "irisclose"
+)
+
+// 指令码，PTZ 指令 bit0右 bit1左 bit2下 bit3上 bit4放大 bit5缩小，FI 指令高4位为0100 bit0聚焦远 bit1聚焦近 bit2光圈放大 bit3光圈缩小
+var ptzCodes = map[string]byte{
+	PTZStop:      0x00,
+	PTZRight:     0x01,
+	PTZLeft:      0x02,
+	PTZDown:      0x04,
+	PTZUp:        0x08,
+	PTZDownRight: 0x05,
+	PTZDownLeft:  0x06,
+	PTZUpRight:   0x09,
+	PTZUpLeft:    0x0A,
+	PTZZoomIn:    0x10,
+	PTZZoomOut:   0x20,
+	PTZFocusFar:  0x41,
+	PTZFocusNear: 0x42,
+	PTZIrisOpen:  0x44,
+	PTZIrisClose: 0x48,
+}
+
+// PTZCmd 8字节云台控制指令，返回16进制字符串
+// 字节1 A5，字节2 版本和校验位，字节3 地址低8位，字节4 指令码，字节5-7 数据，字节8 前7字节和取模256
+func PTZCmd(code, data1, data2, data3 byte) string {
+	cmd := [8]byte{0xA5, 0, 0x01, code, data1, data2, (data3 & 0x0F) << 4}
+	// 版本号0，校验位 = (字节1高4位 + 字节1低4位 + 字节2高4位) % 16
+	cmd[1] = (0xA + 0x5 + 0x0) % 16
+	var sum int
+	for _, b := range cmd[:7] {
+		sum += int(b)
+	}
+	cmd[7] = byte(sum % 256)
+	return fmt.Sprintf("%X", cmd[:])
+}
+
+// 生成方向、变倍、聚焦、光圈控制指令，speed 0-255，变倍速度取高4位
+func ptzMoveCmd(command string, speed int) (string, error) {
+	code, ok := ptzCodes[command]
+	if !ok {
+		return "", fmt.Errorf("不支持的云台指令:%s", command)
+	}
+	if speed < 0 || speed > 255 {
+		return "", errors.New("速度范围0-255")
+	}
+	s := byte(speed)
+	switch {
+	case code == 0x00:
+		return PTZCmd(code, 0, 0, 0), nil
+	case code&0xF0 == 0x40:
+		// 字节5 聚焦速度 字节6 光圈速度
+		return PTZCmd(code, s, s, 0), nil
+	}
+	// 字节5 水平速度 字节6 垂直速度 字节7高4位 变倍速度
+	var pan, tilt, zoom byte
+	if code&0x03 != 0 {
+		pan = s
+	}
+	if code&0x0C != 0 {
+		tilt = s
+	}
+	if code&0x30 != 0 {
+		zoom = s >> 4
+	}
+	return PTZCmd(code, pan, tilt, zoom), nil
+}
+
+// SipPTZ 云台控制，duration 大于0时运动指定毫秒数后自动发送停止指令
+func SipPTZ(channel *Channels, command string, speed, duration int) error {
+	cmd, err := ptzMoveCmd(command, speed)
+	if err != nil {
+		return err
+	}
+	if err := sipPTZCmd(channel, cmd); err != nil {
+		return err
+	}
+	if duration > 0 && command != PTZStop {
+		ch := *channel
+		time.AfterFunc(time.Duration(duration)*time.Millisecond, func() {
+			if err := sipPTZCmd(&ch, PTZCmd(ptzCodes[PTZStop], 0, 0, 0)); err != nil {
+				logrus.Warnln("sipPTZ stop fail,", ch.ChannelID, err)
+			}
+		})
+	}
+	return nil
+}
+
+func sipPTZCmd(channel *Channels, cmd string) error {
+	return sipChannelMessage(channel, sip.GetPTZCmdXML(channel.ChannelID, cmd))
+}
+
+// 向通道发送 MESSAGE 请求，等待设备返回200
+func sipChannelMessage(channel *Channels, body []byte) error {
+	device, ok := _activeDevices.Get(channel.DeviceID)
+	if !ok {
+		return errors.New("设备不在线")
+	}
+	channelURI, err := sip.ParseURI(channel.URIStr)
+	if err != nil {
+		return err
+	}
+	transURIScheme(channelURI, device.TransPort)
+	channel.addr = &sip.Address{URI: channelURI}
+	return sipMessage(device, channel.addr, body)
+}
+
+// 通过注册设备发送 MESSAGE 请求，等待设备返回200
+func sipMessage(device Devices, to *sip.Address, body []byte) error {
+	to = deviceAddress(to, device.TransPort)
+	hb := sip.NewHeaderBuilder().SetTo(to).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
+		Transport: device.TransPort,
+		Params:    sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+	}).SetContentType(&sip.ContentTypeXML).SetMethod(sip.MESSAGE)
+	req := sip.NewRequest("", sip.MESSAGE, to.URI, sip.DefaultSipVersion, hb.Build(), body)
+	req.SetDestination(device.source)
+	tx, err := srv.Request(req)
+	if err != nil {
+		return err
+	}
+	_, err = sipResponse(tx)
+	return err
+}
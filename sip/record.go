@@ -23,12 +23,14 @@ func SipRecordList(to *Channels, start, end int64) (*Records, error) {
 		return nil, errors.New("设备不在线")
 	}
 	channelURI, _ := sip.ParseURI(to.URIStr)
+	transURIScheme(channelURI, device.TransPort)
 	to.addr = &sip.Address{URI: channelURI}
 	recordKey := fmt.Sprintf("%s%d", to.ChannelID, sn)
 	_recordList.Store(recordKey, recordList{channelid: to.ChannelID, resp: resp, data: [][]int64{}, l: &sync.Mutex{}, s: start, e: end})
 	defer _recordList.Delete(recordKey)
 	hb := sip.NewHeaderBuilder().SetTo(to.addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
-		Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+		Transport: device.TransPort,
+		Params:    sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
 	}).SetContentType(&sip.ContentTypeXML).SetMethod(sip.MESSAGE)
 	req := sip.NewRequest("", sip.MESSAGE, to.addr.URI, sip.DefaultSipVersion, hb.Build(), sip.GetRecordInfoXML(to.ChannelID, sn, start, end))
 	req.SetDestination(device.source)
@@ -0,0 +1,43 @@
+package sipapi
+
+import (
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	"github.com/sirupsen/logrus"
+)
+
+// 设备未携带Expires时默认的注册有效期(秒)
+const defaultRegisterExpires = 3600
+
+// 设备注销或注册过期，从活跃设备中移除并发送通知
+func unregisterDevice(deviceID, reason string) {
+	deviceOffline(deviceID)
+	_, err := db.UpdateAll(db.DBClient, new(Devices), db.M{"deviceid=?": deviceID}, db.M{"expire": 0})
+	if err != nil {
+		logrus.Errorln("unregister device update fail,id:", deviceID, err)
+	}
+	logrus.Infoln("device unregister,id:", deviceID, "reason:", reason)
+	go notify(notifyDevicesUnregister(deviceID, reason))
+}
+
+// CheckRegisters 定时检查注册过期的设备
+func CheckRegisters() {
+	var skip int
+	now := time.Now().Unix()
+	for {
+		devices := []Devices{}
+		db.FindT(db.DBClient, new(Devices), &devices, db.M{"expire>?": 0, "expire<?": now}, "", skip, 100, false)
+		for _, device := range devices {
+			if active, ok := _activeDevices.Get(device.DeviceID); ok && active.Expire >= now {
+				// 已重新注册，数据库尚未更新
+				continue
+			}
+			unregisterDevice(device.DeviceID, "expired")
+		}
+		if len(devices) != 100 {
+			break
+		}
+		skip += 100
+	}
+}
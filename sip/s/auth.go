@@ -2,13 +2,40 @@ package sip
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/panjjo/gosip/utils"
+)
+
+// 摘要认证算法 RFC 7616
+const (
+	AlgorithmMD5        = "MD5"
+	AlgorithmMD5Sess    = "MD5-sess"
+	AlgorithmSHA256     = "SHA-256"
+	AlgorithmSHA256Sess = "SHA-256-sess"
 )
 
-// Authorization currently only Digest and MD5
+var algorithms = []string{AlgorithmMD5, AlgorithmMD5Sess, AlgorithmSHA256, AlgorithmSHA256Sess}
+
+// ParseAlgorithm 返回标准的算法名称，不支持的算法返回false
+func ParseAlgorithm(algorithm string) (string, bool) {
+	for _, v := range algorithms {
+		if strings.EqualFold(v, strings.TrimSpace(algorithm)) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Authorization Digest 认证，支持 MD5 MD5-sess SHA-256 SHA-256-sess
 type Authorization struct {
 	realm     string
 	nonce     string
@@ -69,6 +96,11 @@ func AuthFromValue(value string) *Authorization {
 	return auth
 }
 
+// Algorithm 摘要算法，未携带时为MD5
+func (auth *Authorization) Algorithm() string {
+	return auth.algorithm
+}
+
 // Get Get
 func (auth *Authorization) Get(key string) string {
 	return auth.Data[key]
@@ -104,7 +136,8 @@ func (auth *Authorization) SetPassword(password string) *Authorization {
 
 // CalcResponse CalcResponse
 func (auth *Authorization) CalcResponse() string {
-	auth.response = CalcResponse(
+	auth.response = CalcResponseWithAlgorithm(
+		auth.algorithm,
 		auth.username,
 		auth.realm,
 		auth.password,
@@ -136,30 +169,134 @@ func (auth *Authorization) String() string {
 	if auth.qop == "auth" {
 		str += fmt.Sprintf(`,qop=%s,nc=%s,cnonce="%s"`, auth.qop, auth.nc, auth.cnonce)
 	}
+	if opaque, ok := auth.other["opaque"]; ok {
+		str += fmt.Sprintf(`,opaque="%s"`, opaque)
+	}
 
 	return str
 }
 
+// NewAuthorization 根据服务端的认证质询生成请求的Authorization(UAC) RFC 2617 3.2.2
+// 质询携带qop时生成cnonce，nc为使用同一nonce的请求次数
+func NewAuthorization(challenge, username, password, method, uri string, nc uint32) *Authorization {
+	auth := AuthFromValue(challenge).SetUsername(username).SetPassword(password).SetMethod(method).SetURI(uri)
+	if auth.qop == "auth" {
+		auth.cnonce = utils.RandString(16)
+		auth.nc = fmt.Sprintf("%08x", nc)
+	}
+	auth.CalcResponse()
+	return auth
+}
+
 // CalcResponse Authorization response https://www.ietf.org/rfc/rfc2617.txt
 func CalcResponse(username, realm, password, method, uri, nonce, qop, cnonce, nc string) string {
-	calcA1 := func() string {
-		encoder := md5.New()
-		encoder.Write([]byte(username + ":" + realm + ":" + password))
+	return CalcResponseWithAlgorithm(AlgorithmMD5, username, realm, password, method, uri, nonce, qop, cnonce, nc)
+}
 
-		return hex.EncodeToString(encoder.Sum(nil))
+// CalcResponseWithAlgorithm 指定算法计算摘要 https://www.rfc-editor.org/rfc/rfc7616
+// -sess 算法 A1 = H(username:realm:password):nonce:cnonce，不支持的算法按MD5计算
+func CalcResponseWithAlgorithm(algorithm, username, realm, password, method, uri, nonce, qop, cnonce, nc string) string {
+	algorithm, _ = ParseAlgorithm(algorithm)
+	newHash := md5.New
+	if algorithm == AlgorithmSHA256 || algorithm == AlgorithmSHA256Sess {
+		newHash = sha256.New
 	}
-	calcA2 := func() string {
-		encoder := md5.New()
-		encoder.Write([]byte(method + ":" + uri))
-
+	h := func(data string) string {
+		encoder := newHash()
+		encoder.Write([]byte(data))
 		return hex.EncodeToString(encoder.Sum(nil))
 	}
+	a1 := h(username + ":" + realm + ":" + password)
+	if algorithm == AlgorithmMD5Sess || algorithm == AlgorithmSHA256Sess {
+		a1 = h(a1 + ":" + nonce + ":" + cnonce)
+	}
+	a2 := h(method + ":" + uri)
 
-	encoder := md5.New()
-	encoder.Write([]byte(calcA1() + ":" + nonce + ":"))
+	encoder := newHash()
+	encoder.Write([]byte(a1 + ":" + nonce + ":"))
 	if qop != "" {
 		encoder.Write([]byte(nc + ":" + cnonce + ":" + qop + ":"))
 	}
-	encoder.Write([]byte(calcA2()))
+	encoder.Write([]byte(a2))
 	return hex.EncodeToString(encoder.Sum(nil))
 }
+
+var (
+	// ErrNonceStale nonce 不存在或已过期，需要回复 stale=true 让客户端使用新的nonce重新计算
+	ErrNonceStale = errors.New("nonce stale")
+	// ErrNonceCount nonce-count 未递增，可能是重放的请求
+	ErrNonceCount = errors.New("nonce count not increase")
+)
+
+// NonceStore 摘要认证的nonce管理，记录已下发nonce的过期时间和最后使用的nonce-count
+type NonceStore struct {
+	expire    time.Duration
+	mu        *sync.Mutex
+	nonces    map[string]*nonceItem
+	lastSweep time.Time
+}
+
+type nonceItem struct {
+	expire time.Time
+	nc     uint64
+}
+
+// NewNonceStore NewNonceStore
+func NewNonceStore(expire time.Duration) *NonceStore {
+	return &NonceStore{
+		expire:    expire,
+		mu:        &sync.Mutex{},
+		nonces:    map[string]*nonceItem{},
+		lastSweep: time.Now(),
+	}
+}
+
+// New 生成新的nonce
+func (s *NonceStore) New() string {
+	nonce := utils.RandString(32)
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nonces[nonce] = &nonceItem{expire: now.Add(s.expire)}
+	if now.Sub(s.lastSweep) > s.expire {
+		for k, v := range s.nonces {
+			if now.After(v.expire) {
+				delete(s.nonces, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nonce
+}
+
+// Check 校验nonce，需在摘要响应验证通过后调用
+// qop=auth 时 nc 必须大于上次使用的值，未使用qop时nonce只能使用一次
+func (s *NonceStore) Check(nonce, qop, nc string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.nonces[nonce]
+	if !ok {
+		return ErrNonceStale
+	}
+	if time.Now().After(item.expire) {
+		delete(s.nonces, nonce)
+		return ErrNonceStale
+	}
+	if qop == "" {
+		delete(s.nonces, nonce)
+		return nil
+	}
+	count, err := strconv.ParseUint(nc, 16, 64)
+	if err != nil || count <= item.nc {
+		return ErrNonceCount
+	}
+	item.nc = count
+	return nil
+}
+
+// Delete 删除nonce
+func (s *NonceStore) Delete(nonce string) {
+	s.mu.Lock()
+	delete(s.nonces, nonce)
+	s.mu.Unlock()
+}
@@ -0,0 +1,100 @@
+package sip
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+// RFC 7616 3.9.1 示例
+const (
+	rfc7616Username = "Mufasa"
+	rfc7616Realm    = "http-auth@example.org"
+	rfc7616Password = "Circle of Life"
+	rfc7616URI      = "/dir/index.html"
+	rfc7616Nonce    = "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v"
+	rfc7616CNonce   = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+)
+
+// digest 按 RFC 7616 3.4 逐步计算，用来校验-sess算法
+func digest(newHash func() hash.Hash, sess bool, username, realm, password, method, uri, nonce, qop, cnonce, nc string) string {
+	h := func(data string) string {
+		encoder := newHash()
+		encoder.Write([]byte(data))
+		return hex.EncodeToString(encoder.Sum(nil))
+	}
+	a1 := h(username + ":" + realm + ":" + password)
+	if sess {
+		a1 = h(a1 + ":" + nonce + ":" + cnonce)
+	}
+	a2 := h(method + ":" + uri)
+	if qop == "" {
+		return h(a1 + ":" + nonce + ":" + a2)
+	}
+	return h(a1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + a2)
+}
+
+func TestCalcResponseWithAlgorithm(t *testing.T) {
+	cases := []struct {
+		algorithm string
+		qop       string
+		want      string
+	}{
+		// RFC 7616 3.9.1
+		{AlgorithmMD5, "auth", "8ca523f5e9506fed4657c9700eebdbec"},
+		{AlgorithmSHA256, "auth", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+		{AlgorithmMD5Sess, "auth", digest(md5.New, true, rfc7616Username, rfc7616Realm, rfc7616Password, "GET", rfc7616URI, rfc7616Nonce, "auth", rfc7616CNonce, "00000001")},
+		{AlgorithmSHA256Sess, "auth", digest(sha256.New, true, rfc7616Username, rfc7616Realm, rfc7616Password, "GET", rfc7616URI, rfc7616Nonce, "auth", rfc7616CNonce, "00000001")},
+		{AlgorithmMD5, "", digest(md5.New, false, rfc7616Username, rfc7616Realm, rfc7616Password, "GET", rfc7616URI, rfc7616Nonce, "", "", "")},
+		{AlgorithmSHA256, "", digest(sha256.New, false, rfc7616Username, rfc7616Realm, rfc7616Password, "GET", rfc7616URI, rfc7616Nonce, "", "", "")},
+		// 算法名称不区分大小写，不支持的算法按MD5计算
+		{"sha-256", "auth", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+		{"SHA-512-256", "auth", "8ca523f5e9506fed4657c9700eebdbec"},
+	}
+	for _, c := range cases {
+		got := CalcResponseWithAlgorithm(c.algorithm, rfc7616Username, rfc7616Realm, rfc7616Password, "GET", rfc7616URI, rfc7616Nonce, c.qop, rfc7616CNonce, "00000001")
+		if got != c.want {
+			t.Errorf("%s qop=%q: got %s, want %s", c.algorithm, c.qop, got, c.want)
+		}
+	}
+}
+
+func TestCalcResponse(t *testing.T) {
+	// CalcResponse 为MD5
+	got := CalcResponse(rfc7616Username, rfc7616Realm, rfc7616Password, "GET", rfc7616URI, rfc7616Nonce, "auth", rfc7616CNonce, "00000001")
+	if got != "8ca523f5e9506fed4657c9700eebdbec" {
+		t.Errorf("RFC 7616 MD5: got %s", got)
+	}
+	// RFC 2617 3.5
+	got = CalcResponse("Mufasa", "testrealm@host.com", "Circle Of Life", "GET", "/dir/index.html", "dcd98b7102dd2f0e8b11d0f600bfb0c093", "auth", "0a4f113b", "00000001")
+	if got != "6629fae49393a05397450978507c4ef1" {
+		t.Errorf("RFC 2617 MD5: got %s", got)
+	}
+	// 与未携带algorithm时的默认算法一致
+	for _, algorithm := range []string{AlgorithmMD5, "md5", ""} {
+		if v := CalcResponseWithAlgorithm(algorithm, "Mufasa", "testrealm@host.com", "Circle Of Life", "GET", "/dir/index.html", "dcd98b7102dd2f0e8b11d0f600bfb0c093", "auth", "0a4f113b", "00000001"); v != got {
+			t.Errorf("algorithm %q: got %s, want %s", algorithm, v, got)
+		}
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, c := range []struct {
+		algorithm string
+		want      string
+		ok        bool
+	}{
+		{"MD5", AlgorithmMD5, true},
+		{"md5-sess", AlgorithmMD5Sess, true},
+		{" SHA-256 ", AlgorithmSHA256, true},
+		{"sha-256-SESS", AlgorithmSHA256Sess, true},
+		{"SHA-512-256", "", false},
+		{"", "", false},
+	} {
+		if got, ok := ParseAlgorithm(c.algorithm); got != c.want || ok != c.ok {
+			t.Errorf("%q: got %q %v, want %q %v", c.algorithm, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package sip
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewAuthorization(t *testing.T) {
+	for _, algorithm := range algorithms {
+		challenge := `Digest realm="` + rfc7616Realm + `",qop="auth",algorithm=` + algorithm + `,nonce="` + rfc7616Nonce + `",opaque="abc"`
+		auth := NewAuthorization(challenge, rfc7616Username, rfc7616Password, "REGISTER", "sip:34020000002000000001@3402000000", 2)
+		// 服务端按请求头重新计算
+		server := AuthFromValue(auth.String())
+		if server.Algorithm() != algorithm || server.Get("nc") != "00000002" || server.Get("opaque") != "abc" {
+			t.Errorf("%s: unexpected header %s", algorithm, auth.String())
+		}
+		server.SetPassword(rfc7616Password).SetMethod("REGISTER")
+		want := server.Get("response")
+		if got := server.CalcResponse(); got != want {
+			t.Errorf("%s: server calc %s, client sent %s", algorithm, got, want)
+		}
+	}
+}
+
+func TestNonceStoreCheck(t *testing.T) {
+	store := NewNonceStore(time.Minute)
+	if err := store.Check("unknown", "auth", "00000001"); !errors.Is(err, ErrNonceStale) {
+		t.Errorf("unknown nonce: got %v", err)
+	}
+
+	// 未使用qop时nonce只能使用一次
+	nonce := store.New()
+	if err := store.Check(nonce, "", ""); err != nil {
+		t.Fatalf("first use without qop: %v", err)
+	}
+	if err := store.Check(nonce, "", ""); !errors.Is(err, ErrNonceStale) {
+		t.Errorf("reuse without qop: got %v", err)
+	}
+
+	// qop=auth 时nc必须递增
+	nonce = store.New()
+	for _, c := range []struct {
+		nc   string
+		want error
+	}{
+		{"00000001", nil},
+		{"00000001", ErrNonceCount},
+		{"00000003", nil},
+		{"00000002", ErrNonceCount},
+		{"0000000a", nil},
+		{"zz", ErrNonceCount},
+	} {
+		if err := store.Check(nonce, "auth", c.nc); !errors.Is(err, c.want) {
+			t.Errorf("nc %s: got %v, want %v", c.nc, err, c.want)
+		}
+	}
+	store.Delete(nonce)
+	if err := store.Check(nonce, "auth", "0000000b"); !errors.Is(err, ErrNonceStale) {
+		t.Errorf("deleted nonce: got %v", err)
+	}
+
+	expired := NewNonceStore(10 * time.Millisecond)
+	nonce = expired.New()
+	time.Sleep(20 * time.Millisecond)
+	if err := expired.Check(nonce, "auth", "00000001"); !errors.Is(err, ErrNonceStale) {
+		t.Errorf("expired nonce: got %v", err)
+	}
+	// 过期的nonce已删除
+	if err := expired.Check(nonce, "", ""); !errors.Is(err, ErrNonceStale) {
+		t.Errorf("expired nonce reused: got %v", err)
+	}
+}
@@ -3,8 +3,10 @@ package sip
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -77,6 +79,9 @@ type connection struct {
 	raddr    net.Addr
 	// mu       sync.RWMutex
 	logKey string
+	// 面向连接的传输(tcp/tls)
+	stream  bool
+	network string
 }
 
 func newUDPConnection(baseConn net.Conn) Connection {
@@ -89,6 +94,19 @@ func newUDPConnection(baseConn net.Conn) Connection {
 	return conn
 }
 
+// tcp/tls 连接，一个设备对应一条连接，WriteTo 时忽略 raddr 直接写入当前连接
+func newStreamConnection(transport string, baseConn net.Conn) Connection {
+	conn := &connection{
+		baseConn: baseConn,
+		laddr:    baseConn.LocalAddr(),
+		raddr:    baseConn.RemoteAddr(),
+		logKey:   strings.ToLower(transport) + "Connection",
+		network:  strings.ToUpper(transport),
+		stream:   true,
+	}
+	return conn
+}
+
 func (conn *connection) Read(buf []byte) (int, error) {
 	var (
 		num int
@@ -104,6 +122,10 @@ func (conn *connection) Read(buf []byte) (int, error) {
 }
 
 func (conn *connection) ReadFrom(buf []byte) (num int, raddr net.Addr, err error) {
+	if conn.stream {
+		num, err = conn.Read(buf)
+		return num, conn.raddr, err
+	}
 	num, raddr, err = conn.baseConn.(net.PacketConn).ReadFrom(buf)
 	if err != nil {
 		return num, raddr, utils.NewError(err, conn.logKey, "readfrom", conn.baseConn.LocalAddr().String(), raddr.String())
@@ -126,6 +148,13 @@ func (conn *connection) Write(buf []byte) (int, error) {
 }
 
 func (conn *connection) WriteTo(buf []byte, raddr net.Addr) (num int, err error) {
+	if conn.stream {
+		num, err = conn.Write(buf)
+		if err == nil {
+			logrus.Tracef("writeTo %d , %s -> %s \n %s", num, conn.baseConn.LocalAddr(), conn.raddr.String(), string(buf[:num]))
+		}
+		return num, err
+	}
 	num, err = conn.baseConn.(net.PacketConn).WriteTo(buf, raddr)
 	if err != nil {
 		return num, utils.NewError(err, conn.logKey, "writeTo", conn.baseConn.LocalAddr().String(), raddr.String())
@@ -151,6 +180,9 @@ func (conn *connection) Close() error {
 }
 
 func (conn *connection) Network() string {
+	if conn.network != "" {
+		return conn.network
+	}
 	return strings.ToUpper(conn.baseConn.LocalAddr().Network())
 }
 
@@ -165,3 +197,51 @@ func (conn *connection) SetReadDeadline(t time.Time) error {
 func (conn *connection) SetWriteDeadline(t time.Time) error {
 	return conn.baseConn.SetWriteDeadline(t)
 }
+
+// readStreamMessage 从面向连接的传输中读取一条完整的sip消息，使用Content-Length确定消息边界 RFC 3261 18.3
+func readStreamMessage(reader *bufio.Reader) ([]byte, error) {
+	var (
+		buffer bytes.Buffer
+		length int
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if buffer.Len() == 0 && strings.TrimSpace(line) == "" {
+			// 消息之间的空行(CRLF keepalive)直接跳过
+			continue
+		}
+		buffer.WriteString(line)
+		if strings.TrimSpace(line) == "" {
+			// 头部结束
+			break
+		}
+		if buffer.Len() > int(bufferSize) {
+			return nil, fmt.Errorf("message header too large, size:%d", buffer.Len())
+		}
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(line[:idx])) {
+		case "content-length", "l":
+			length, err = strconv.Atoi(strings.TrimSpace(line[idx+1:]))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid content-length:%s", line)
+			}
+		}
+	}
+	if length > int(bufferSize) {
+		return nil, fmt.Errorf("message body too large, size:%d", length)
+	}
+	if length > 0 {
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil, err
+		}
+		buffer.Write(body)
+	}
+	return buffer.Bytes(), nil
+}
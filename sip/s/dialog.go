@@ -0,0 +1,308 @@
+package sip
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/panjjo/gosip/utils"
+)
+
+// Dialog sip 对话 RFC 3261 12
+// 由INVITE或SUBSCRIBE的2xx响应建立，用来发送对话内请求(BYE INFO re-INVITE 刷新订阅等)，可直接作为数据库json字段保存和恢复
+type Dialog struct {
+	CallID string `json:"callid"`
+	// LocalURI 本端地址 From
+	LocalURI string `json:"localuri"`
+	LocalTag string `json:"localtag"`
+	// LocalContact 本端Contact
+	LocalContact string `json:"localcontact"`
+	// RemoteURI 对端地址 To
+	RemoteURI string `json:"remoteuri"`
+	RemoteTag string `json:"remotetag"`
+	// LocalSeq 本端最后发送请求的CSeq序号
+	LocalSeq uint32 `json:"localseq"`
+	// RemoteSeq 对端最后发送请求的CSeq序号
+	RemoteSeq uint32 `json:"remoteseq"`
+	// RemoteTarget 对端Contact，对话内请求的Request-URI
+	RemoteTarget string `json:"remotetarget"`
+	// RouteSet 路由集合，取自Record-Route
+	RouteSet []string `json:"routeset"`
+	// Transport 传输协议 UDP TCP TLS
+	Transport string `json:"transport"`
+	// INVITE 的 CSeq 序号，2xx 的ACK使用
+	InviteSeq uint32 `json:"inviteseq"`
+
+	// mu 保护CSeq序号、RemoteTarget和dest，对话内请求和收到的请求可能在不同协程处理
+	mu   sync.Mutex
+	dest net.Addr
+}
+
+// NewDialog 由本端发送的INVITE(SUBSCRIBE)和收到的2xx响应建立对话(UAC)
+func NewDialog(invite *Request, res *Response) (*Dialog, error) {
+	if res.StatusCode() < 200 || res.StatusCode() >= 300 {
+		return nil, fmt.Errorf("dialog must be created by 2xx response, got %d", res.StatusCode())
+	}
+	callid, ok := res.CallID()
+	if !ok {
+		return nil, errors.New("missing required 'Call-ID' header")
+	}
+	from, ok := res.From()
+	if !ok {
+		return nil, errors.New("missing required 'From' header")
+	}
+	to, ok := res.To()
+	if !ok {
+		return nil, errors.New("missing required 'To' header")
+	}
+	cseq, ok := res.CSeq()
+	if !ok {
+		return nil, errors.New("missing required 'CSeq' header")
+	}
+	d := &Dialog{
+		CallID:    string(*callid),
+		LocalURI:  from.Address.String(),
+		LocalTag:  paramValue(from.Params, "tag"),
+		RemoteURI: to.Address.String(),
+		RemoteTag: paramValue(to.Params, "tag"),
+		LocalSeq:  cseq.SeqNo,
+		InviteSeq: cseq.SeqNo,
+		RouteSet:  []string{},
+		dest:      res.Source(),
+	}
+	if via, ok := res.ViaHop(); ok {
+		d.Transport = via.Transport
+	}
+	if contact, ok := invite.Contact(); ok {
+		d.LocalContact = contact.Address.String()
+	}
+	d.RemoteTarget = d.RemoteURI
+	if contact, ok := res.Contact(); ok && contact.Address != nil {
+		d.RemoteTarget = contact.Address.String()
+	}
+	// UAC 路由集合为Record-Route的反序
+	for _, h := range res.GetHeaders("Record-Route") {
+		for _, uri := range h.(*RecordRouteHeader).Addresses {
+			d.RouteSet = append([]string{uri.String()}, d.RouteSet...)
+		}
+	}
+	return d, nil
+}
+
+// NewDialogFromRequest 由收到的INVITE和本端回复的2xx响应建立对话(UAS) RFC 3261 12.1.1
+func NewDialogFromRequest(invite *Request, res *Response) (*Dialog, error) {
+	if res.StatusCode() < 200 || res.StatusCode() >= 300 {
+		return nil, fmt.Errorf("dialog must be created by 2xx response, got %d", res.StatusCode())
+	}
+	callid, ok := invite.CallID()
+	if !ok {
+		return nil, errors.New("missing required 'Call-ID' header")
+	}
+	from, ok := invite.From()
+	if !ok {
+		return nil, errors.New("missing required 'From' header")
+	}
+	to, ok := res.To()
+	if !ok {
+		return nil, errors.New("missing required 'To' header")
+	}
+	cseq, ok := invite.CSeq()
+	if !ok {
+		return nil, errors.New("missing required 'CSeq' header")
+	}
+	d := &Dialog{
+		CallID:    string(*callid),
+		LocalURI:  to.Address.String(),
+		LocalTag:  paramValue(to.Params, "tag"),
+		RemoteURI: from.Address.String(),
+		RemoteTag: paramValue(from.Params, "tag"),
+		RemoteSeq: cseq.SeqNo,
+		RouteSet:  []string{},
+		dest:      invite.Source(),
+	}
+	if via, ok := invite.ViaHop(); ok {
+		d.Transport = via.Transport
+	}
+	if contact, ok := res.Contact(); ok && contact.Address != nil {
+		d.LocalContact = contact.Address.String()
+	}
+	d.RemoteTarget = d.RemoteURI
+	if contact, ok := invite.Contact(); ok && contact.Address != nil {
+		d.RemoteTarget = contact.Address.String()
+	}
+	// UAS 路由集合为Record-Route的顺序
+	for _, h := range invite.GetHeaders("Record-Route") {
+		for _, uri := range h.(*RecordRouteHeader).Addresses {
+			d.RouteSet = append(d.RouteSet, uri.String())
+		}
+	}
+	return d, nil
+}
+
+// ID 对话标识 Call-ID + local tag + remote tag
+func (d *Dialog) ID() string {
+	return strings.Join([]string{d.CallID, d.LocalTag, d.RemoteTag}, "|")
+}
+
+// Destination 对话请求发送地址
+func (d *Dialog) Destination() net.Addr {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dest
+}
+
+// SetDestination 设置对话请求发送地址，从数据库恢复后需要重新设置
+func (d *Dialog) SetDestination(dest net.Addr) {
+	d.mu.Lock()
+	d.dest = dest
+	d.mu.Unlock()
+}
+
+// Match 请求是否属于当前对话
+func (d *Dialog) Match(msg Message) bool {
+	callid, ok := msg.CallID()
+	if !ok || string(*callid) != d.CallID {
+		return false
+	}
+	from, ok := msg.From()
+	if !ok {
+		return false
+	}
+	to, ok := msg.To()
+	if !ok {
+		return false
+	}
+	if _, ok := msg.(*Request); ok {
+		// 对端发起的请求 From 为对端
+		return paramValue(from.Params, "tag") == d.RemoteTag && paramValue(to.Params, "tag") == d.LocalTag
+	}
+	return paramValue(from.Params, "tag") == d.LocalTag && paramValue(to.Params, "tag") == d.RemoteTag
+}
+
+// ReceiveRequest 收到对端的对话内请求，检查并更新对端CSeq，re-INVITE时更新对端Contact RFC 3261 12.2.2
+func (d *Dialog) ReceiveRequest(req *Request) error {
+	cseq, ok := req.CSeq()
+	if !ok {
+		return errors.New("missing required 'CSeq' header")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !req.IsAck() && req.Method() != CANCEL {
+		if d.RemoteSeq != 0 && cseq.SeqNo < d.RemoteSeq {
+			return fmt.Errorf("cseq %d lower than remote cseq %d", cseq.SeqNo, d.RemoteSeq)
+		}
+		d.RemoteSeq = cseq.SeqNo
+	}
+	if req.IsInvite() {
+		if contact, ok := req.Contact(); ok && contact.Address != nil {
+			d.RemoteTarget = contact.Address.String()
+		}
+	}
+	return nil
+}
+
+// UpdateTarget re-INVITE 的2xx响应更新对端Contact
+func (d *Dialog) UpdateTarget(res *Response) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if contact, ok := res.Contact(); ok && contact.Address != nil {
+		d.RemoteTarget = contact.Address.String()
+	}
+	if cseq, ok := res.CSeq(); ok && cseq.MethodName == INVITE {
+		d.InviteSeq = cseq.SeqNo
+	}
+}
+
+// NewRequest 生成对话内请求 RFC 3261 12.2.1.1
+// ACK 使用INVITE的CSeq序号，CANCEL 不在对话内发送，其他请求CSeq序号递增
+func (d *Dialog) NewRequest(method RequestMethod, contentType *ContentType, body []byte) (*Request, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	target, err := ParseURI(d.RemoteTarget)
+	if err != nil {
+		return nil, utils.NewError(err, "dialog remote target error", d.RemoteTarget)
+	}
+	localURI, err := ParseURI(d.LocalURI)
+	if err != nil {
+		return nil, utils.NewError(err, "dialog local uri error", d.LocalURI)
+	}
+	remoteURI, err := ParseURI(d.RemoteURI)
+	if err != nil {
+		return nil, utils.NewError(err, "dialog remote uri error", d.RemoteURI)
+	}
+	var seq uint32
+	if method == ACK {
+		seq = d.InviteSeq
+	} else {
+		d.LocalSeq++
+		seq = d.LocalSeq
+		if method == INVITE {
+			d.InviteSeq = seq
+		}
+	}
+	callid := CallID(d.CallID)
+	hb := NewHeaderBuilder().SetFrom(&Address{URI: localURI, Params: NewParams().Add("tag", String{Str: d.LocalTag})}).
+		SetToWithParam(&Address{URI: remoteURI, Params: NewParams().Add("tag", String{Str: d.RemoteTag})}).
+		AddVia(&ViaHop{
+			Transport: d.Transport,
+			Params:    NewParams().Add("branch", String{Str: GenerateBranch()}),
+		}).SetCallID(&callid).SetMethod(method).SetSeqNo(uint(seq))
+	if contentType != nil {
+		hb.SetContentType(contentType)
+	}
+	if d.LocalContact != "" && (method == INVITE || method == SUBSCRIBE) {
+		if contact, err := ParseURI(d.LocalContact); err == nil {
+			hb.SetContact(&Address{URI: contact, Params: NewParams()})
+		}
+	}
+	req := NewRequest("", method, target, DefaultSipVersion, hb.Build(), body)
+	if len(d.RouteSet) > 0 {
+		route := &RouteHeader{Addresses: []*URI{}}
+		for _, r := range d.RouteSet {
+			uri, err := ParseURI(r)
+			if err != nil {
+				return nil, utils.NewError(err, "dialog route error", r)
+			}
+			route.Addresses = append(route.Addresses, uri)
+		}
+		req.AppendHeader(route)
+	}
+	req.SetDestination(d.dest)
+	return req, nil
+}
+
+// Value 数据库保存
+func (d *Dialog) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return utils.JSONEncode(d), nil
+}
+
+// Scan 数据库读取
+func (d *Dialog) Scan(value interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch t := value.(type) {
+	case []byte:
+		return utils.JSONDecode(t, d)
+	case string:
+		return utils.JSONDecode([]byte(t), d)
+	}
+	return errors.New(fmt.Sprint("Failed to unmarshal dialog value:", value))
+}
+
+func paramValue(params Params, key string) string {
+	if params == nil {
+		return ""
+	}
+	if v, ok := params.Get(key); ok && v != nil {
+		return v.String()
+	}
+	return ""
+}
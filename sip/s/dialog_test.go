@@ -0,0 +1,60 @@
+package sip
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// 对话内请求、收到的请求和设置发送地址在不同协程并发执行
+func TestDialogConcurrent(t *testing.T) {
+	invite := newServerRequest(t, INVITE, "z9hG4bK-dialog", "dialog-callid", 1)
+	res := NewResponseFromRequest("", invite, http.StatusOK, "OK", nil)
+	setToTag(res, "to1")
+	dialog, err := NewDialogFromRequest(invite, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 50
+	seqs := make(chan uint32, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			req, err := dialog.NewRequest(INFO, nil, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			cseq, _ := req.CSeq()
+			seqs <- cseq.SeqNo
+		}()
+		go func(seq int) {
+			defer wg.Done()
+			dialog.ReceiveRequest(newServerRequest(t, INFO, "z9hG4bK-info", "dialog-callid", seq))
+		}(i + 2)
+		go func() {
+			defer wg.Done()
+			dialog.SetDestination(testRemote)
+		}()
+	}
+	wg.Wait()
+	close(seqs)
+	used := map[uint32]bool{}
+	for seq := range seqs {
+		if used[seq] {
+			t.Errorf("duplicate local cseq %d", seq)
+		}
+		used[seq] = true
+	}
+	if dialog.LocalSeq != n {
+		t.Errorf("local cseq %d, want %d", dialog.LocalSeq, n)
+	}
+	if dialog.RemoteSeq != n+1 {
+		t.Errorf("remote cseq %d, want %d", dialog.RemoteSeq, n+1)
+	}
+	if dialog.Destination() != testRemote {
+		t.Errorf("destination %v, want %v", dialog.Destination(), testRemote)
+	}
+}
@@ -1355,7 +1355,7 @@ func (ua *UserAgentHeader) Equals(other interface{}) bool {
 
 // ==================   AllowHeader   ================
 
-var defaultAllowMethods = &AllowHeader{INVITE, ACK, CANCEL, MESSAGE, REGISTER}
+var defaultAllowMethods = &AllowHeader{INVITE, ACK, CANCEL, BYE, INFO, MESSAGE, NOTIFY, REGISTER}
 
 // AllowHeader AllowHeader
 type AllowHeader []RequestMethod
@@ -1471,8 +1471,8 @@ func (route *RouteHeader) Clone() Header {
 		Addresses: []*URI{},
 	}
 
-	for i, uri := range route.Addresses {
-		newRoute.Addresses[i] = uri.Clone()
+	for _, uri := range route.Addresses {
+		newRoute.Addresses = append(newRoute.Addresses, uri.Clone())
 	}
 
 	return newRoute
@@ -1531,8 +1531,8 @@ func (route *RecordRouteHeader) Clone() Header {
 		Addresses: []*URI{},
 	}
 
-	for i, uri := range route.Addresses {
-		newRoute.Addresses[i] = uri.Clone()
+	for _, uri := range route.Addresses {
+		newRoute.Addresses = append(newRoute.Addresses, uri.Clone())
 	}
 
 	return newRoute
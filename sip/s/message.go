@@ -17,14 +17,14 @@ type RequestMethod string
 // It's nicer to avoid using raw strings to represent methods, so the following standard
 // method names are defined here as constants for convenience.
 const (
-	INVITE   RequestMethod = "INVITE"
-	ACK      RequestMethod = "ACK"
-	CANCEL   RequestMethod = "CANCEL"
-	BYE      RequestMethod = "BYE"
-	REGISTER RequestMethod = "REGISTER"
-	OPTIONS  RequestMethod = "OPTIONS"
-	// SUBSCRIBE RequestMethod = "SUBSCRIBE"
-	// NOTIFY  RequestMethod = "NOTIFY"
+	INVITE    RequestMethod = "INVITE"
+	ACK       RequestMethod = "ACK"
+	CANCEL    RequestMethod = "CANCEL"
+	BYE       RequestMethod = "BYE"
+	REGISTER  RequestMethod = "REGISTER"
+	OPTIONS   RequestMethod = "OPTIONS"
+	SUBSCRIBE RequestMethod = "SUBSCRIBE"
+	NOTIFY    RequestMethod = "NOTIFY"
 	// REFER   RequestMethod = "REFER"
 	INFO    RequestMethod = "INFO"
 	MESSAGE RequestMethod = "MESSAGE"
@@ -229,6 +229,16 @@ func (uri *URI) SetHost(host string) {
 	uri.FHost = host
 }
 
+// IsEncrypted sips uri
+func (uri *URI) IsEncrypted() bool {
+	return uri.FIsEncrypted
+}
+
+// SetEncrypted 设置为sips uri，通过tls传输的请求使用
+func (uri *URI) SetEncrypted(encrypted bool) {
+	uri.FIsEncrypted = encrypted
+}
+
 // Generates the string representation of a SipUri struct.
 func (uri *URI) String() string {
 	var buffer bytes.Buffer
@@ -78,6 +78,34 @@ var ContentTypeSDP = ContentType("application/sdp")
 // ContentTypeXML XML contenttype
 var ContentTypeXML = ContentType("Application/MANSCDP+xml")
 
+// ContentTypeRTSP 回放控制 contenttype
+var ContentTypeRTSP = ContentType("Application/MANSRTSP")
+
+// 回放控制命令 GB28181 附录B
+const (
+	// RTSPPlay 播放、恢复播放、拖动、倍速
+	RTSPPlay = "PLAY"
+	// RTSPPause 暂停
+	RTSPPause = "PAUSE"
+	// RTSPTeardown 停止
+	RTSPTeardown = "TEARDOWN"
+)
+
+// GetMANSRTSP 回放控制消息体，rng 为npt播放起点(秒)，scale 为播放倍速，为空时不携带
+func GetMANSRTSP(method string, cseq uint32, rng, scale string) []byte {
+	body := fmt.Sprintf("%s RTSP/1.0\r\nCSeq: %d\r\n", method, cseq)
+	if method == RTSPPause {
+		body += "PauseTime: now\r\n"
+	}
+	if rng != "" {
+		body += fmt.Sprintf("Range: npt=%s-\r\n", rng)
+	}
+	if scale != "" {
+		body += fmt.Sprintf("Scale: %s\r\n", scale)
+	}
+	return []byte(body + "\r\n")
+}
+
 var (
 	// CatalogXML 获取设备列表xml样式
 	CatalogXML = `<?xml version="1.0" encoding="GB2312"?>
@@ -106,6 +134,134 @@ var (
 <SN>%d</SN>
 <DeviceID>%s</DeviceID>
 </Query>
+`
+	// DeviceStatusXML 查询设备状态xml样式
+	DeviceStatusXML = `<?xml version="1.0" encoding="GB2312"?>
+<Query>
+<CmdType>DeviceStatus</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+</Query>
+`
+	// ConfigDownloadXML 查询设备配置xml样式
+	ConfigDownloadXML = `<?xml version="1.0" encoding="GB2312"?>
+<Query>
+<CmdType>ConfigDownload</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<ConfigType>%s</ConfigType>
+</Query>
+`
+	// DeviceConfigXML 设备配置xml样式
+	DeviceConfigXML = `<?xml version="1.0" encoding="GB2312"?>
+<Control>
+<CmdType>DeviceConfig</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+%s
+</Control>
+`
+	// KeepaliveXML 向上级平台发送心跳xml样式
+	KeepaliveXML = `<?xml version="1.0" encoding="GB2312"?>
+<Notify>
+<CmdType>Keepalive</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<Status>OK</Status>
+</Notify>
+`
+	// DeviceInfoResponseXML 应答上级平台设备信息查询xml样式
+	DeviceInfoResponseXML = `<?xml version="1.0" encoding="GB2312"?>
+<Response>
+<CmdType>DeviceInfo</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<Result>OK</Result>
+<DeviceName>%s</DeviceName>
+<Manufacturer>%s</Manufacturer>
+<Model>%s</Model>
+<Firmware>%s</Firmware>
+<Channel>%d</Channel>
+</Response>
+`
+	// DeviceStatusResponseXML 应答上级平台设备状态查询xml样式
+	DeviceStatusResponseXML = `<?xml version="1.0" encoding="GB2312"?>
+<Response>
+<CmdType>DeviceStatus</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<Result>OK</Result>
+<Online>%s</Online>
+<Status>OK</Status>
+<DeviceTime>%s</DeviceTime>
+</Response>
+`
+	// BroadcastXML 语音广播通知xml样式
+	BroadcastXML = `<?xml version="1.0" encoding="GB2312"?>
+<Notify>
+<CmdType>Broadcast</CmdType>
+<SN>%d</SN>
+<SourceID>%s</SourceID>
+<TargetID>%s</TargetID>
+</Notify>
+`
+	// PTZCmdXML 云台控制xml样式
+	PTZCmdXML = `<?xml version="1.0" encoding="GB2312"?>
+<Control>
+<CmdType>DeviceControl</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<PTZCmd>%s</PTZCmd>
+<Info>
+<ControlPriority>5</ControlPriority>
+</Info>
+</Control>
+`
+	// DeviceControlXML 设备控制xml样式
+	DeviceControlXML = `<?xml version="1.0" encoding="GB2312"?>
+<Control>
+<CmdType>DeviceControl</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<%s>%s</%s>
+</Control>
+`
+	// AlarmResponseXML 报警通知应答xml样式
+	AlarmResponseXML = `<?xml version="1.0" encoding="GB2312"?>
+<Response>
+<CmdType>Alarm</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<Result>OK</Result>
+</Response>
+`
+	// AlarmSubscribeXML 报警订阅xml样式
+	AlarmSubscribeXML = `<?xml version="1.0" encoding="GB2312"?>
+<Query>
+<CmdType>Alarm</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<StartAlarmPriority>%d</StartAlarmPriority>
+<EndAlarmPriority>%d</EndAlarmPriority>
+<AlarmMethod>%d</AlarmMethod>
+</Query>
+`
+	// MobilePositionSubscribeXML 移动设备位置订阅xml样式
+	MobilePositionSubscribeXML = `<?xml version="1.0" encoding="GB2312"?>
+<Query>
+<CmdType>MobilePosition</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+<Interval>%d</Interval>
+</Query>
+`
+	// PresetQueryXML 查询预置位xml样式
+	PresetQueryXML = `<?xml version="1.0" encoding="GB2312"?>
+<Query>
+<CmdType>PresetQuery</CmdType>
+<SN>%d</SN>
+<DeviceID>%s</DeviceID>
+</Query>
 `
 )
 
@@ -114,11 +270,76 @@ func GetDeviceInfoXML(id string) []byte {
 	return []byte(fmt.Sprintf(DeviceInfoXML, utils.RandInt(100000, 999999), id))
 }
 
+// GetDeviceStatusXML 获取设备状态指令
+func GetDeviceStatusXML(id string, sn int) []byte {
+	return []byte(fmt.Sprintf(DeviceStatusXML, sn, id))
+}
+
+// GetConfigDownloadXML 查询设备配置，configType 配置类型，多个用/分隔
+func GetConfigDownloadXML(id string, sn int, configType string) []byte {
+	return []byte(fmt.Sprintf(ConfigDownloadXML, sn, id, configType))
+}
+
+// GetDeviceConfigXML 设备配置，config 配置参数xml，如<BasicParam>...</BasicParam>
+func GetDeviceConfigXML(id string, sn int, config string) []byte {
+	return []byte(fmt.Sprintf(DeviceConfigXML, sn, id, config))
+}
+
+// GetKeepaliveXML 向上级平台发送心跳
+func GetKeepaliveXML(id string, sn int) []byte {
+	return []byte(fmt.Sprintf(KeepaliveXML, sn, id))
+}
+
+// GetDeviceInfoResponseXML 应答上级平台设备信息查询
+func GetDeviceInfoResponseXML(id string, sn int, name, manufacturer, model, firmware string, channel int) []byte {
+	return []byte(fmt.Sprintf(DeviceInfoResponseXML, sn, id, name, manufacturer, model, firmware, channel))
+}
+
+// GetDeviceStatusResponseXML 应答上级平台设备状态查询，online 为 ONLINE 或 OFFLINE
+func GetDeviceStatusResponseXML(id string, sn int, online string) []byte {
+	return []byte(fmt.Sprintf(DeviceStatusResponseXML, sn, id, online, time.Now().Format("2006-01-02T15:04:05")))
+}
+
+// GetBroadcastXML 语音广播通知，sourceID 语音输入设备（平台）编码，targetID 语音输出设备编码
+func GetBroadcastXML(sourceID, targetID string, sn int) []byte {
+	return []byte(fmt.Sprintf(BroadcastXML, sn, sourceID, targetID))
+}
+
 // GetCatalogXML 获取NVR下设备列表指令
 func GetCatalogXML(id string) []byte {
 	return []byte(fmt.Sprintf(CatalogXML, utils.RandInt(100000, 999999), id))
 }
 
+// GetPTZCmdXML 云台控制指令
+func GetPTZCmdXML(id, cmd string) []byte {
+	return []byte(fmt.Sprintf(PTZCmdXML, utils.RandInt(100000, 999999), id, cmd))
+}
+
+// GetDeviceControlXML 设备控制指令，cmd 控制命令字段名，value 命令值
+func GetDeviceControlXML(id string, sn int, cmd, value string) []byte {
+	return []byte(fmt.Sprintf(DeviceControlXML, sn, id, cmd, value, cmd))
+}
+
+// GetAlarmResponseXML 报警通知应答，sn 与报警通知一致
+func GetAlarmResponseXML(id string, sn int) []byte {
+	return []byte(fmt.Sprintf(AlarmResponseXML, sn, id))
+}
+
+// GetAlarmSubscribeXML 报警订阅，报警级别0和报警方式0表示全部
+func GetAlarmSubscribeXML(id string, sn, startPriority, endPriority, method int) []byte {
+	return []byte(fmt.Sprintf(AlarmSubscribeXML, sn, id, startPriority, endPriority, method))
+}
+
+// GetMobilePositionSubscribeXML 移动设备位置订阅，interval 上报间隔(秒)
+func GetMobilePositionSubscribeXML(id string, sn, interval int) []byte {
+	return []byte(fmt.Sprintf(MobilePositionSubscribeXML, sn, id, interval))
+}
+
+// GetPresetQueryXML 查询预置位指令
+func GetPresetQueryXML(id string, sn int) []byte {
+	return []byte(fmt.Sprintf(PresetQueryXML, sn, id))
+}
+
 // GetRecordInfoXML 获取录像文件列表指令
 func GetRecordInfoXML(id string, sceqNo int, start, end int64) []byte {
 	return []byte(fmt.Sprintf(RecordInfoXML, sceqNo, id, time.Unix(start, 0).Format("2006-01-02T15:04:05"), time.Unix(end, 0).Format("2006-01-02T15:04:05")))
@@ -614,7 +614,7 @@ func (p *parser) start() {
 				if err == nil {
 					headers = append(headers, newHeaders...)
 				} else {
-					logrus.Warnf("skip header '%s' due to error: %s", buffer, err)
+					logrus.Warnf("skip header '%s' due to error: %s", buffer.String(), err)
 				}
 				buffer.Reset()
 			}
@@ -793,7 +793,7 @@ func ParseSipURI(uriStr string) (uri URI, err error) {
 	uriStrCopy := uriStr
 
 	// URI should start 'sip' or 'sips'. Check the first 3 chars.
-	if strings.ToLower(uriStr[:3]) != "sip" {
+	if len(uriStr) < 4 || strings.ToLower(uriStr[:3]) != "sip" {
 		err = fmt.Errorf("invalid SIP uri protocol name in '%s'", uriStrCopy)
 		return
 	}
@@ -806,7 +806,7 @@ func ParseSipURI(uriStr string) (uri URI, err error) {
 	}
 
 	// The 'sip' or 'sips' protocol name should be followed by a ':' character.
-	if uriStr[0] != ':' {
+	if len(uriStr) == 0 || uriStr[0] != ':' {
 		err = fmt.Errorf("no ':' after protocol name in SIP uri '%s'", uriStrCopy)
 		return
 	}
@@ -0,0 +1,198 @@
+package sip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/panjjo/gosip/utils"
+)
+
+// Registration 向上级注册(UAC) RFC 3261 10.2
+// 同一注册使用相同的Call-ID，CSeq递增，收到401/407时使用摘要认证重新发送
+// 认证质询缓存后，之后的请求直接携带认证信息
+type Registration struct {
+	srv *Server
+	// AOR 本端地址 sip:本端编号@本端域
+	AOR *Address
+	// Registrar 上级地址 sip:上级编号@上级域
+	Registrar *URI
+	Transport string
+	Username  string
+	Password  string
+
+	dest   net.Addr
+	callID CallID
+	mu     sync.Mutex
+	seq    uint32
+	// 最后一次的认证质询和认证头名称
+	challenge string
+	authName  string
+	nonce     string
+	// 使用当前nonce的请求次数
+	nc uint32
+}
+
+// NewRegistration 创建注册，addr 为上级信令地址 ip:port，只支持UDP TCP
+func NewRegistration(srv *Server, aor *Address, registrar *URI, transport, addr, username, password string) (*Registration, error) {
+	transport = strings.ToUpper(transport)
+	if transport == "" {
+		transport = "UDP"
+	}
+	var (
+		dest net.Addr
+		err  error
+	)
+	switch transport {
+	case "UDP":
+		dest, err = net.ResolveUDPAddr("udp", addr)
+	case "TCP":
+		dest, err = net.ResolveTCPAddr("tcp", addr)
+	case "TLS":
+		// tls 连接由设备发起并保持，不主动建立
+		return nil, errors.New("unsupported transport TLS, tls connections are only accepted from devices")
+	default:
+		return nil, fmt.Errorf("unsupported transport %s", transport)
+	}
+	if err != nil {
+		return nil, utils.NewError(err, "resolve registrar addr", addr)
+	}
+	if aor.Params == nil {
+		aor.Params = NewParams()
+	}
+	return &Registration{
+		srv:       srv,
+		AOR:       aor,
+		Registrar: registrar,
+		Transport: transport,
+		Username:  username,
+		Password:  password,
+		dest:      dest,
+		callID:    CallID(utils.RandString(32)),
+	}, nil
+}
+
+// Destination 上级信令地址
+func (r *Registration) Destination() net.Addr {
+	return r.dest
+}
+
+// Register 发送注册，expires 为0时注销
+func (r *Registration) Register(expires int) (*Response, error) {
+	return r.do(func() *Request {
+		req := r.newRequest(REGISTER, r.callID, nil, nil)
+		exp := Expires(expires)
+		req.AppendHeader(&exp)
+		return req
+	})
+}
+
+// Request 向上级发送对话外请求，如 MESSAGE
+func (r *Registration) Request(method RequestMethod, contentType *ContentType, body []byte) (*Response, error) {
+	callID := CallID(utils.RandString(32))
+	return r.do(func() *Request {
+		return r.newRequest(method, callID, contentType, body)
+	})
+}
+
+// 发送请求，已有认证质询时直接携带摘要，返回401/407时使用新的质询重新发送一次
+func (r *Registration) do(build func() *Request) (*Response, error) {
+	req := build()
+	r.authorize(req)
+	res, err := r.send(req)
+	if err != nil {
+		return nil, err
+	}
+	code := res.StatusCode()
+	if code != http.StatusUnauthorized && code != http.StatusProxyAuthRequired {
+		return res, nil
+	}
+	challengeName, authName := "WWW-Authenticate", "Authorization"
+	if code == http.StatusProxyAuthRequired {
+		challengeName, authName = "Proxy-Authenticate", "Proxy-Authorization"
+	}
+	hdrs := res.GetHeaders(challengeName)
+	if len(hdrs) == 0 {
+		return res, fmt.Errorf("missing '%s' header", challengeName)
+	}
+	challenge := ""
+	if h, ok := hdrs[0].(*GenericHeader); ok {
+		challenge = h.Contents
+	}
+	r.setChallenge(challenge, authName)
+	req = build()
+	r.authorize(req)
+	return r.send(req)
+}
+
+// setChallenge 缓存认证质询，nonce 变化时nc从1开始 RFC 7616 3.4
+func (r *Registration) setChallenge(challenge, authName string) {
+	nonce := AuthFromValue(challenge).Get("nonce")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if nonce != r.nonce {
+		r.nc = 0
+	}
+	r.challenge, r.authName, r.nonce = challenge, authName, nonce
+}
+
+// authorize 使用缓存的认证质询计算摘要，没有质询时不处理
+func (r *Registration) authorize(req *Request) {
+	r.mu.Lock()
+	if r.challenge == "" {
+		r.mu.Unlock()
+		return
+	}
+	r.nc++
+	challenge, authName, nc := r.challenge, r.authName, r.nc
+	r.mu.Unlock()
+	auth := NewAuthorization(challenge, r.Username, r.Password, string(req.Method()), req.Recipient().String(), nc)
+	req.AppendHeader(&GenericHeader{HeaderName: authName, Contents: auth.String()})
+}
+
+func (r *Registration) send(req *Request) (*Response, error) {
+	tx, err := r.srv.Request(req)
+	if err != nil {
+		return nil, err
+	}
+	res := tx.GetResponse()
+	if res == nil {
+		return nil, errors.New("response timeout")
+	}
+	return res, nil
+}
+
+func (r *Registration) newRequest(method RequestMethod, callID CallID, contentType *ContentType, body []byte) *Request {
+	r.mu.Lock()
+	r.seq++
+	seq := r.seq
+	r.mu.Unlock()
+	to := &Address{URI: r.Registrar, Params: NewParams()}
+	if method == REGISTER {
+		// 注册的To为本端地址
+		to = r.AOR
+	}
+	hb := NewHeaderBuilder().SetFrom(r.AOR).SetTo(to).AddVia(&ViaHop{
+		Transport: r.Transport,
+		Params:    NewParams().Add("branch", String{Str: GenerateBranch()}),
+	}).SetCallID(&callID).SetMethod(method).SetSeqNo(uint(seq)).SetContact(r.contact())
+	if contentType != nil {
+		hb.SetContentType(contentType)
+	}
+	req := NewRequest("", method, r.Registrar, DefaultSipVersion, hb.Build(), body)
+	req.SetDestination(r.dest)
+	return req
+}
+
+// 本端Contact，使用服务监听的地址
+func (r *Registration) contact() *Address {
+	host, port := r.srv.LocalAddr(r.Transport)
+	uri := &URI{FUser: r.AOR.URI.FUser, FHost: host.String(), FPort: port, FUriParams: NewParams()}
+	if r.Transport != "UDP" {
+		uri.FUriParams.Add("transport", String{Str: strings.ToLower(r.Transport)})
+	}
+	return &Address{URI: uri, Params: NewParams()}
+}
@@ -0,0 +1,115 @@
+package sip
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// testRegistrar 模拟上级平台，未携带认证或nonce不是当前nonce时回复401
+type testRegistrar struct {
+	t     *testing.T
+	srv   *Server
+	mu    sync.Mutex
+	nonce string
+	// 收到的请求的认证信息，未认证为nil
+	auths []*Authorization
+}
+
+func (r *testRegistrar) receive(msg string) {
+	req, ok := parseMessage(r.t, msg, testLocal).(*Request)
+	if !ok || req.IsAck() {
+		return
+	}
+	r.mu.Lock()
+	nonce := r.nonce
+	var auth *Authorization
+	if hdrs := req.GetHeaders("Authorization"); len(hdrs) > 0 {
+		auth = AuthFromValue(hdrs[0].(*GenericHeader).Contents)
+	}
+	r.auths = append(r.auths, auth)
+	r.mu.Unlock()
+
+	res := NewResponseFromRequest("", req, http.StatusOK, "OK", nil)
+	if auth == nil || auth.Get("nonce") != nonce ||
+		auth.SetPassword("pwd").SetMethod(string(req.Method())).CalcResponse() != auth.Get("response") {
+		res = NewResponseFromRequest("", req, http.StatusUnauthorized, "Unauthorized", nil)
+		res.AppendHeader(&GenericHeader{HeaderName: "WWW-Authenticate", Contents: `Digest realm="3402000000",qop="auth",nonce="` + nonce + `"`})
+	}
+	// 在事务锁外交给事务
+	go r.srv.handlerResponse(res)
+}
+
+func (r *testRegistrar) take() []*Authorization {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	auths := r.auths
+	r.auths = nil
+	return auths
+}
+
+func TestRegistrationDigest(t *testing.T) {
+	srv, conn := newTestServer(t)
+	registrar := &testRegistrar{t: t, srv: srv, nonce: "nonce1"}
+	conn.onWrite = registrar.receive
+
+	aor, err := ParseSipURI("sip:34020000002000000001@3402000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := ParseSipURI("sip:34020000002000000002@3402000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := NewRegistration(srv, &Address{URI: &aor}, &uri, "UDP", testRemote.String(), "34020000002000000001", "pwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	register := func() {
+		t.Helper()
+		res, err := reg.Register(3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode() != http.StatusOK {
+			t.Fatalf("want 200, got %d", res.StatusCode())
+		}
+	}
+
+	// 首次注册收到401后认证，nc从1开始
+	register()
+	auths := registrar.take()
+	if len(auths) != 2 || auths[0] != nil || auths[1].Get("nc") != "00000001" {
+		t.Fatalf("want unauthenticated request then nc=1, got %v", auths)
+	}
+	// 之后的请求直接携带认证，同一nonce的nc递增
+	register()
+	if _, err := reg.Request(MESSAGE, &ContentTypeXML, []byte("<Query/>")); err != nil {
+		t.Fatal(err)
+	}
+	auths = registrar.take()
+	if len(auths) != 2 || auths[0] == nil || auths[0].Get("nc") != "00000002" || auths[1] == nil || auths[1].Get("nc") != "00000003" {
+		t.Fatalf("want preemptive nc=2,3, got %v", auths)
+	}
+	// nonce 变化后nc重新从1开始
+	registrar.mu.Lock()
+	registrar.nonce = "nonce2"
+	registrar.mu.Unlock()
+	register()
+	auths = registrar.take()
+	if len(auths) != 2 || auths[0].Get("nonce") != "nonce1" || auths[1].Get("nonce") != "nonce2" || auths[1].Get("nc") != "00000001" {
+		t.Fatalf("want nc reset for new nonce, got %v", auths)
+	}
+}
+
+func TestRegistrationTransport(t *testing.T) {
+	srv, _ := newTestServer(t)
+	aor, _ := ParseSipURI("sip:34020000002000000001@3402000000")
+	uri, _ := ParseSipURI("sip:34020000002000000002@3402000000")
+	for transport, ok := range map[string]bool{"": true, "udp": true, "TCP": true, "TLS": false, "SCTP": false} {
+		_, err := NewRegistration(srv, &Address{URI: &aor}, &uri, transport, testRemote.String(), "", "")
+		if (err == nil) != ok {
+			t.Errorf("transport %q: got %v", transport, err)
+		}
+	}
+}
@@ -85,6 +85,34 @@ func NewRequestFromResponse(method RequestMethod, inviteResponse *Response) *Req
 	return ackRequest
 }
 
+// newAckRequest 客户端INVITE事务收到非2xx最终响应时生成的ACK，RFC 3261 17.1.1.3
+// 与INVITE使用相同的Request-URI、Call-ID、From、CSeq序号和顶层Via，To取自响应
+func newAckRequest(invite *Request, res *Response) *Request {
+	ackRequest := NewRequest(
+		"",
+		ACK,
+		invite.Recipient().Clone(),
+		invite.SipVersion(),
+		[]Header{},
+		[]byte{},
+	)
+	if via, ok := invite.ViaHop(); ok {
+		ackRequest.AppendHeader(ViaHeader{via.Clone()})
+	}
+	CopyHeaders("Route", invite, ackRequest)
+	CopyHeaders("From", invite, ackRequest)
+	CopyHeaders("To", res, ackRequest)
+	CopyHeaders("Call-ID", invite, ackRequest)
+	if cseq, ok := invite.CSeq(); ok {
+		ackRequest.AppendHeader(&CSeq{SeqNo: cseq.SeqNo, MethodName: ACK})
+	}
+	CopyHeaders("Max-Forwards", invite, ackRequest)
+	ackRequest.SetBody([]byte{}, true)
+	ackRequest.SetSource(invite.Source())
+	ackRequest.SetDestination(invite.Destination())
+	return ackRequest
+}
+
 // StartLine returns Request Line - RFC 2361 7.1.
 func (req *Request) StartLine() string {
 	var buffer bytes.Buffer
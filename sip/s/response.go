@@ -7,6 +7,13 @@ import (
 	"github.com/gofrs/uuid"
 )
 
+// sip 特有的响应码，其他响应码与http相同 RFC 3261 21
+const (
+	StatusTemporarilyUnavailable      = 480
+	StatusCallTransactionDoesNotExist = 481
+	StatusRequestTerminated           = 487
+)
+
 // Response Response
 type Response struct {
 	message
@@ -45,9 +52,8 @@ func NewResponseFromRequest(
 	res.SetSource(req.Destination())
 	res.SetDestination(req.Source())
 
-	if len(body) > 0 {
-		res.SetBody(body, true)
-	}
+	// tcp 传输必须携带 Content-Length
+	res.SetBody(body, true)
 
 	return res
 }
@@ -1,10 +1,16 @@
 package sip
 
 import (
+	"bufio"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/panjjo/gosip/utils"
 	"github.com/sirupsen/logrus"
@@ -22,20 +28,31 @@ type Server struct {
 	udpaddr net.Addr
 	conn    Connection
 
+	// 面向连接的传输 key=transport-raddr，cmu 同时保护监听时设置的conn、parser、ports、host
+	cmu   *sync.RWMutex
+	conns map[string]Connection
+	// tcp 消息解析，主动发起tcp连接时使用
+	tcpParser *parser
+	tlsParser *parser
+
 	txs *transacionts
 
 	hmu             *sync.RWMutex
 	requestHandlers map[RequestMethod]RequestHandler
 
-	port *Port
-	host net.IP
+	// 各传输协议监听端口 key=transport
+	ports map[string]*Port
+	host  net.IP
 }
 
 // NewServer NewServer
 func NewServer() *Server {
 	activeTX = &transacionts{txs: map[string]*Transaction{}, rwm: &sync.RWMutex{}}
 	srv := &Server{hmu: &sync.RWMutex{},
+		cmu:             &sync.RWMutex{},
+		conns:           map[string]Connection{},
 		txs:             activeTX,
+		ports:           map[string]*Port{},
 		requestHandlers: map[RequestMethod]RequestHandler{}}
 	return srv
 }
@@ -46,12 +63,20 @@ func NewServer() *Server {
 func (s *Server) getTX(key string) *Transaction {
 	return s.txs.getTX(key)
 }
-func (s *Server) mustTX(key string) *Transaction {
-	tx := s.txs.getTX(key)
-	if tx == nil {
-		tx = s.txs.newTX(key, s.conn)
+
+// listenOn 记录监听端口并解析本机地址，各传输协议的监听同时启动
+func (s *Server) listenOn(transport string, port int, addr string) {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+	s.ports[transport] = NewPort(port)
+	if s.host != nil {
+		return
 	}
-	return tx
+	host, err := utils.ResolveSelfIP()
+	if err != nil {
+		logrus.Fatal("resolveip err", err, addr)
+	}
+	s.host = host
 }
 
 // ListenUDPServer ListenUDPServer
@@ -60,16 +85,15 @@ func (s *Server) ListenUDPServer(addr string) {
 	if err != nil {
 		logrus.Fatal("net.ResolveUDPAddr err", err, addr)
 	}
-	s.port = NewPort(udpaddr.Port)
-	s.host, err = utils.ResolveSelfIP()
-	if err != nil {
-		logrus.Fatal("net.ListenUDP resolveip err", err, addr)
-	}
+	s.listenOn("UDP", udpaddr.Port, addr)
 	udp, err := net.ListenUDP("udp", udpaddr)
 	if err != nil {
 		logrus.Fatal("net.ListenUDP err", err, addr)
 	}
-	s.conn = newUDPConnection(udp)
+	conn := newUDPConnection(udp)
+	s.cmu.Lock()
+	s.conn = conn
+	s.cmu.Unlock()
 	var (
 		raddr net.Addr
 		num   int
@@ -79,7 +103,7 @@ func (s *Server) ListenUDPServer(addr string) {
 	defer parser.stop()
 	go s.handlerListen(parser.out)
 	for {
-		num, raddr, err = s.conn.ReadFrom(buf)
+		num, raddr, err = conn.ReadFrom(buf)
 		if err != nil {
 			logrus.Errorln("udp.ReadFromUDP err", err)
 			continue
@@ -88,6 +112,172 @@ func (s *Server) ListenUDPServer(addr string) {
 	}
 }
 
+// ListenTCPServer ListenTCPServer
+func (s *Server) ListenTCPServer(addr string) {
+	tcpaddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		logrus.Fatal("net.ResolveTCPAddr err", err, addr)
+	}
+	s.listenOn("TCP", tcpaddr.Port, addr)
+	listener, err := net.ListenTCP("tcp", tcpaddr)
+	if err != nil {
+		logrus.Fatal("net.ListenTCP err", err, addr)
+	}
+	parser := newParser()
+	s.cmu.Lock()
+	s.tcpParser = parser
+	s.cmu.Unlock()
+	defer parser.stop()
+	go s.handlerListen(parser.out)
+	s.acceptStream("TCP", listener, parser)
+}
+
+// ListenTLSServer sip over tls(sips)
+func (s *Server) ListenTLSServer(addr, certFile, keyFile string) {
+	tcpaddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		logrus.Fatal("net.ResolveTCPAddr err", err, addr)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		logrus.Fatal("tls.LoadX509KeyPair err", err, certFile, keyFile)
+	}
+	s.listenOn("TLS", tcpaddr.Port, addr)
+	listener, err := tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		logrus.Fatal("tls.Listen err", err, addr)
+	}
+	parser := newParser()
+	s.cmu.Lock()
+	s.tlsParser = parser
+	s.cmu.Unlock()
+	defer parser.stop()
+	go s.handlerListen(parser.out)
+	s.acceptStream("TLS", listener, parser)
+}
+
+// acceptStream 监听关闭后退出，其他错误(如文件描述符耗尽)按 net/http 的方式退避重试
+func (s *Server) acceptStream(transport string, listener net.Listener, parser *parser) {
+	var tempDelay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				logrus.Infoln(transport, "listener closed")
+				return
+			}
+			if tempDelay == 0 {
+				tempDelay = 5 * time.Millisecond
+			} else {
+				tempDelay *= 2
+			}
+			if tempDelay > time.Second {
+				tempDelay = time.Second
+			}
+			logrus.Errorln(transport, "Accept err", err, "retrying in", tempDelay)
+			time.Sleep(tempDelay)
+			continue
+		}
+		tempDelay = 0
+		s.serveStream(transport, newStreamConnection(transport, conn), parser)
+	}
+}
+
+// serveStream 记录面向连接传输的连接并读取其上的消息，连接断开后移除
+func (s *Server) serveStream(transport string, conn Connection, parser *parser) {
+	key := connKey(transport, conn.RemoteAddr())
+	s.cmu.Lock()
+	s.conns[key] = conn
+	s.cmu.Unlock()
+	logrus.Traceln("new stream connection", key)
+	go s.readStream(key, conn, parser)
+}
+
+func (s *Server) readStream(key string, conn Connection, parser *parser) {
+	defer func() {
+		s.cmu.Lock()
+		if s.conns[key] == conn {
+			delete(s.conns, key)
+		}
+		s.cmu.Unlock()
+		conn.Close()
+		logrus.Traceln("stream connection closed", key)
+	}()
+	reader := bufio.NewReaderSize(conn, int(bufferSize))
+	for {
+		data, err := readStreamMessage(reader)
+		if err != nil {
+			if err != io.EOF {
+				logrus.Warnln("read stream message err", key, err)
+			}
+			return
+		}
+		parser.in <- newPacket(data, conn.RemoteAddr())
+	}
+}
+
+// getConn 根据传输协议获取发送消息的连接，tcp连接不存在时主动建立连接
+func (s *Server) getConn(transport string, raddr net.Addr) (Connection, error) {
+	transport = strings.ToUpper(transport)
+	switch transport {
+	case "TCP":
+		if raddr == nil {
+			return nil, fmt.Errorf("missing destination address")
+		}
+		key := connKey(transport, raddr)
+		s.cmu.RLock()
+		conn, ok := s.conns[key]
+		parser := s.tcpParser
+		s.cmu.RUnlock()
+		if ok {
+			return conn, nil
+		}
+		if parser == nil {
+			return nil, fmt.Errorf("tcp server not started")
+		}
+		baseConn, err := net.DialTimeout("tcp", raddr.String(), 5*time.Second)
+		if err != nil {
+			return nil, utils.NewError(err, "dial tcp", raddr.String())
+		}
+		conn = newStreamConnection(transport, baseConn)
+		s.serveStream(transport, conn, parser)
+		return conn, nil
+	case "TLS":
+		// tls 连接由设备发起并保持，不主动建立
+		if raddr == nil {
+			return nil, fmt.Errorf("missing destination address")
+		}
+		s.cmu.RLock()
+		conn, ok := s.conns[connKey(transport, raddr)]
+		s.cmu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("tls connection not found, raddr:%s", raddr.String())
+		}
+		return conn, nil
+	default:
+		s.cmu.RLock()
+		defer s.cmu.RUnlock()
+		return s.conn, nil
+	}
+}
+
+func connKey(transport string, raddr net.Addr) string {
+	return strings.ToUpper(transport) + "-" + raddr.String()
+}
+
+// sourceTransport 消息实际来源的传输协议，tcp和tls的远端地址都是tcp地址，通过连接记录区分
+func (s *Server) sourceTransport(msg Message) string {
+	if msg.Source().Network() == "udp" {
+		return "UDP"
+	}
+	s.cmu.RLock()
+	defer s.cmu.RUnlock()
+	if _, ok := s.conns[connKey("TLS", msg.Source())]; ok {
+		return "TLS"
+	}
+	return "TCP"
+}
+
 // RegistHandler RegistHandler
 func (s *Server) RegistHandler(method RequestMethod, handler RequestHandler) {
 	s.hmu.Lock()
@@ -113,14 +303,33 @@ func (s *Server) handlerListen(msgs chan Message) {
 	}
 }
 func (s *Server) handlerRequest(msg *Request) {
-	tx := s.mustTX(getTXKey(msg))
+	conn, err := s.getConn(s.sourceTransport(msg), msg.Source())
+	if err != nil {
+		logrus.Errorln("not found connection,", err, "message: \n", msg.String())
+		return
+	}
+	key := getTXKey(msg)
+	if tx := s.getTX(key); tx != nil && tx.receiveRequest(msg) {
+		// 重传的请求或非2xx响应的ACK由事务处理
+		logrus.Traceln("absorbed request from:", msg.Source(), ",method:", msg.Method(), "txKey:", key)
+		return
+	}
+	var tx *Transaction
+	if msg.IsAck() {
+		// 2xx的ACK不建立事务
+		tx = newTransaction(key, conn, false, msg)
+	} else {
+		tx = s.txs.newTX(key, conn, false, msg)
+	}
 	logrus.Traceln("receive request from:", msg.Source(), ",method:", msg.Method(), "txKey:", tx.key, "message: \n", msg.String())
 	s.hmu.RLock()
 	handler, ok := s.requestHandlers[msg.Method()]
 	s.hmu.RUnlock()
 	if !ok {
 		logrus.Errorln("not found handler func,requestMethod:", msg.Method(), msg.String())
-		go handlerMethodNotAllowed(msg, tx)
+		if !msg.IsAck() {
+			go handlerMethodNotAllowed(msg, tx)
+		}
 		return
 	}
 
@@ -137,25 +346,57 @@ func (s *Server) handlerResponse(msg *Response) {
 	}
 }
 
+// CancelTX 获取CANCEL请求对应的INVITE服务端事务 RFC 3261 9.2
+func (s *Server) CancelTX(cancel *Request) *Transaction {
+	tx := s.getTX(getTXKeyWithMethod(cancel, INVITE))
+	if tx == nil || tx.client {
+		return nil
+	}
+	return tx
+}
+
 // Request Request
 func (s *Server) Request(req *Request) (*Transaction, error) {
 	viaHop, ok := req.ViaHop()
 	if !ok {
 		return nil, fmt.Errorf("missing required 'Via' header")
 	}
-	viaHop.Host = s.host.String()
-	viaHop.Port = s.port
+	conn, err := s.getConn(viaHop.Transport, req.Destination())
+	if err != nil {
+		return nil, err
+	}
+	host, port := s.LocalAddr(viaHop.Transport)
+	viaHop.Host = host.String()
+	viaHop.Port = port
 	if viaHop.Params == nil {
-		viaHop.Params = NewParams().Add("branch", String{Str: GenerateBranch()})
+		viaHop.Params = NewParams()
+	}
+	if !viaHop.Params.Has("branch") {
+		viaHop.Params.Add("branch", String{Str: GenerateBranch()})
 	}
 	if !viaHop.Params.Has("rport") {
 		viaHop.Params.Add("rport", nil)
 	}
+	if _, ok := req.ContentLength(); !ok {
+		// tcp 传输必须携带 Content-Length
+		req.SetBody(req.Body(), true)
+	}
 
-	tx := s.mustTX(getTXKey(req))
+	tx := s.txs.newTX(getTXKey(req), conn, true, nil)
 	return tx, tx.Request(req)
 }
 
+// LocalAddr 本端监听地址，主动发起请求时用于Contact
+func (s *Server) LocalAddr(transport string) (net.IP, *Port) {
+	s.cmu.RLock()
+	defer s.cmu.RUnlock()
+	port := s.ports[strings.ToUpper(transport)]
+	if port == nil {
+		port = s.ports["UDP"]
+	}
+	return s.host, port
+}
+
 func handlerMethodNotAllowed(req *Request, tx *Transaction) {
 	resp := NewResponseFromRequest("", req, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed), []byte{})
 	tx.Respond(resp)
@@ -0,0 +1,30 @@
+package sip
+
+import (
+	"sync"
+	"testing"
+)
+
+// 三种传输协议的监听同时启动，监听时写入端口和本机地址，处理请求时读取
+func TestServerListenOnConcurrent(t *testing.T) {
+	srv := NewServer()
+	srv.host = testLocal.IP
+	wg := sync.WaitGroup{}
+	for i, transport := range []string{"UDP", "TCP", "TLS"} {
+		wg.Add(2)
+		go func(transport string, port int) {
+			defer wg.Done()
+			srv.listenOn(transport, port, "")
+		}(transport, 5060+i)
+		go func(transport string) {
+			defer wg.Done()
+			srv.LocalAddr(transport)
+		}(transport)
+	}
+	wg.Wait()
+	for i, transport := range []string{"UDP", "TCP", "TLS"} {
+		if _, port := srv.LocalAddr(transport); port == nil || int(*port) != 5060+i {
+			t.Errorf("%s port: got %v", transport, port)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package sip
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,6 +11,28 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// 事务定时器基础值 RFC 3261 17.1.1.1
+var (
+	// T1 RTT 估计值
+	T1 = 500 * time.Millisecond
+	// T2 非INVITE请求和INVITE响应的最大重传间隔
+	T2 = 4 * time.Second
+	// T4 消息在网络中的最大存活时间
+	T4 = 5 * time.Second
+	// TimerC INVITE 等待最终响应的最长时间 RFC 3261 16.6，服务端TU超过该时间未应答INVITE时回复408
+	TimerC = 3 * time.Minute
+)
+
+// SetTimers 设置事务定时器T1 T2，小于等于0时使用默认值
+func SetTimers(t1, t2 time.Duration) {
+	if t1 > 0 {
+		T1 = t1
+	}
+	if t2 > 0 {
+		T2 = t2
+	}
+}
+
 var activeTX *transacionts
 
 type transacionts struct {
@@ -16,8 +40,8 @@ type transacionts struct {
 	rwm *sync.RWMutex
 }
 
-func (txs *transacionts) newTX(key string, conn Connection) *Transaction {
-	tx := NewTransaction(key, conn)
+func (txs *transacionts) newTX(key string, conn Connection, client bool, origin *Request) *Transaction {
+	tx := newTransaction(key, conn, client, origin)
 	txs.rwm.Lock()
 	txs.txs[key] = tx
 	txs.rwm.Unlock()
@@ -36,54 +60,158 @@ func (txs *transacionts) getTX(key string) *Transaction {
 
 func (txs *transacionts) rmTX(tx *Transaction) {
 	txs.rwm.Lock()
-	delete(txs.txs, tx.key)
+	if txs.txs[tx.key] == tx {
+		delete(txs.txs, tx.key)
+	}
 	txs.rwm.Unlock()
 }
 
+// txState 事务状态 RFC 3261 17.1 17.2，Accepted 见 RFC 6026
+type txState int
+
+const (
+	txStateCalling txState = iota
+	txStateTrying
+	txStateProceeding
+	txStateCompleted
+	txStateConfirmed
+	txStateAccepted
+	txStateTerminated
+)
+
+var txStateNames = map[txState]string{
+	txStateCalling:    "Calling",
+	txStateTrying:     "Trying",
+	txStateProceeding: "Proceeding",
+	txStateCompleted:  "Completed",
+	txStateConfirmed:  "Confirmed",
+	txStateAccepted:   "Accepted",
+	txStateTerminated: "Terminated",
+}
+
+func (s txState) String() string {
+	return txStateNames[s]
+}
+
 // Transaction Transaction
 type Transaction struct {
-	conn   Connection
-	key    string
+	conn Connection
+	key  string
+	// true 客户端事务(本端发起请求) false 服务端事务(接收到请求)
+	client bool
+	// 可靠传输(tcp/tls)不需要重传
+	reliable bool
+	// 事务的初始请求
+	origin *Request
+	// 客户端INVITE事务发送的ACK
+	ack *Request
+	// 服务端事务最后发送的响应
+	lastResp *Response
+
+	state  txState
+	mu     *sync.Mutex
+	timers []*time.Timer
 	resp   chan *Response
-	active chan int
 }
 
-// NewTransaction NewTransaction
-func NewTransaction(key string, conn Connection) *Transaction {
-	logrus.Traceln("new tx", key, time.Now().Format("2006-01-02 15:04:05"))
-	tx := &Transaction{conn: conn, key: key, resp: make(chan *Response, 10), active: make(chan int, 1)}
-	go tx.watch()
+func newTransaction(key string, conn Connection, client bool, origin *Request) *Transaction {
+	logrus.Traceln("new tx", key, "client:", client, time.Now().Format("2006-01-02 15:04:05"))
+	tx := &Transaction{
+		conn:     conn,
+		key:      key,
+		client:   client,
+		reliable: conn.Network() != "UDP",
+		origin:   origin,
+		mu:       &sync.Mutex{},
+		resp:     make(chan *Response, 10),
+	}
+	if client {
+		return tx
+	}
+	// 服务端事务
+	if origin.IsInvite() {
+		tx.state = txStateProceeding
+		// TU 200ms 内没有响应时自动回复100 Trying RFC 3261 17.2.1
+		tx.after(200*time.Millisecond, func() {
+			if tx.state == txStateProceeding && tx.lastResp == nil {
+				tx.write(NewResponseFromRequest("", origin, http.StatusContinue, "Trying", nil))
+			}
+		})
+		tx.after(TimerC, func() { tx.respondTimeout(http.StatusRequestTimeout, "Request Timeout") })
+	} else {
+		tx.state = txStateTrying
+		if !origin.IsAck() {
+			// 客户端 Timer F 超时后不再等待响应
+			tx.after(64*T1, func() { tx.respondTimeout(http.StatusInternalServerError, "Server Internal Error") })
+		}
+	}
 	return tx
 }
 
+// respondTimeout TU 未发送最终响应时由事务回复，之后按最终响应的定时器结束事务
+func (tx *Transaction) respondTimeout(code int, reason string) {
+	if tx.state != txStateTrying && tx.state != txStateProceeding {
+		return
+	}
+	logrus.Warnln("tu response timeout, txkey:", tx.key, "state:", tx.state, "response:", code)
+	tx.respond(NewResponseFromRequest("", tx.origin, code, reason, nil))
+}
+
 // Key Key
 func (tx *Transaction) Key() string {
 	return tx.key
 }
 
-func (tx *Transaction) watch() {
-	for {
-		select {
-		case <-tx.active:
-			logrus.Traceln("active tx", tx.Key(), time.Now().Format("2006-01-02 15:04:05"))
-		case <-time.After(20 * time.Second):
-			tx.Close()
-			logrus.Traceln("watch closed tx", tx.key, time.Now().Format("2006-01-02 15:04:05"))
+// Origin 事务的初始请求
+func (tx *Transaction) Origin() *Request {
+	return tx.origin
+}
+
+// Pending 服务端事务是否还可以发送最终响应，INVITE被CANCEL或事务超时后返回false
+func (tx *Transaction) Pending() bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return !tx.client && (tx.state == txStateTrying || tx.state == txStateProceeding)
+}
+
+// after 启动定时器，回调在事务锁内执行，事务结束后不再执行
+func (tx *Transaction) after(d time.Duration, f func()) {
+	tx.timers = append(tx.timers, time.AfterFunc(d, func() {
+		tx.mu.Lock()
+		defer tx.mu.Unlock()
+		if tx.state == txStateTerminated {
 			return
 		}
+		f()
+	}))
+}
+
+// terminateAfter 可靠传输时立即结束事务，否则等待d后结束
+func (tx *Transaction) terminateAfter(d time.Duration) {
+	if tx.reliable || d <= 0 {
+		tx.terminate()
+		return
 	}
+	tx.after(d, tx.terminate)
 }
 
-// GetResponse GetResponse
+func (tx *Transaction) write(msg Message) error {
+	_, err := tx.conn.WriteTo([]byte(msg.String()), msg.Destination())
+	if err != nil {
+		logrus.Warnln("tx write message fail, txkey:", tx.key, "err:", err)
+	}
+	return err
+}
+
+// GetResponse 获取最终响应，事务超时返回nil
 func (tx *Transaction) GetResponse() *Response {
 	for {
 		res := <-tx.resp
 		if res == nil {
 			return res
 		}
-		tx.active <- 2
 		logrus.Traceln("response tx", tx.key, time.Now().Format("2006-01-02 15:04:05"))
-		if res.StatusCode() == http.StatusContinue || res.statusCode == http.StatusSwitchingProtocols {
+		if res.StatusCode() < http.StatusOK {
 			// Trying and Dialog Establishement 等待下一个返回
 			continue
 		}
@@ -93,44 +221,344 @@ func (tx *Transaction) GetResponse() *Response {
 
 // Close Close
 func (tx *Transaction) Close() {
-	logrus.Traceln("closed tx", tx.key, time.Now().Format("2006-01-02 15:04:05"))
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.terminate()
+}
+
+// terminate 需在事务锁内调用
+func (tx *Transaction) terminate() {
+	if tx.state == txStateTerminated {
+		return
+	}
+	logrus.Traceln("closed tx", tx.key, "state:", tx.state, time.Now().Format("2006-01-02 15:04:05"))
+	tx.state = txStateTerminated
+	for _, t := range tx.timers {
+		t.Stop()
+	}
+	tx.timers = nil
 	activeTX.rmTX(tx)
 	close(tx.resp)
-	close(tx.active)
 }
 
-// Response Response
-func (tx *Transaction) receiveResponse(msg *Response) {
-	defer func() {
-		if r := recover(); r != nil {
-			logrus.Errorln("send to closed channel, txkey:", tx.key, "message: \n", msg.String())
+// passUp 将响应交给TU，需在事务锁内调用
+func (tx *Transaction) passUp(msg *Response) {
+	select {
+	case tx.resp <- msg:
+	default:
+		logrus.Warnln("tx response channel full, drop response, txkey:", tx.key, "message: \n", msg.String())
+	}
+}
+
+// Request 客户端事务发送请求，首次调用发送事务的初始请求并启动重传和超时定时器，之后可用来发送ACK
+func (tx *Transaction) Request(req *Request) error {
+	logrus.Traceln("send request,to:", req.dest.String(), "txkey:", tx.key, "message: \n", req.String())
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.state == txStateTerminated && !req.IsAck() {
+		return fmt.Errorf("transaction terminated, txkey:%s", tx.key)
+	}
+	if req.IsAck() {
+		if tx.origin != nil {
+			// ACK与INVITE由同一UA发出，使用相同的sent-by
+			via, ok := req.ViaHop()
+			ovia, ook := tx.origin.ViaHop()
+			if ok && ook {
+				via.Host, via.Port = ovia.Host, ovia.Port
+			}
+		}
+		if _, ok := req.ContentLength(); !ok {
+			req.SetBody(req.Body(), true)
+		}
+		tx.ack = req
+		return tx.write(req)
+	}
+	if tx.origin != nil && tx.origin != req {
+		return tx.write(req)
+	}
+	tx.origin = req
+	if req.IsInvite() {
+		tx.state = txStateCalling
+	} else {
+		tx.state = txStateTrying
+	}
+	if err := tx.write(req); err != nil {
+		tx.terminate()
+		return err
+	}
+	if !tx.reliable {
+		// Timer A/E
+		tx.after(T1, func() { tx.retransmit(T1) })
+	}
+	// Timer B/F，INVITE收到临时响应后由被叫决定何时应答，只在Calling状态超时
+	tx.after(64*T1, func() {
+		timeout := tx.state == txStateTrying || tx.state == txStateProceeding
+		if tx.origin.IsInvite() {
+			timeout = tx.state == txStateCalling
+		}
+		if timeout {
+			logrus.Traceln("tx timeout", tx.key, "state:", tx.state)
+			tx.terminate()
+		}
+	})
+	if req.IsInvite() {
+		// Timer C，收到临时响应后仍然限制等待最终响应的时间
+		tx.after(TimerC, func() {
+			if tx.state == txStateProceeding {
+				logrus.Warnln("invite final response timeout", tx.key)
+				tx.terminate()
+			}
+		})
+	}
+	return nil
+}
+
+// retransmit Timer A(INVITE) / Timer E(非INVITE) 重传请求
+func (tx *Transaction) retransmit(interval time.Duration) {
+	if tx.origin.IsInvite() {
+		if tx.state != txStateCalling {
+			return
 		}
-	}()
+		interval *= 2
+	} else {
+		switch tx.state {
+		case txStateTrying:
+			interval *= 2
+			if interval > T2 {
+				interval = T2
+			}
+		case txStateProceeding:
+			interval = T2
+		default:
+			return
+		}
+	}
+	logrus.Traceln("retransmit request, txkey:", tx.key, "state:", tx.state)
+	tx.write(tx.origin)
+	tx.after(interval, func() { tx.retransmit(interval) })
+}
+
+// receiveResponse 客户端事务接收响应
+func (tx *Transaction) receiveResponse(msg *Response) {
 	logrus.Traceln("receiveResponse tx", tx.Key(), time.Now().Format("2006-01-02 15:04:05"))
-	tx.resp <- msg
-	tx.active <- 1
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if !tx.client || tx.origin == nil {
+		logrus.Warnln("receive response for server tx, txkey:", tx.key, "message: \n", msg.String())
+		return
+	}
+	ocseq, _ := tx.origin.CSeq()
+	if cseq, ok := msg.CSeq(); !ok || ocseq == nil || cseq.SeqNo != ocseq.SeqNo || cseq.MethodName != tx.origin.Method() {
+		logrus.Infoln("response not match tx, txkey:", tx.key, "message: \n", msg.String())
+		return
+	}
+	code := msg.StatusCode()
+	if tx.origin.IsInvite() {
+		switch tx.state {
+		case txStateCalling, txStateProceeding:
+			switch {
+			case code < http.StatusOK:
+				tx.state = txStateProceeding
+				tx.passUp(msg)
+			case code < http.StatusMultipleChoices:
+				// 2xx 的ACK由TU发送，Timer M 内吸收2xx重传
+				tx.state = txStateAccepted
+				tx.passUp(msg)
+				tx.after(64*T1, tx.terminate)
+			default:
+				tx.state = txStateCompleted
+				tx.ack = newAckRequest(tx.origin, msg)
+				tx.write(tx.ack)
+				tx.passUp(msg)
+				// Timer D
+				tx.terminateAfter(32 * time.Second)
+			}
+		case txStateCompleted:
+			// 最终响应重传，重发ACK
+			if code >= http.StatusMultipleChoices && tx.ack != nil {
+				tx.write(tx.ack)
+			}
+		case txStateAccepted:
+			if code >= http.StatusOK && code < http.StatusMultipleChoices && tx.ack != nil {
+				tx.write(tx.ack)
+			}
+		}
+		return
+	}
+	switch tx.state {
+	case txStateTrying, txStateProceeding:
+		if code < http.StatusOK {
+			tx.state = txStateProceeding
+			tx.passUp(msg)
+			return
+		}
+		tx.state = txStateCompleted
+		tx.passUp(msg)
+		// Timer K
+		tx.terminateAfter(T4)
+	}
 }
 
-// Respond Respond
+// receiveRequest 服务端事务接收到重传的请求或ACK，返回true表示请求已被事务吸收，不需要再交给TU处理
+func (tx *Transaction) receiveRequest(req *Request) bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.client || tx.state == txStateTerminated || !tx.matchRequest(req) {
+		return false
+	}
+	if req.IsAck() {
+		if tx.state == txStateCompleted {
+			// 非2xx最终响应的ACK，Timer I
+			tx.state = txStateConfirmed
+			tx.terminateAfter(T4)
+			return true
+		}
+		// 2xx的ACK交给TU处理
+		return tx.state == txStateConfirmed
+	}
+	logrus.Traceln("receive retransmission request, txkey:", tx.key, "state:", tx.state)
+	switch tx.state {
+	case txStateProceeding, txStateCompleted, txStateAccepted:
+		if tx.lastResp != nil {
+			tx.write(tx.lastResp)
+		}
+	}
+	return true
+}
+
+// matchRequest 请求是否为当前事务初始请求的重传或对应的ACK
+func (tx *Transaction) matchRequest(req *Request) bool {
+	ocseq, ok := tx.origin.CSeq()
+	if !ok {
+		return false
+	}
+	cseq, ok := req.CSeq()
+	if !ok || cseq.SeqNo != ocseq.SeqNo {
+		return false
+	}
+	if req.IsAck() {
+		return tx.origin.IsInvite()
+	}
+	return req.Method() == tx.origin.Method()
+}
+
+// Respond 服务端事务发送响应
 func (tx *Transaction) Respond(res *Response) error {
 	logrus.Traceln("send response,to:", res.dest.String(), "txkey:", tx.key, "message: \n", res.String())
-	_, err := tx.conn.WriteTo([]byte(res.String()), res.dest)
-	return err
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.client {
+		return tx.write(res)
+	}
+	return tx.respond(res)
 }
 
-// Request Request
-func (tx *Transaction) Request(req *Request) error {
-	logrus.Traceln("send request,to:", req.dest.String(), "txkey:", tx.key, "message: \n", req.String())
-	_, err := tx.conn.WriteTo([]byte(req.String()), req.dest)
-	return err
+// respond 需在事务锁内调用
+func (tx *Transaction) respond(res *Response) error {
+	switch tx.state {
+	case txStateTrying, txStateProceeding:
+	case txStateAccepted:
+		// 2xx 可以由TU重传
+		if res.StatusCode() >= http.StatusOK && res.StatusCode() < http.StatusMultipleChoices {
+			return tx.write(res)
+		}
+		fallthrough
+	default:
+		logrus.Warnln("transaction already responded, txkey:", tx.key, "state:", tx.state)
+		return fmt.Errorf("transaction already responded, state:%s", tx.state)
+	}
+	tx.lastResp = res
+	if err := tx.write(res); err != nil {
+		return err
+	}
+	code := res.StatusCode()
+	if code < http.StatusOK {
+		tx.state = txStateProceeding
+		return nil
+	}
+	if !tx.origin.IsInvite() {
+		tx.state = txStateCompleted
+		// Timer J
+		tx.terminateAfter(64 * T1)
+		return nil
+	}
+	if code < http.StatusMultipleChoices {
+		// Timer L
+		tx.state = txStateAccepted
+		tx.after(64*T1, tx.terminate)
+		return nil
+	}
+	tx.state = txStateCompleted
+	if !tx.reliable {
+		// Timer G
+		tx.after(T1, func() { tx.retransmitResponse(T1) })
+	}
+	// Timer H 等待ACK超时
+	tx.after(64*T1, func() {
+		if tx.state == txStateCompleted {
+			logrus.Warnln("wait ack timeout, txkey:", tx.key)
+			tx.terminate()
+		}
+	})
+	return nil
+}
+
+// retransmitResponse Timer G 重传INVITE的非2xx最终响应
+func (tx *Transaction) retransmitResponse(interval time.Duration) {
+	if tx.state != txStateCompleted {
+		return
+	}
+	tx.write(tx.lastResp)
+	interval *= 2
+	if interval > T2 {
+		interval = T2
+	}
+	tx.after(interval, func() { tx.retransmitResponse(interval) })
 }
 
+// getTXKey 事务标识 RFC 3261 17.1.3 17.2.3
+// 顶层Via的branch(RFC 3261 魔术字开头) + sent-by + CSeq方法，ACK与INVITE属于同一事务
+// 不支持branch的RFC 2543设备，使用From tag、Call-ID、CSeq序号和顶层Via组合匹配
 func getTXKey(msg Message) (key string) {
-	callid, ok := msg.CallID()
-	if ok {
-		key = callid.String()
-	} else {
-		key = utils.RandString(10)
+	var method RequestMethod
+	if cseq, ok := msg.CSeq(); ok {
+		method = cseq.MethodName
+	}
+	if req, ok := msg.(*Request); ok {
+		method = req.Method()
+	}
+	if method == ACK {
+		method = INVITE
+	}
+	return getTXKeyWithMethod(msg, method)
+}
+
+// getTXKeyWithMethod 指定方法的事务标识，CANCEL 用来查找对应的INVITE事务
+func getTXKeyWithMethod(msg Message, method RequestMethod) string {
+	via, ok := msg.ViaHop()
+	if !ok {
+		return utils.RandString(10)
+	}
+	if branch, ok := via.Params.Get("branch"); ok && strings.HasPrefix(branch.String(), RFC3261BranchMagicCookie) {
+		return strings.Join([]string{branch.String(), via.SentBy(), string(method)}, "|")
+	}
+	return rfc2543TXKey(msg, via, method)
+}
+
+// rfc2543TXKey RFC 2543 事务匹配 RFC 3261 17.2.3
+// To tag 在INVITE和ACK中不同，响应中没有Request-URI，都不参与匹配，保证请求和响应得到相同的标识
+func rfc2543TXKey(msg Message, via *ViaHop, method RequestMethod) string {
+	keys := []string{via.Transport, via.SentBy(), string(method)}
+	if callid, ok := msg.CallID(); ok {
+		keys = append(keys, string(*callid))
+	}
+	if cseq, ok := msg.CSeq(); ok {
+		keys = append(keys, fmt.Sprintf("%d", cseq.SeqNo))
+	}
+	if from, ok := msg.From(); ok {
+		if tag, ok := from.Params.Get("tag"); ok {
+			keys = append(keys, tag.String())
+		}
 	}
-	return
+	return strings.Join(keys, "|")
 }
@@ -0,0 +1,589 @@
+package sip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn 记录事务写出的消息
+type fakeConn struct {
+	net.Conn
+	network string
+	mu      sync.Mutex
+	msgs    []string
+	// onWrite 模拟对端收到消息
+	onWrite func(msg string)
+}
+
+func (c *fakeConn) Network() string {
+	return c.network
+}
+
+func (c *fakeConn) ReadFrom(buf []byte) (int, net.Addr, error) {
+	return 0, nil, fmt.Errorf("not implemented")
+}
+
+func (c *fakeConn) WriteTo(buf []byte, raddr net.Addr) (int, error) {
+	c.mu.Lock()
+	c.msgs = append(c.msgs, string(buf))
+	c.mu.Unlock()
+	if c.onWrite != nil {
+		c.onWrite(string(buf))
+	}
+	return len(buf), nil
+}
+
+// count 以prefix开头的消息数量
+func (c *fakeConn) count(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, msg := range c.msgs {
+		if strings.HasPrefix(msg, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+var (
+	testLocal  = &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5060}
+	testRemote = &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5070}
+)
+
+func newTestServer(t *testing.T) (*Server, *fakeConn) {
+	t1, t2, t4, timerC := T1, T2, T4, TimerC
+	SetTimers(10*time.Millisecond, 40*time.Millisecond)
+	T4 = 20 * time.Millisecond
+	TimerC = 100 * T1
+	conn := &fakeConn{network: "UDP"}
+	srv := NewServer()
+	t.Cleanup(func() {
+		// 结束未超时的事务，避免定时器读取恢复后的定时器值
+		srv.txs.rwm.RLock()
+		txs := make([]*Transaction, 0, len(srv.txs.txs))
+		for _, tx := range srv.txs.txs {
+			txs = append(txs, tx)
+		}
+		srv.txs.rwm.RUnlock()
+		for _, tx := range txs {
+			tx.Close()
+		}
+		T1, T2, T4, TimerC = t1, t2, t4, timerC
+	})
+	srv.conn = conn
+	srv.host = testLocal.IP
+	srv.ports["UDP"] = NewPort(testLocal.Port)
+	return srv, conn
+}
+
+var (
+	testParser     *parser
+	testParserOnce sync.Once
+	testParserMu   sync.Mutex
+)
+
+// parseMessage 按收到的报文解析
+func parseMessage(t *testing.T, raw string, src net.Addr) Message {
+	t.Helper()
+	testParserOnce.Do(func() { testParser = newParser() })
+	testParserMu.Lock()
+	defer testParserMu.Unlock()
+	testParser.in <- newPacket([]byte(raw), src)
+	select {
+	case msg := <-testParser.out:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("parse message timeout")
+	}
+	return nil
+}
+
+func rawRequest(method RequestMethod, branch, callID string, seq int) string {
+	via := "SIP/2.0/UDP 127.0.0.1:5070;rport"
+	if branch != "" {
+		via += ";branch=" + branch
+	}
+	return fmt.Sprintf("%s sip:34020000002000000001@127.0.0.1:5060 SIP/2.0\r\n"+
+		"Via: %s\r\n"+
+		"From: <sip:34020000001320000001@3402000000>;tag=from1\r\n"+
+		"To: <sip:34020000002000000001@3402000000>\r\n"+
+		"Call-ID: %s\r\n"+
+		"CSeq: %d %s\r\n"+
+		"Max-Forwards: 70\r\n"+
+		"Content-Length: 0\r\n\r\n", method, via, callID, seq, method)
+}
+
+// newClientRequest 本端发起的请求
+func newClientRequest(t *testing.T, method RequestMethod, callID string) *Request {
+	req := parseMessage(t, rawRequest(method, "", callID, 1), testLocal).(*Request)
+	// branch 由Server.Request生成
+	via, _ := req.ViaHop()
+	via.Params = NewParams()
+	req.SetDestination(testRemote)
+	return req
+}
+
+// newServerRequest 设备发来的请求
+func newServerRequest(t *testing.T, method RequestMethod, branch, callID string, seq int) *Request {
+	req := parseMessage(t, rawRequest(method, branch, callID, seq), testRemote).(*Request)
+	req.SetDestination(testLocal)
+	return req
+}
+
+func setToTag(res *Response, tag string) {
+	if to, ok := res.To(); ok {
+		to.Params.Add("tag", String{Str: tag})
+	}
+}
+
+func txStateOf(tx *Transaction) txState {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.state
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, d time.Duration, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(d)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// serveRequests 注册处理函数，将收到的请求的事务交给测试
+func serveRequests(srv *Server, method RequestMethod) chan *Transaction {
+	txs := make(chan *Transaction, 10)
+	srv.RegistHandler(method, func(req *Request, tx *Transaction) {
+		txs <- tx
+	})
+	return txs
+}
+
+func recvTX(t *testing.T, txs chan *Transaction) *Transaction {
+	t.Helper()
+	select {
+	case tx := <-txs:
+		return tx
+	case <-time.After(time.Second):
+		t.Fatal("handler not called")
+	}
+	return nil
+}
+
+func TestClientNonInviteTimerEF(t *testing.T) {
+	srv, conn := newTestServer(t)
+	tx, err := srv.Request(newClientRequest(t, MESSAGE, "nonInviteTimeout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if res := tx.GetResponse(); res != nil {
+		t.Fatalf("want timeout, got %d", res.StatusCode())
+	}
+	if d := time.Since(start); d < 60*T1 {
+		t.Errorf("Timer F fired too early: %s", d)
+	}
+	// Timer E: 10 30 70 110 ... 间隔最大T2
+	if n := conn.count("MESSAGE"); n < 10 {
+		t.Errorf("want request retransmitted by Timer E, sent %d", n)
+	}
+	if srv.getTX(tx.Key()) != nil {
+		t.Error("tx not removed after Timer F")
+	}
+}
+
+func TestClientNonInviteTimerK(t *testing.T) {
+	srv, conn := newTestServer(t)
+	tx, err := srv.Request(newClientRequest(t, MESSAGE, "nonInviteTimerK"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.handlerResponse(NewResponseFromRequest("", tx.Origin(), http.StatusContinue, "Trying", nil))
+	if s := txStateOf(tx); s != txStateProceeding {
+		t.Fatalf("want Proceeding, got %s", s)
+	}
+	srv.handlerResponse(NewResponseFromRequest("", tx.Origin(), http.StatusOK, "OK", nil))
+	res := tx.GetResponse()
+	if res == nil || res.StatusCode() != http.StatusOK {
+		t.Fatalf("want 200, got %v", res)
+	}
+	sent := conn.count("MESSAGE")
+	waitFor(t, 10*T4, "tx not terminated by Timer K", func() bool { return srv.getTX(tx.Key()) == nil })
+	time.Sleep(4 * T2)
+	if n := conn.count("MESSAGE"); n != sent {
+		t.Errorf("retransmitted after final response, %d -> %d", sent, n)
+	}
+}
+
+func TestClientInviteTimerAB(t *testing.T) {
+	srv, conn := newTestServer(t)
+	tx, err := srv.Request(newClientRequest(t, INVITE, "inviteTimeout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := tx.GetResponse(); res != nil {
+		t.Fatalf("want timeout, got %d", res.StatusCode())
+	}
+	// Timer A 间隔翻倍: 10 30 70 150 310 630
+	if n := conn.count("INVITE"); n < 5 || n > 8 {
+		t.Errorf("want INVITE sent 5-8 times by Timer A, sent %d", n)
+	}
+	if srv.getTX(tx.Key()) != nil {
+		t.Error("tx not removed after Timer B")
+	}
+}
+
+func TestClientInviteProceeding(t *testing.T) {
+	srv, conn := newTestServer(t)
+	tx, err := srv.Request(newClientRequest(t, INVITE, "inviteProceeding"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, time.Second, "INVITE not retransmitted", func() bool { return conn.count("INVITE") >= 3 })
+	srv.handlerResponse(NewResponseFromRequest("", tx.Origin(), 180, "Ringing", nil))
+	sent := conn.count("INVITE")
+	// 收到临时响应后停止重传，Timer B 不再结束事务
+	time.Sleep(80 * T1)
+	if n := conn.count("INVITE"); n > sent+1 {
+		t.Errorf("retransmitted in Proceeding, %d -> %d", sent, n)
+	}
+	if s := txStateOf(tx); s != txStateProceeding {
+		t.Fatalf("want Proceeding after 64*T1, got %s", s)
+	}
+
+	final := NewResponseFromRequest("", tx.Origin(), 486, "Busy Here", nil)
+	setToTag(final, "to1")
+	srv.handlerResponse(final)
+	res := tx.GetResponse()
+	if res == nil || res.StatusCode() != 486 {
+		t.Fatalf("want 486, got %v", res)
+	}
+	if s := txStateOf(tx); s != txStateCompleted {
+		t.Fatalf("want Completed, got %s", s)
+	}
+	if n := conn.count("ACK"); n != 1 {
+		t.Fatalf("want ACK for non-2xx, sent %d", n)
+	}
+	// 最终响应重传，重发ACK
+	srv.handlerResponse(final)
+	if n := conn.count("ACK"); n != 2 {
+		t.Errorf("want ACK resent for retransmitted response, sent %d", n)
+	}
+}
+
+func TestClientInviteReliable(t *testing.T) {
+	srv, _ := newTestServer(t)
+	conn := &fakeConn{network: "TCP"}
+	srv.conns[connKey("TCP", testRemote)] = conn
+	req := newClientRequest(t, INVITE, "inviteReliable")
+	via, _ := req.ViaHop()
+	via.Transport = "TCP"
+	tx, err := srv.Request(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * T1)
+	if n := conn.count("INVITE"); n != 1 {
+		t.Errorf("retransmitted over reliable transport, sent %d", n)
+	}
+	srv.handlerResponse(NewResponseFromRequest("", tx.Origin(), http.StatusNotFound, "Not Found", nil))
+	if n := conn.count("ACK"); n != 1 {
+		t.Errorf("want ACK for non-2xx, sent %d", n)
+	}
+	// 可靠传输Timer D为0
+	if srv.getTX(tx.Key()) != nil {
+		t.Error("tx not terminated over reliable transport")
+	}
+}
+
+func TestServerNonInviteTimerJ(t *testing.T) {
+	srv, conn := newTestServer(t)
+	txs := serveRequests(srv, MESSAGE)
+	req := newServerRequest(t, MESSAGE, "z9hG4bK-message", "serverMessage", 1)
+	srv.handlerRequest(req)
+	tx := recvTX(t, txs)
+	if s := txStateOf(tx); s != txStateTrying || !tx.Pending() {
+		t.Fatalf("want pending Trying, got %s", s)
+	}
+	// 响应前的重传被吸收，没有响应可以重发
+	srv.handlerRequest(newServerRequest(t, MESSAGE, "z9hG4bK-message", "serverMessage", 1))
+	if n := conn.count("SIP/2.0"); n != 0 {
+		t.Errorf("responded before TU, sent %d", n)
+	}
+	if err := tx.Respond(NewResponseFromRequest("", req, http.StatusOK, "OK", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if s := txStateOf(tx); s != txStateCompleted || tx.Pending() {
+		t.Fatalf("want Completed, got %s", s)
+	}
+	// 请求重传被吸收，重发最后的响应
+	srv.handlerRequest(newServerRequest(t, MESSAGE, "z9hG4bK-message", "serverMessage", 1))
+	if n := conn.count("SIP/2.0 200"); n != 2 {
+		t.Errorf("want last response resent, sent %d", n)
+	}
+	select {
+	case <-txs:
+		t.Error("retransmission passed to TU")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := tx.Respond(NewResponseFromRequest("", req, http.StatusOK, "OK", nil)); err == nil {
+		t.Error("want error when responding twice")
+	}
+	waitFor(t, 100*T1, "tx not terminated by Timer J", func() bool { return srv.getTX(tx.Key()) == nil })
+}
+
+func TestServerInviteTimerGI(t *testing.T) {
+	srv, conn := newTestServer(t)
+	txs := serveRequests(srv, INVITE)
+	req := newServerRequest(t, INVITE, "z9hG4bK-invite", "serverInvite", 1)
+	srv.handlerRequest(req)
+	tx := recvTX(t, txs)
+	if s := txStateOf(tx); s != txStateProceeding {
+		t.Fatalf("want Proceeding, got %s", s)
+	}
+	// TU 200ms 内没有响应，自动回复100
+	waitFor(t, time.Second, "100 Trying not sent", func() bool { return conn.count("SIP/2.0 100") == 1 })
+
+	res := NewResponseFromRequest("", req, 486, "Busy Here", nil)
+	setToTag(res, "to1")
+	if err := tx.Respond(res); err != nil {
+		t.Fatal(err)
+	}
+	// Timer G 重传非2xx最终响应
+	waitFor(t, time.Second, "response not retransmitted by Timer G", func() bool { return conn.count("SIP/2.0 486") >= 3 })
+
+	srv.handlerRequest(newServerRequest(t, ACK, "z9hG4bK-invite", "serverInvite", 1))
+	if s := txStateOf(tx); s != txStateConfirmed {
+		t.Fatalf("want Confirmed after ACK, got %s", s)
+	}
+	select {
+	case <-txs:
+		t.Error("ACK for non-2xx passed to TU")
+	case <-time.After(20 * time.Millisecond):
+	}
+	sent := conn.count("SIP/2.0 486")
+	waitFor(t, 10*T4, "tx not terminated by Timer I", func() bool { return srv.getTX(tx.Key()) == nil })
+	time.Sleep(4 * T2)
+	if n := conn.count("SIP/2.0 486"); n > sent+1 {
+		t.Errorf("retransmitted after ACK, %d -> %d", sent, n)
+	}
+}
+
+func TestServerInviteTimerH(t *testing.T) {
+	srv, conn := newTestServer(t)
+	txs := serveRequests(srv, INVITE)
+	req := newServerRequest(t, INVITE, "z9hG4bK-noack", "serverNoAck", 1)
+	srv.handlerRequest(req)
+	tx := recvTX(t, txs)
+	if err := tx.Respond(NewResponseFromRequest("", req, http.StatusNotFound, "Not Found", nil)); err != nil {
+		t.Fatal(err)
+	}
+	// INVITE 重传被吸收，重发最终响应
+	srv.handlerRequest(newServerRequest(t, INVITE, "z9hG4bK-noack", "serverNoAck", 1))
+	start := time.Now()
+	waitFor(t, 100*T1, "tx not terminated by Timer H", func() bool { return srv.getTX(tx.Key()) == nil })
+	if d := time.Since(start); d < 50*T1 {
+		t.Errorf("Timer H fired too early: %s", d)
+	}
+	if n := conn.count("SIP/2.0 404"); n < 5 {
+		t.Errorf("want response retransmitted until Timer H, sent %d", n)
+	}
+}
+
+func TestConcurrentTransactionsSameCallID(t *testing.T) {
+	srv, _ := newTestServer(t)
+	const n = 10
+	txs := make([]*Transaction, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		req := newClientRequest(t, MESSAGE, "sameCallID")
+		cseq, _ := req.CSeq()
+		cseq.SeqNo = uint32(i + 1)
+		wg.Add(1)
+		go func(i int, req *Request) {
+			defer wg.Done()
+			tx, err := srv.Request(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			txs[i] = tx
+		}(i, req)
+	}
+	wg.Wait()
+	keys := map[string]bool{}
+	for _, tx := range txs {
+		if tx == nil {
+			t.FailNow()
+		}
+		keys[tx.Key()] = true
+	}
+	if len(keys) != n {
+		t.Fatalf("want %d transactions, got %d", n, len(keys))
+	}
+	// 倒序并发送达响应，每个事务只收到自己的响应
+	for i := n - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(tx *Transaction, code int) {
+			defer wg.Done()
+			srv.handlerResponse(NewResponseFromRequest("", tx.Origin(), code, "OK", nil))
+		}(txs[i], http.StatusOK+i)
+	}
+	wg.Wait()
+	for i, tx := range txs {
+		res := tx.GetResponse()
+		if res == nil || res.StatusCode() != http.StatusOK+i {
+			t.Errorf("tx %d got wrong response %v", i, res)
+			continue
+		}
+		if cseq, _ := res.CSeq(); cseq.SeqNo != uint32(i+1) {
+			t.Errorf("tx %d got response for cseq %d", i, cseq.SeqNo)
+		}
+	}
+
+	// 服务端同一会话中的INVITE和INFO是不同的事务
+	invites := serveRequests(srv, INVITE)
+	infos := serveRequests(srv, INFO)
+	go srv.handlerRequest(newServerRequest(t, INVITE, "z9hG4bK-same-1", "sameCallID", 1))
+	go srv.handlerRequest(newServerRequest(t, INFO, "z9hG4bK-same-2", "sameCallID", 2))
+	invite, info := recvTX(t, invites), recvTX(t, infos)
+	if invite == info || invite.Key() == info.Key() {
+		t.Fatal("requests in the same call share a transaction")
+	}
+}
+
+func TestCancelTX(t *testing.T) {
+	srv, _ := newTestServer(t)
+	txs := serveRequests(srv, INVITE)
+	srv.handlerRequest(newServerRequest(t, INVITE, "z9hG4bK-cancel", "cancel", 1))
+	invite := recvTX(t, txs)
+
+	cancel := newServerRequest(t, CANCEL, "z9hG4bK-cancel", "cancel", 1)
+	if tx := srv.CancelTX(cancel); tx != invite {
+		t.Fatalf("CANCEL not matched to INVITE tx, got %v", tx)
+	}
+	if getTXKey(cancel) == invite.Key() {
+		t.Error("CANCEL shares the INVITE transaction key")
+	}
+	if tx := srv.CancelTX(newServerRequest(t, CANCEL, "z9hG4bK-other", "cancel", 1)); tx != nil {
+		t.Errorf("CANCEL with another branch matched tx %s", tx.Key())
+	}
+
+	// 本端发起的INVITE不能被设备CANCEL
+	client, err := srv.Request(newClientRequest(t, INVITE, "cancelClient"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	via, _ := client.Origin().ViaHop()
+	branch, _ := via.Params.Get("branch")
+	cancel = newServerRequest(t, CANCEL, branch.String(), "cancelClient", 1)
+	cvia, _ := cancel.ViaHop()
+	cvia.Host, cvia.Port = via.Host, via.Port
+	if tx := srv.CancelTX(cancel); tx != nil {
+		t.Errorf("CANCEL matched client tx %s", tx.Key())
+	}
+}
+
+func TestRFC2543Transactions(t *testing.T) {
+	srv, conn := newTestServer(t)
+	txs := serveRequests(srv, INVITE)
+	req := newServerRequest(t, INVITE, "", "rfc2543", 1)
+	srv.handlerRequest(req)
+	tx := recvTX(t, txs)
+
+	res := NewResponseFromRequest("", req, 486, "Busy Here", nil)
+	setToTag(res, "to1")
+	if key := getTXKey(res); key != tx.Key() {
+		t.Fatalf("response key %s not match request key %s", key, tx.Key())
+	}
+	if err := tx.Respond(res); err != nil {
+		t.Fatal(err)
+	}
+	// 没有branch的重传同样被吸收
+	srv.handlerRequest(newServerRequest(t, INVITE, "", "rfc2543", 1))
+	if n := conn.count("SIP/2.0 486"); n < 2 {
+		t.Errorf("want last response resent, sent %d", n)
+	}
+	if tx := srv.CancelTX(newServerRequest(t, CANCEL, "", "rfc2543", 1)); tx == nil {
+		t.Error("CANCEL without branch not matched")
+	}
+	// 同一会话的新请求是新的事务
+	srv.handlerRequest(newServerRequest(t, INVITE, "", "rfc2543", 2))
+	if next := recvTX(t, txs); next.Key() == tx.Key() {
+		t.Error("new CSeq matched old transaction")
+	}
+
+	srv.handlerRequest(newServerRequest(t, ACK, "", "rfc2543", 1))
+	if s := txStateOf(tx); s != txStateConfirmed {
+		t.Fatalf("want Confirmed after ACK, got %s", s)
+	}
+	select {
+	case <-txs:
+		t.Error("ACK for non-2xx passed to TU")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestClientInviteTimerC(t *testing.T) {
+	srv, _ := newTestServer(t)
+	tx, err := srv.Request(newClientRequest(t, INVITE, "inviteTimerC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	srv.handlerResponse(NewResponseFromRequest("", tx.Origin(), 180, "Ringing", nil))
+	// 收到临时响应后没有最终响应，Timer C 结束事务
+	if res := tx.GetResponse(); res != nil {
+		t.Fatalf("want timeout, got %d", res.StatusCode())
+	}
+	if d := time.Since(start); d < TimerC-T1 {
+		t.Errorf("Timer C fired too early: %s", d)
+	}
+	if srv.getTX(tx.Key()) != nil {
+		t.Error("tx not removed after Timer C")
+	}
+}
+
+func TestServerTUTimeout(t *testing.T) {
+	srv, conn := newTestServer(t)
+	messages := serveRequests(srv, MESSAGE)
+	invites := serveRequests(srv, INVITE)
+	acks := serveRequests(srv, ACK)
+
+	// 非INVITE未应答时回复500
+	srv.handlerRequest(newServerRequest(t, MESSAGE, "z9hG4bK-tu-message", "tuMessage", 1))
+	message := recvTX(t, messages)
+	srv.handlerRequest(newServerRequest(t, INVITE, "z9hG4bK-tu-invite", "tuInvite", 1))
+	invite := recvTX(t, invites)
+	// 2xx的ACK没有响应
+	srv.handlerRequest(newServerRequest(t, ACK, "z9hG4bK-tu-ack", "tuAck", 1))
+	recvTX(t, acks)
+
+	waitFor(t, 100*T1, "no 500 for unanswered request", func() bool { return conn.count("SIP/2.0 500") == 1 })
+	if message.Pending() {
+		t.Error("tx still pending after 500")
+	}
+	if !invite.Pending() {
+		t.Fatal("invite answered before Timer C")
+	}
+	waitFor(t, 100*T1, "tx not terminated after 500", func() bool { return srv.getTX(message.Key()) == nil })
+
+	// INVITE 未应答时回复408
+	waitFor(t, TimerC, "no 408 for unanswered invite", func() bool { return conn.count("SIP/2.0 408") >= 1 })
+	srv.handlerRequest(newServerRequest(t, ACK, "z9hG4bK-tu-invite", "tuInvite", 1))
+	waitFor(t, 10*T4, "invite tx not terminated after ACK", func() bool { return srv.getTX(invite.Key()) == nil })
+	if n := conn.count("SIP/2.0 500"); n != 1 {
+		t.Errorf("want only the MESSAGE answered with 500, sent %d", n)
+	}
+}
@@ -0,0 +1,117 @@
+package sipapi
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceStatus 设备状态 GB28181 A.2.6.7
+type DeviceStatus struct {
+	// Result 查询结果 OK ERROR
+	Result string `xml:"Result" json:"result"`
+	// Online 是否在线 ONLINE OFFLINE
+	Online string `xml:"Online" json:"online"`
+	// Status 是否正常工作 OK ERROR
+	Status string `xml:"Status" json:"status"`
+	// Reason 不正常工作原因
+	Reason string `xml:"Reason" json:"reason"`
+	// Encode 是否编码 ON OFF
+	Encode string `xml:"Encode" json:"encode"`
+	// Record 是否录像 ON OFF
+	Record string `xml:"Record" json:"record"`
+	// DeviceTime 设备时间
+	DeviceTime int64 `xml:"-" json:"devicetime"`
+	// AlarmStatus 报警设备状态列表
+	AlarmStatus []AlarmStatusItem `xml:"Alarmstatus>Item" json:"alarmstatus"`
+	// UpdateAt 状态获取时间
+	UpdateAt int64 `xml:"-" json:"updateat"`
+}
+
+// AlarmStatusItem 报警设备状态
+type AlarmStatusItem struct {
+	DeviceID string `xml:"DeviceID" json:"deviceid"`
+	// DutyStatus 布防状态 ONDUTY OFFDUTY ALARM
+	DutyStatus string `xml:"DutyStatus" json:"dutystatus"`
+}
+
+// Value 数据库保存
+func (s DeviceStatus) Value() (driver.Value, error) {
+	return utils.JSONEncode(&s), nil
+}
+
+// Scan 数据库读取
+func (s *DeviceStatus) Scan(value interface{}) error {
+	switch t := value.(type) {
+	case []byte:
+		return utils.JSONDecode(t, s)
+	case string:
+		return utils.JSONDecode([]byte(t), s)
+	}
+	return errors.New(fmt.Sprint("Failed to unmarshal device status value:", value))
+}
+
+// 等待设备状态应答的请求 key:deviceid+sn
+var _statusResponses = &sync.Map{}
+
+// SipDeviceStatus 查询设备状态，10秒内未应答返回超时
+func SipDeviceStatus(deviceID string) (*DeviceStatus, error) {
+	device, ok := _activeDevices.Get(deviceID)
+	if !ok {
+		return nil, errors.New("设备不在线")
+	}
+	sn := utils.RandInt(100000, 999999)
+	key := fmt.Sprintf("%s%d", device.DeviceID, sn)
+	resp := make(chan *DeviceStatus, 1)
+	_statusResponses.Store(key, resp)
+	defer _statusResponses.Delete(key)
+	if err := sipMessage(device, device.addr, sip.GetDeviceStatusXML(device.DeviceID, sn)); err != nil {
+		return nil, err
+	}
+	select {
+	case status := <-resp:
+		return status, nil
+	case <-time.After(10 * time.Second):
+		return nil, errors.New("等待设备状态应答超时")
+	}
+}
+
+// MessageDeviceStatusResponse 设备状态应答
+type MessageDeviceStatusResponse struct {
+	DeviceStatus
+	CmdType    string `xml:"CmdType"`
+	SN         int    `xml:"SN"`
+	DeviceID   string `xml:"DeviceID"`
+	DeviceTime string `xml:"DeviceTime"`
+}
+
+// 解析设备状态应答，保存为设备最新状态
+func sipMessageDeviceStatus(u Devices, body []byte) error {
+	message := &MessageDeviceStatusResponse{}
+	if err := utils.XMLDecode(body, message); err != nil {
+		logrus.Errorln("Message Unmarshal xml err:", err, "body:", string(body))
+		return err
+	}
+	status := message.DeviceStatus
+	status.UpdateAt = time.Now().Unix()
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", message.DeviceTime, time.Local); err == nil {
+		status.DeviceTime = t.Unix()
+	}
+	if _, err := db.UpdateAll(db.DBClient, new(Devices), db.M{"deviceid=?": message.DeviceID}, db.M{"devicestatus": status}); err != nil {
+		logrus.Errorln("save device status fail,", message.DeviceID, err)
+	}
+	if v, ok := _statusResponses.Load(fmt.Sprintf("%s%d", message.DeviceID, message.SN)); ok {
+		select {
+		case v.(chan *DeviceStatus) <- &status:
+		default:
+		}
+	}
+	return nil
+}
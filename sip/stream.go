@@ -14,7 +14,7 @@ import (
 // Streams Streams
 type Streams struct {
 	db.DBModel
-	// 0  直播 1 历史
+	// 0  直播 1 历史 2 下载
 	T int `json:"t" gorm:"column:t"`
 	// 设备ID
 	DeviceID string `json:"deviceid" gorm:"column:deviceid"`
@@ -24,16 +24,13 @@ type Streams struct {
 	StreamType string `json:"streamtype" gorm:"column:streamtype"`
 	// 0正常 1关闭 -1 尚未开始
 	Status int `json:"status" gorm:"column:status"`
-	// header from params
-	Ftag db.M `gorm:"column:ftag" sql:"type:json" json:"-"`
-	// header to params
-	Ttag db.M `gorm:"column:ttag" sql:"type:json" json:"-"`
+	// sip 对话，用来发送bye等对话内请求
+	Dialog *sip.Dialog `gorm:"column:dialog" sql:"type:json" json:"-"`
 	// header callid
 	CallID string `json:"callid" gorm:"column:callid"`
 	// 是否停止
-	Stop   bool   `json:"stop" gorm:"column:stop"`
-	Msg    string `json:"msg" gorm:"column:msg"`
-	CseqNo uint32 `json:"cseqno" gorm:"column:cseqno"`
+	Stop bool   `json:"stop" gorm:"column:stop"`
+	Msg  string `json:"msg" gorm:"column:msg"`
 	// 视频流ID gb28181的ssrc
 	StreamID string `json:"streamid"  gorm:"column:streamid"`
 	// m3u8播放地址
@@ -46,12 +43,29 @@ type Streams struct {
 	WSFLV string `json:"wsflv" gorm:"column:wsflv"`
 	// zlm是否收到流
 	Stream bool `json:"stream" gorm:"column:stream"`
+	// 回放控制状态，T=1时有效
+	// Position 最近一次控制时的播放位置，相对回放开始时间的秒数
+	Position float64 `json:"position" gorm:"column:position"`
+	// PositionAt 最近一次控制时间
+	PositionAt int64 `json:"positionat" gorm:"column:positionat"`
+	// Scale 播放倍速
+	Scale float64 `json:"scale" gorm:"column:scale"`
+	// Paused 是否暂停
+	Paused bool `json:"paused" gorm:"column:paused"`
+	// 回放、下载的时间段
+	Start int64 `json:"start" gorm:"column:start"`
+	End   int64 `json:"end" gorm:"column:end"`
+	// 下载倍速，T=2时有效
+	Speed int `json:"speed" gorm:"column:speed"`
+	// 下载进度 0-100
+	Progress int `json:"progress" gorm:"column:progress"`
+	// 下载完成的mp4文件地址
+	File string `json:"file" gorm:"column:file"`
 
 	// ---
-	S, E time.Time     `json:"-" gorm:"-"`
-	ssrc string        // 国标ssrc 10进制字符串
-	Ext  int64         `json:"-" gorm:"-"` // 流等待过期时间
-	Resp *sip.Response `json:"-" gorm:"-"`
+	S, E time.Time `json:"-" gorm:"-"`
+	ssrc string    // 国标ssrc 10进制字符串
+	Ext  int64     `json:"-" gorm:"-"` // 流等待过期时间
 }
 
 // 当前系统中存在的流列表
@@ -121,34 +135,24 @@ func CheckStreams() {
 			}
 			logrus.Debugln("checkStreamClosed", stream.StreamID, stream.DeviceID)
 			// 关闭此流
-			channel := Channels{ChannelID: stream.ChannelID}
-			if err := db.Get(db.DBClient, &channel); err != nil {
-				logrus.Errorln("checkStreamGetchannelError", stream.StreamID, stream.ChannelID, err)
-				stream.Msg = err.Error()
+			if stream.Dialog == nil {
+				// 没有对话信息无法发送bye，直接关闭
+				logrus.Warningln("checkStreamDialog is nil", stream.StreamID, stream.DeviceID)
+				StreamList.Response.Delete(stream.StreamID)
+				StreamList.Succ.Delete(stream.ChannelID)
+				stream.Status = 1
+				stream.Stop = true
 				db.Save(db.DBClient, stream)
-				channel = Channels{
-					ChannelID: stream.ChannelID,
-					DeviceID:  stream.DeviceID,
-					URIStr:    fmt.Sprintf("sip:%s@%s", stream.ChannelID, _serverDevices.Region),
-				}
-			}
-			channelURI, _ := sip.ParseURI(channel.URIStr)
-			channel.addr = &sip.Address{URI: channelURI, Params: sip.NewParams()}
-			for k, v := range stream.Ttag {
-				channel.addr.Params.Add(k, sip.String{Str: v.(string)})
+				continue
 			}
-			for k, v := range stream.Ftag {
-				_serverDevices.addr.Params.Add(k, sip.String{Str: v.(string)})
+			stream.Dialog.SetDestination(device.source)
+			req, err := stream.Dialog.NewRequest(sip.BYE, nil, nil)
+			if err != nil {
+				logrus.Errorln("checkStreamBuildByeError", stream.StreamID, stream.ChannelID, err)
+				stream.Msg = err.Error()
+				db.Save(db.DBClient, stream)
+				continue
 			}
-			callid := sip.CallID(stream.CallID)
-			stream.CseqNo++
-
-			hb := sip.NewHeaderBuilder().SetToWithParam(channel.addr).SetFrom(_serverDevices.addr).AddVia(&sip.ViaHop{
-				Params: sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
-			}).SetContentType(&sip.ContentTypeSDP).SetMethod(sip.BYE).SetContact(_serverDevices.addr).SetCallID(&callid).SetSeqNo(uint(stream.CseqNo))
-			req := sip.NewRequest("", sip.BYE, channel.addr.URI, sip.DefaultSipVersion, hb.Build(), nil)
-			req.SetDestination(device.source)
-			req.SetRecipient(channel.addr.URI)
 
 			// 不管成功不成功 程序都删除掉，后面开新流，关闭不成功的后面重试
 			StreamList.Response.Delete(stream.StreamID)
@@ -163,11 +167,11 @@ func CheckStreams() {
 			}
 			response := tx.GetResponse()
 			if response == nil {
-				logrus.Warningln("checkStreamClosedFail response is nil", channel.ChannelID, channel.DeviceID, stream.StreamID)
+				logrus.Warningln("checkStreamClosedFail response is nil", stream.ChannelID, stream.DeviceID, stream.StreamID)
 				continue
 			}
 			if response.StatusCode() != http.StatusOK {
-				if response.StatusCode() == 481 {
+				if response.StatusCode() == sip.StatusCallTransactionDoesNotExist {
 					logrus.Infoln("checkStreamClosedFail1", stream.StreamID, response.StatusCode())
 					stream.Msg = response.Reason()
 					stream.Status = 1
@@ -189,3 +193,44 @@ func CheckStreams() {
 		skip += 100
 	}
 }
+
+// 根据对话内请求查找对应的流，先查找当前流列表，不存在时查询数据库中未关闭的流
+func getStreamByDialog(req *sip.Request) (*Streams, bool) {
+	var stream *Streams
+	StreamList.Response.Range(func(key, value interface{}) bool {
+		item := value.(*Streams)
+		if item.Dialog != nil && item.Dialog.Match(req) {
+			stream = item
+			return false
+		}
+		return true
+	})
+	if stream != nil {
+		return stream, true
+	}
+	callid, ok := req.CallID()
+	if !ok {
+		return nil, false
+	}
+	stream = &Streams{}
+	if err := db.GetQ(db.DBClient, stream, db.M{"callid=?": string(*callid), "status=?": 0}); err != nil {
+		return nil, false
+	}
+	if stream.Dialog == nil || !stream.Dialog.Match(req) {
+		return nil, false
+	}
+	return stream, true
+}
+
+// 设备端关闭流，停止媒体服务器的流并清理流列表
+func closeStreamByDevice(stream *Streams, msg string) {
+	zlmCloseStream(stream.StreamID)
+	stream.Status = 1
+	stream.Stop = true
+	stream.Msg = msg
+	db.Save(db.DBClient, stream)
+	StreamList.Response.Delete(stream.StreamID)
+	if stream.T == 0 {
+		StreamList.Succ.Delete(stream.ChannelID)
+	}
+}
@@ -0,0 +1,255 @@
+package sipapi
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/panjjo/gosip/db"
+	sip "github.com/panjjo/gosip/sip/s"
+	"github.com/panjjo/gosip/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SubscribeAlarm 报警订阅
+	SubscribeAlarm = "alarm"
+	// SubscribeCatalog 目录订阅
+	SubscribeCatalog = "catalog"
+	// SubscribeMobilePosition 移动设备位置订阅
+	SubscribeMobilePosition = "mobileposition"
+
+	defaultSubscribeExpires = 3600
+	// 订阅到期前多久刷新，秒
+	subscribeRefreshBefore = 60
+)
+
+// SubscribeParams 订阅条件
+type SubscribeParams struct {
+	// StartPriority 报警起始级别，0为全部
+	StartPriority int `json:"startpriority"`
+	// EndPriority 报警终止级别，0为全部
+	EndPriority int `json:"endpriority"`
+	// Method 报警方式，0为全部
+	Method int `json:"method"`
+	// Interval 移动设备位置上报间隔，秒
+	Interval int `json:"interval"`
+}
+
+// Value 数据库保存
+func (p SubscribeParams) Value() (driver.Value, error) {
+	return utils.JSONEncode(&p), nil
+}
+
+// Scan 数据库读取
+func (p *SubscribeParams) Scan(value interface{}) error {
+	switch t := value.(type) {
+	case []byte:
+		return utils.JSONDecode(t, p)
+	case string:
+		return utils.JSONDecode([]byte(t), p)
+	}
+	return errors.New(fmt.Sprint("Failed to unmarshal subscribe params value:", value))
+}
+
+// Subscriptions 设备事件订阅
+type Subscriptions struct {
+	db.DBModel
+	// DeviceID 订阅的设备编号
+	DeviceID string `json:"deviceid" gorm:"column:deviceid"`
+	// Event 订阅类型 alarm catalog mobileposition
+	Event string `json:"event" gorm:"column:event"`
+	// Expires 订阅有效期，秒
+	Expires int `json:"expires" gorm:"column:expires"`
+	// Params 订阅条件
+	Params SubscribeParams `json:"params" gorm:"column:params" sql:"type:json"`
+	// ExpireAt 当前订阅到期时间，0 未订阅成功
+	ExpireAt int64 `json:"expireat" gorm:"column:expireat"`
+
+	Dialog *sip.Dialog `json:"-" gorm:"column:dialog" sql:"type:json"`
+}
+
+type subscribeEvent struct {
+	// Event 头域
+	event string
+	body  func(sub *Subscriptions, sn int) []byte
+}
+
+var subscribeEvents = map[string]subscribeEvent{
+	SubscribeAlarm: {event: "presence", body: func(sub *Subscriptions, sn int) []byte {
+		return sip.GetAlarmSubscribeXML(sub.DeviceID, sn, sub.Params.StartPriority, sub.Params.EndPriority, sub.Params.Method)
+	}},
+	SubscribeCatalog: {event: "Catalog", body: func(sub *Subscriptions, sn int) []byte {
+		return sip.GetCatalogXML(sub.DeviceID)
+	}},
+	SubscribeMobilePosition: {event: "presence", body: func(sub *Subscriptions, sn int) []byte {
+		interval := sub.Params.Interval
+		if interval <= 0 {
+			interval = defaultPositionInterval
+		}
+		return sip.GetMobilePositionSubscribeXML(sub.DeviceID, sn, interval)
+	}},
+}
+
+// SipSubscribe 开启设备订阅，保存订阅配置，设备在线时立即发送订阅
+func SipSubscribe(deviceID, event string, expires int, params SubscribeParams) (*Subscriptions, error) {
+	if _, ok := subscribeEvents[event]; !ok {
+		return nil, fmt.Errorf("不支持的订阅类型:%s", event)
+	}
+	if expires <= 0 {
+		expires = defaultSubscribeExpires
+	}
+	if expires <= subscribeRefreshBefore {
+		return nil, fmt.Errorf("订阅有效期必须大于%d秒", subscribeRefreshBefore)
+	}
+	sub := &Subscriptions{DeviceID: deviceID, Event: event}
+	if err := db.Get(db.DBClient, sub); err != nil && !db.RecordNotFound(err) {
+		return nil, err
+	}
+	sub.Expires = expires
+	sub.Params = params
+	if err := db.Save(db.DBClient, sub); err != nil {
+		return nil, err
+	}
+	device, ok := _activeDevices.Get(deviceID)
+	if !ok {
+		// 设备上线后自动订阅
+		return sub, nil
+	}
+	if err := sipSubscribe(device, sub); err != nil {
+		return sub, err
+	}
+	return sub, nil
+}
+
+// SipUnsubscribe 取消设备订阅，设备在线时发送有效期为0的订阅
+func SipUnsubscribe(deviceID, event string) error {
+	sub := &Subscriptions{DeviceID: deviceID, Event: event}
+	if err := db.Get(db.DBClient, sub); err != nil {
+		return err
+	}
+	if device, ok := _activeDevices.Get(deviceID); ok && sub.Dialog != nil && sub.ExpireAt > time.Now().Unix() {
+		sub.Dialog.SetDestination(device.source)
+		if req, err := newSubscribeRequest(device, sub, 0); err == nil {
+			if tx, err := srv.Request(req); err == nil {
+				if _, err := sipResponse(tx); err != nil {
+					logrus.Warnln("sipUnsubscribe response error,", deviceID, event, err)
+				}
+			}
+		}
+	}
+	return db.Del(db.DBClient, &Subscriptions{DBModel: db.DBModel{ID: sub.ID}})
+}
+
+// 发送订阅，订阅有效时在对话内刷新，否则建立新的订阅
+func sipSubscribe(device Devices, sub *Subscriptions) error {
+	if sub.Dialog != nil && sub.ExpireAt > time.Now().Unix() {
+		sub.Dialog.SetDestination(device.source)
+		err := sipSubscribeRequest(device, sub)
+		if err == nil {
+			return nil
+		}
+		logrus.Warnln("sipSubscribe refresh fail, resubscribe,", sub.DeviceID, sub.Event, err)
+	}
+	sub.Dialog = nil
+	return sipSubscribeRequest(device, sub)
+}
+
+func sipSubscribeRequest(device Devices, sub *Subscriptions) error {
+	req, err := newSubscribeRequest(device, sub, sub.Expires)
+	if err != nil {
+		return err
+	}
+	tx, err := srv.Request(req)
+	if err != nil {
+		return err
+	}
+	response, err := sipResponse(tx)
+	if err != nil {
+		return err
+	}
+	if sub.Dialog == nil {
+		if sub.Dialog, err = sip.NewDialog(req, response); err != nil {
+			return err
+		}
+	}
+	// 设备可以缩短订阅有效期
+	expires := sub.Expires
+	if hdrs := response.GetHeaders("Expires"); len(hdrs) > 0 {
+		if v, ok := hdrs[0].(*sip.Expires); ok && int(*v) > 0 {
+			expires = int(*v)
+		}
+	}
+	sub.ExpireAt = time.Now().Unix() + int64(expires)
+	_, err = db.UpdateAll(db.DBClient, new(Subscriptions), db.M{"id=?": sub.ID}, db.M{"dialog": sub.Dialog, "expireat": sub.ExpireAt})
+	return err
+}
+
+// 生成订阅请求，存在对话时生成对话内请求
+func newSubscribeRequest(device Devices, sub *Subscriptions, expires int) (*sip.Request, error) {
+	ev := subscribeEvents[sub.Event]
+	body := ev.body(sub, utils.RandInt(100000, 999999))
+	var req *sip.Request
+	if sub.Dialog != nil {
+		var err error
+		if req, err = sub.Dialog.NewRequest(sip.SUBSCRIBE, &sip.ContentTypeXML, body); err != nil {
+			return nil, err
+		}
+	} else {
+		addr := deviceAddress(device.addr, device.TransPort)
+		hb := sip.NewHeaderBuilder().SetTo(addr).SetFrom(&sip.Address{URI: _serverDevices.addr.URI, Params: sip.NewParams()}).AddVia(&sip.ViaHop{
+			Transport: device.TransPort,
+			Params:    sip.NewParams().Add("branch", sip.String{Str: sip.GenerateBranch()}),
+		}).SetContentType(&sip.ContentTypeXML).SetMethod(sip.SUBSCRIBE).SetContact(_serverDevices.addr)
+		req = sip.NewRequest("", sip.SUBSCRIBE, addr.URI, sip.DefaultSipVersion, hb.Build(), body)
+		req.SetDestination(device.source)
+	}
+	exp := sip.Expires(expires)
+	req.AppendHeader(&sip.GenericHeader{HeaderName: "Event", Contents: ev.event})
+	req.AppendHeader(&exp)
+	return req, nil
+}
+
+// 设备上线后重新订阅，设备重启后原订阅已失效
+func subscribeDevice(device Devices) {
+	if config.Catalog.Subscribe {
+		// 自动订阅目录
+		sub := &Subscriptions{DeviceID: device.DeviceID, Event: SubscribeCatalog}
+		if err := db.Get(db.DBClient, sub); db.RecordNotFound(err) {
+			sub.Expires = defaultSubscribeExpires
+			if err := db.Create(db.DBClient, sub); err != nil {
+				logrus.Errorln("subscribeDevice create catalog subscription error,", device.DeviceID, err)
+			}
+		}
+	}
+	subs := []Subscriptions{}
+	if _, err := db.FindT(db.DBClient, new(Subscriptions), &subs, db.M{"deviceid=?": device.DeviceID}, "", 0, -1, false); err != nil {
+		logrus.Errorln("subscribeDevice find subscriptions error,", device.DeviceID, err)
+		return
+	}
+	for i := range subs {
+		subs[i].Dialog = nil
+		if err := sipSubscribe(device, &subs[i]); err != nil {
+			logrus.Warnln("subscribeDevice fail,", device.DeviceID, subs[i].Event, err)
+		}
+	}
+}
+
+// CheckSubscriptions 定时刷新即将到期的订阅
+func CheckSubscriptions() {
+	subs := []Subscriptions{}
+	if _, err := db.FindT(db.DBClient, new(Subscriptions), &subs, db.M{"expireat<?": time.Now().Unix() + subscribeRefreshBefore}, "", 0, -1, false); err != nil {
+		logrus.Errorln("checkSubscriptions find subscriptions error,", err)
+		return
+	}
+	for i := range subs {
+		device, ok := _activeDevices.Get(subs[i].DeviceID)
+		if !ok {
+			continue
+		}
+		if err := sipSubscribe(device, &subs[i]); err != nil {
+			logrus.Warnln("checkSubscriptions subscribe fail,", subs[i].DeviceID, subs[i].Event, err)
+		}
+	}
+}
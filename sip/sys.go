@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/panjjo/gosip/db"
 	"github.com/panjjo/gosip/m"
@@ -22,13 +23,33 @@ func Start() {
 	db.DBClient.AutoMigrate(new(Streams))
 	db.DBClient.AutoMigrate(new(m.SysInfo))
 	db.DBClient.AutoMigrate(new(Files))
+	db.DBClient.AutoMigrate(new(Presets))
+	db.DBClient.AutoMigrate(new(Alarms))
+	db.DBClient.AutoMigrate(new(Subscriptions))
+	db.DBClient.AutoMigrate(new(Nodes))
+	db.DBClient.AutoMigrate(new(Positions))
+	db.DBClient.AutoMigrate(new(Platforms))
+	db.DBClient.AutoMigrate(new(PlatformChannels))
 
 	LoadSYSInfo()
 
+	sip.SetTimers(time.Duration(config.Timer.T1)*time.Millisecond, time.Duration(config.Timer.T2)*time.Millisecond)
 	srv = sip.NewServer()
 	srv.RegistHandler(sip.REGISTER, handlerRegister)
 	srv.RegistHandler(sip.MESSAGE, handlerMessage)
+	srv.RegistHandler(sip.BYE, handlerBye)
+	srv.RegistHandler(sip.CANCEL, handlerCancel)
+	srv.RegistHandler(sip.ACK, handlerAck)
+	srv.RegistHandler(sip.INFO, handlerInfo)
+	srv.RegistHandler(sip.NOTIFY, handlerNotify)
+	srv.RegistHandler(sip.INVITE, handlerInvite)
 	go srv.ListenUDPServer(config.UDP)
+	if config.TCP != "" {
+		go srv.ListenTCPServer(config.TCP)
+	}
+	if config.TLS.Addr != "" {
+		go srv.ListenTLSServer(config.TLS.Addr, config.TLS.Cert, config.TLS.Key)
+	}
 }
 
 // MODDEBUG MODDEBUG
@@ -49,6 +70,9 @@ func (a *ActiveDevices) Get(key string) (Devices, bool) {
 
 var _activeDevices ActiveDevices
 
+// 注册摘要认证的nonce，有效期5分钟
+var _nonces *sip.NonceStore
+
 // 系统运行信息
 var _sysinfo *m.SysInfo
 var config *m.Config
@@ -57,6 +81,11 @@ func LoadSYSInfo() {
 
 	config = m.MConfig
 	_activeDevices = ActiveDevices{sync.Map{}}
+	_nonces = sip.NewNonceStore(5 * time.Minute)
+	config.Auth.Algorithms = parseAlgorithms(config.Auth.Algorithms)
+	if len(config.Auth.Algorithms) == 0 {
+		config.Auth.Algorithms = []string{sip.AlgorithmMD5}
+	}
 
 	StreamList = streamsList{&sync.Map{}, &sync.Map{}, 0}
 	ssrcLock = &sync.Mutex{}
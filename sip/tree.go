@@ -0,0 +1,392 @@
+package sipapi
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/panjjo/gosip/db"
+	"github.com/panjjo/gosip/m"
+	"github.com/sirupsen/logrus"
+)
+
+// 组织树节点类型
+const (
+	NodeTypeDevice        = "device"
+	NodeTypeCivilCode     = "civilcode"
+	NodeTypeBusinessGroup = "businessgroup"
+	NodeTypeVirtualOrg    = "virtualorg"
+	NodeTypeChannel       = "channel"
+)
+
+// Nodes 目录中的组织节点（行政区划、业务分组、虚拟组织）
+type Nodes struct {
+	db.DBModel
+	// DeviceID 上报目录的设备编号
+	DeviceID string `json:"deviceid" gorm:"column:deviceid"`
+	// NodeID 节点编码
+	NodeID string `json:"nodeid" gorm:"column:nodeid"`
+	Name   string `json:"name" gorm:"column:name"`
+	// Type 节点类型 civilcode businessgroup virtualorg
+	Type            string `json:"type" gorm:"column:type"`
+	ParentID        string `json:"parentid" gorm:"column:parentid"`
+	BusinessGroupID string `json:"businessgroupid" gorm:"column:businessgroupid"`
+	CivilCode       string `json:"civilcode" gorm:"column:civilcode"`
+}
+
+// 目录项的组织节点类型，行政区划编码为2-8位，业务分组类型编码215，虚拟组织类型编码216
+func orgNodeType(id string) (string, bool) {
+	if len(id) >= 2 && len(id) <= 8 && len(id)%2 == 0 {
+		if _, err := strconv.Atoi(id); err == nil {
+			return NodeTypeCivilCode, true
+		}
+	}
+	if len(id) == 20 {
+		switch id[10:13] {
+		case "215":
+			return NodeTypeBusinessGroup, true
+		case "216":
+			return NodeTypeVirtualOrg, true
+		}
+	}
+	return "", false
+}
+
+// 保存目录中的组织节点
+func saveCatalogNode(deviceID, nodeType string, d Channels) error {
+	node := &Nodes{DeviceID: deviceID, NodeID: d.ChannelID}
+	if err := db.Get(db.DBClient, node); err != nil && !db.RecordNotFound(err) {
+		return err
+	}
+	node.Name = d.Name
+	node.Type = nodeType
+	node.ParentID = d.ParentID
+	node.BusinessGroupID = d.BusinessGroupID
+	node.CivilCode = d.CivilCode
+	return db.Save(db.DBClient, node)
+}
+
+// 删除完整目录中已不存在的组织节点
+func removeMissingNodes(deviceID string, reported map[string]bool) {
+	nodes := []Nodes{}
+	if _, err := db.FindT(db.DBClient, new(Nodes), &nodes, db.M{"deviceid=?": deviceID}, "", 0, -1, false); err != nil {
+		logrus.Errorln("catalog find nodes fail,deviceid:", deviceID, err)
+		return
+	}
+	for _, node := range nodes {
+		if !reported[node.NodeID] {
+			db.Del(db.DBClient, &Nodes{DBModel: db.DBModel{ID: node.ID}})
+		}
+	}
+}
+
+// TreeNode 组织树节点
+type TreeNode struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	DeviceID string `json:"deviceid"`
+	ParentID string `json:"parentid"`
+	// Status 通道状态，仅通道节点
+	Status string `json:"status,omitempty"`
+	// Total 节点下通道总数
+	Total int `json:"total"`
+	// Online 节点下在线通道数
+	Online int `json:"online"`
+	// Leaf 是否为叶子节点，懒加载时用来判断是否还有子节点
+	Leaf     bool        `json:"leaf"`
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// 统计节点下通道数量，并对子节点排序
+func (n *TreeNode) count() {
+	if n.Type == NodeTypeChannel {
+		n.Total = 1
+		if n.Status == m.DeviceStatusON {
+			n.Online = 1
+		}
+		n.Leaf = true
+		return
+	}
+	n.Total, n.Online = 0, 0
+	for _, child := range n.Children {
+		child.count()
+		n.Total += child.Total
+		n.Online += child.Online
+	}
+	n.Leaf = len(n.Children) == 0
+	n.sortChildren()
+}
+
+// 子节点排序：组织节点在前，通道在后
+func (n *TreeNode) sortChildren() {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		ci, cj := n.Children[i].Type == NodeTypeChannel, n.Children[j].Type == NodeTypeChannel
+		if ci != cj {
+			return cj
+		}
+		return n.Children[i].ID < n.Children[j].ID
+	})
+}
+
+// 懒加载时只返回当前层级
+func (n *TreeNode) shallow() *TreeNode {
+	node := *n
+	node.Children = nil
+	return &node
+}
+
+func (n *TreeNode) find(id string) *TreeNode {
+	if n.ID == id {
+		return n
+	}
+	for _, child := range n.Children {
+		if node := child.find(id); node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+// 构建设备的组织树，根节点为设备
+func deviceTree(device Devices) (*TreeNode, error) {
+	root, index, err := orgTree(device)
+	if err != nil {
+		return nil, err
+	}
+	channels := []Channels{}
+	if _, err := db.FindT(db.DBClient, new(Channels), &channels, db.M{"deviceid=?": device.DeviceID, "status<>?": m.DeviceStatusREMOVED}, "", 0, -1, false); err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if _, ok := index[channel.ChannelID]; ok {
+			continue
+		}
+		tn := &TreeNode{ID: channel.ChannelID, Name: channel.Name, Type: NodeTypeChannel, DeviceID: device.DeviceID, Status: channel.Status}
+		tn.ParentID = treeParent(channel.ChannelID, channel.ParentID, channel.BusinessGroupID, channel.CivilCode, index, device.DeviceID)
+		index[tn.ParentID].Children = append(index[tn.ParentID].Children, tn)
+	}
+	root.count()
+	return root, nil
+}
+
+// 构建设备下的组织节点，不包含通道，index 为节点编码到节点的索引(包含设备)
+func orgTree(device Devices) (*TreeNode, map[string]*TreeNode, error) {
+	root := &TreeNode{ID: device.DeviceID, Name: device.Name, Type: NodeTypeDevice, DeviceID: device.DeviceID}
+	nodes := []Nodes{}
+	if _, err := db.FindT(db.DBClient, new(Nodes), &nodes, db.M{"deviceid=?": device.DeviceID}, "", 0, -1, false); err != nil {
+		return nil, nil, err
+	}
+	index := map[string]*TreeNode{device.DeviceID: root}
+	for _, node := range nodes {
+		index[node.NodeID] = &TreeNode{ID: node.NodeID, Name: node.Name, Type: node.Type, DeviceID: device.DeviceID}
+	}
+	for _, node := range nodes {
+		tn := index[node.NodeID]
+		if node.Type == NodeTypeCivilCode {
+			tn.ParentID = civilCodeParent(node.NodeID, index, device.DeviceID)
+		} else {
+			tn.ParentID = treeParent(node.NodeID, node.ParentID, node.BusinessGroupID, "", index, device.DeviceID)
+		}
+	}
+	for _, node := range nodes {
+		tn := index[node.NodeID]
+		if treeLoop(tn, index, device.DeviceID) {
+			// 挂到父节点会成环(如A的父节点为B、B的父节点为A)，改挂在设备下
+			logrus.Warnln("tree node parent loop,deviceid:", device.DeviceID, "nodeid:", tn.ID, "parentid:", tn.ParentID)
+			tn.ParentID = device.DeviceID
+		}
+	}
+	for _, node := range nodes {
+		tn := index[node.NodeID]
+		index[tn.ParentID].Children = append(index[tn.ParentID].Children, tn)
+	}
+	return root, index, nil
+}
+
+// 按父节点字段分组的通道数量
+type channelCount struct {
+	ParentID        string `gorm:"column:parentid"`
+	BusinessGroupID string `gorm:"column:businessgroupid"`
+	CivilCode       string `gorm:"column:civilcode"`
+	Total           int    `gorm:"column:total"`
+	Online          int    `gorm:"column:online"`
+}
+
+// 懒加载设备的组织树，只查询parentID下一级的通道，节点的通道数量使用分组统计，parentID 为空时只统计设备
+func lazyDeviceTree(device Devices, parentID string) (*TreeNode, error) {
+	root, index, err := orgTree(device)
+	if err != nil {
+		return nil, err
+	}
+	start := root
+	if parentID != "" {
+		if start = index[parentID]; start == nil {
+			return nil, nil
+		}
+	}
+	query := db.M{"deviceid=?": device.DeviceID, "status<>?": m.DeviceStatusREMOVED}
+	if len(index) > 1 {
+		// 与组织节点编码相同的通道不计入
+		nodeIDs := make([]string, 0, len(index))
+		for id := range index {
+			nodeIDs = append(nodeIDs, id)
+		}
+		query["channelid not in (?)"] = nodeIDs
+	}
+	counts := []channelCount{}
+	err = db.GenQueryDB(db.DBClient.Model(new(Channels)), query).
+		Select("COALESCE(parentid,'') AS parentid, COALESCE(businessgroupid,'') AS businessgroupid, COALESCE(civilcode,'') AS civilcode, COUNT(*) AS total, SUM(CASE WHEN status=? THEN 1 ELSE 0 END) AS online", m.DeviceStatusON).
+		Group("COALESCE(parentid,''), COALESCE(businessgroupid,''), COALESCE(civilcode,'')").Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	// 直接挂在节点下的通道数
+	direct := map[string]int{}
+	ors := []map[string]any{}
+	for _, c := range counts {
+		p := treeParent("", c.ParentID, c.BusinessGroupID, c.CivilCode, index, device.DeviceID)
+		direct[p] += c.Total
+		for n := index[p]; n != nil; n = index[n.ParentID] {
+			n.Total += c.Total
+			n.Online += c.Online
+			if n == root {
+				break
+			}
+		}
+		if p == start.ID {
+			ors = append(ors, map[string]any{"COALESCE(parentid,'')=?": c.ParentID, "COALESCE(businessgroupid,'')=?": c.BusinessGroupID, "COALESCE(civilcode,'')=?": c.CivilCode})
+		}
+	}
+	for _, n := range index {
+		n.Leaf = len(n.Children) == 0 && direct[n.ID] == 0
+	}
+	if parentID == "" {
+		return start, nil
+	}
+	children := make([]*TreeNode, 0, len(start.Children))
+	for _, child := range start.Children {
+		children = append(children, child.shallow())
+	}
+	if len(ors) > 0 {
+		channels := []Channels{}
+		if err := db.GenQueryDB(db.DBClient, query, ors).Find(&channels).Error; err != nil {
+			return nil, err
+		}
+		for _, channel := range channels {
+			tn := &TreeNode{ID: channel.ChannelID, Name: channel.Name, Type: NodeTypeChannel, DeviceID: device.DeviceID, ParentID: start.ID, Status: channel.Status, Total: 1, Leaf: true}
+			if channel.Status == m.DeviceStatusON {
+				tn.Online = 1
+			}
+			children = append(children, tn)
+		}
+	}
+	start.Children = children
+	start.sortChildren()
+	return start, nil
+}
+
+// 父节点依次取 ParentID(可能为/分隔的路径，取最后一段)、业务分组、行政区划，都不存在时挂在设备下
+func treeParent(id, parentID, businessGroupID, civilCode string, index map[string]*TreeNode, root string) string {
+	if parentID != "" {
+		parts := strings.Split(strings.Trim(parentID, "/"), "/")
+		if p := parts[len(parts)-1]; p != id {
+			if _, ok := index[p]; ok {
+				return p
+			}
+		}
+	}
+	for _, p := range []string{businessGroupID, civilCode} {
+		if p == "" || p == id {
+			continue
+		}
+		if _, ok := index[p]; ok {
+			return p
+		}
+	}
+	return root
+}
+
+// 沿父节点向上是否会回到该节点，上层不包含该节点的环由环中的节点处理
+func treeLoop(n *TreeNode, index map[string]*TreeNode, root string) bool {
+	visited := map[string]bool{}
+	for p := n.ParentID; p != root; p = index[p].ParentID {
+		if p == n.ID {
+			return true
+		}
+		if visited[p] {
+			return false
+		}
+		visited[p] = true
+	}
+	return false
+}
+
+// 行政区划的父节点为上级行政区划编码
+func civilCodeParent(id string, index map[string]*TreeNode, root string) string {
+	for l := len(id) - 2; l >= 2; l -= 2 {
+		if _, ok := index[id[:l]]; ok {
+			return id[:l]
+		}
+	}
+	return root
+}
+
+// Tree 组织树，deviceID 为空时返回所有设备，parentID 不为空时从该节点开始，lazy 为 true 时只返回下一级节点
+func Tree(deviceID, parentID string, lazy bool) ([]*TreeNode, error) {
+	devices := []Devices{}
+	query := db.M{}
+	if deviceID != "" {
+		query["deviceid=?"] = deviceID
+	} else if parentID != "" {
+		// 根据节点找到所属设备
+		node := &Nodes{}
+		if err := db.GetQ(db.DBClient, node, db.M{"nodeid=?": parentID}); err == nil {
+			query["deviceid=?"] = node.DeviceID
+		} else {
+			channel := &Channels{}
+			if err := db.GetQ(db.DBClient, channel, db.M{"channelid=?": parentID}); err == nil {
+				query["deviceid=?"] = channel.DeviceID
+			} else {
+				query["deviceid=?"] = parentID
+			}
+		}
+	}
+	if _, err := db.FindT(db.DBClient, new(Devices), &devices, query, "deviceid", 0, -1, false); err != nil {
+		return nil, err
+	}
+	result := []*TreeNode{}
+	for _, device := range devices {
+		if lazy {
+			start, err := lazyDeviceTree(device, parentID)
+			if err != nil {
+				return nil, err
+			}
+			if start == nil {
+				continue
+			}
+			if parentID != "" {
+				result = append(result, start.Children...)
+			} else {
+				result = append(result, start.shallow())
+			}
+			continue
+		}
+		root, err := deviceTree(device)
+		if err != nil {
+			return nil, err
+		}
+		start := root
+		if parentID != "" {
+			if start = root.find(parentID); start == nil {
+				continue
+			}
+		}
+		if parentID != "" {
+			result = append(result, start.Children...)
+		} else {
+			result = append(result, start)
+		}
+	}
+	return result, nil
+}
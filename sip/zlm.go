@@ -135,3 +135,50 @@ func zlmStopRecord(values url.Values) error {
 	}
 	return nil
 }
+
+// zlm 关闭指定应用下的流
+func zlmCloseAppStream(app, stream string) {
+	utils.GetRequest(config.Media.RESTFUL + "/index/api/close_streams?secret=" + config.Media.Secret + "&app=" + app + "&stream=" + stream)
+}
+
+type zlmSendRtpResp struct {
+	Code      int    `json:"code"`
+	Msg       string `json:"msg"`
+	LocalPort int    `json:"local_port"`
+}
+
+// zlm 开始发送rtp，passive 为true时等待对端连接(tcp被动模式)，返回本地端口
+func zlmStartSendRtp(values url.Values, passive bool) (int, error) {
+	api := "/index/api/startSendRtp?"
+	if passive {
+		api = "/index/api/startSendRtpPassive?"
+	}
+	body, err := utils.GetRequest(config.Media.RESTFUL + api + values.Encode())
+	if err != nil {
+		return 0, err
+	}
+	res := zlmSendRtpResp{}
+	if err = utils.JSONDecode(body, &res); err != nil {
+		return 0, err
+	}
+	if res.Code != 0 {
+		return 0, utils.NewError(nil, res.Msg)
+	}
+	return res.LocalPort, nil
+}
+
+// zlm 停止发送rtp
+func zlmStopSendRtp(values url.Values) error {
+	body, err := utils.GetRequest(config.Media.RESTFUL + "/index/api/stopSendRtp?" + values.Encode())
+	if err != nil {
+		return err
+	}
+	tmp := map[string]interface{}{}
+	if err = utils.JSONDecode(body, &tmp); err != nil {
+		return err
+	}
+	if code, ok := tmp["code"]; !ok || fmt.Sprint(code) != "0" {
+		return utils.NewError(nil, tmp)
+	}
+	return nil
+}